@@ -0,0 +1,433 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"os"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+	"github.com/Ulugbek999/wallet/pkg/wallet"
+)
+
+// command - подкоманда CLI. mutates - нужно ли сохранять каталог после выполнения с аргументами args.
+type command struct {
+	mutates func(args []string) bool
+	run     func(svc *wallet.Service, args []string, stderr io.Writer) (interface{}, error)
+}
+
+var commands = map[string]command{
+	"register": {always, registerCommand},
+	"deposit":  {always, depositCommand},
+	"pay":      {always, payCommand},
+	"reject":   {always, rejectCommand},
+	"repeat":   {always, repeatCommand},
+	"favorite": {favoriteMutates, favoriteCommand},
+	"history":  {never, historyCommand},
+	"sum":      {never, sumCommand},
+	"export":   {never, exportCommand},
+	"import":   {always, importCommand},
+}
+
+func always(args []string) bool {
+	return true
+}
+
+func never(args []string) bool {
+	return false
+}
+
+// favoriteMutates - из действий с избранным только list ничего не изменяет.
+func favoriteMutates(args []string) bool {
+	return len(args) == 0 || args[0] != "list"
+}
+
+func execute(dir string, actor string, name string, args []string, stderr io.Writer) (interface{}, error) {
+	if name == "convert-format" {
+		return convertFormatCommand(args, stderr)
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		return nil, usageError("unknown command %q", name)
+	}
+
+	svc, err := load(dir)
+	if err != nil {
+		return nil, err
+	}
+	svc.SetActor(actor)
+
+	result, err := cmd.run(svc, args, stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.mutates(args) {
+		err = save(svc, dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// load читает каталог данных. Несуществующий каталог - новый пустой кошелёк.
+func load(dir string) (*wallet.Service, error) {
+	svc := &wallet.Service{}
+
+	_, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return svc, nil
+	}
+
+	err = svc.Import(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return svc, nil
+}
+
+// save экспортирует данные в dir.
+func save(svc *wallet.Service, dir string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	return svc.Export(dir)
+}
+
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	return flags
+}
+
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	err := flags.Parse(args)
+	if err != nil {
+		return usageError("%v", err)
+	}
+
+	if flags.NArg() != 0 {
+		return usageError("unexpected arguments %v", flags.Args())
+	}
+
+	return nil
+}
+
+func registerCommand(svc *wallet.Service, args []string, stderr io.Writer) (interface{}, error) {
+	flags := newFlagSet("register", stderr)
+	phone := flags.String("phone", "", "phone number")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := svc.RegisterAccount(types.Phone(*phone))
+	if err != nil {
+		return nil, err
+	}
+
+	return newAccountOutput(account), nil
+}
+
+func depositCommand(svc *wallet.Service, args []string, stderr io.Writer) (interface{}, error) {
+	flags := newFlagSet("deposit", stderr)
+	accountID := flags.Int64("account", 0, "account id")
+	amount := flags.Int64("amount", 0, "amount in minimal units")
+	key := flags.String("key", "", "idempotency key")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if *key != "" {
+		err = svc.DepositIdempotent(*key, *accountID, types.Money(*amount))
+	} else {
+		err = svc.Deposit(*accountID, types.Money(*amount))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := svc.FindAccountByID(*accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAccountOutput(account), nil
+}
+
+func payCommand(svc *wallet.Service, args []string, stderr io.Writer) (interface{}, error) {
+	flags := newFlagSet("pay", stderr)
+	accountID := flags.Int64("account", 0, "account id")
+	amount := flags.Int64("amount", 0, "amount in minimal units")
+	category := flags.String("category", "", "payment category")
+	key := flags.String("key", "", "idempotency key")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var payment *types.Payment
+	if *key != "" {
+		payment, err = svc.PayIdempotent(*key, *accountID, types.Money(*amount), types.PaymentCategory(*category))
+	} else {
+		payment, err = svc.Pay(*accountID, types.Money(*amount), types.PaymentCategory(*category))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newPaymentOutput(*payment), nil
+}
+
+func rejectCommand(svc *wallet.Service, args []string, stderr io.Writer) (interface{}, error) {
+	flags := newFlagSet("reject", stderr)
+	paymentID := flags.String("payment", "", "payment id")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	err = svc.Reject(*paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	payment, err := svc.FindPaymentByID(*paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPaymentOutput(*payment), nil
+}
+
+func repeatCommand(svc *wallet.Service, args []string, stderr io.Writer) (interface{}, error) {
+	flags := newFlagSet("repeat", stderr)
+	paymentID := flags.String("payment", "", "payment id")
+	key := flags.String("key", "", "idempotency key")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var payment *types.Payment
+	if *key != "" {
+		payment, err = svc.RepeatIdempotent(*key, *paymentID)
+	} else {
+		payment, err = svc.Repeat(*paymentID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newPaymentOutput(*payment), nil
+}
+
+// favoriteCommand выполняет действие с избранным: add, pay, list или delete.
+func favoriteCommand(svc *wallet.Service, args []string, stderr io.Writer) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, usageError("favorite: action required (add, pay, list, delete)")
+	}
+
+	action, args := args[0], args[1:]
+	flags := newFlagSet("favorite "+action, stderr)
+
+	switch action {
+	case "add":
+		paymentID := flags.String("payment", "", "payment id")
+		name := flags.String("name", "", "favorite name")
+		err := parseFlags(flags, args)
+		if err != nil {
+			return nil, err
+		}
+
+		favorite, err := svc.FavoritePayment(*paymentID, *name)
+		if err != nil {
+			return nil, err
+		}
+
+		return newFavoriteOutput(*favorite), nil
+	case "pay":
+		favoriteID := flags.String("id", "", "favorite id")
+		key := flags.String("key", "", "idempotency key")
+		err := parseFlags(flags, args)
+		if err != nil {
+			return nil, err
+		}
+
+		var payment *types.Payment
+		if *key != "" {
+			payment, err = svc.PayFromFavoriteIdempotent(*key, *favoriteID)
+		} else {
+			payment, err = svc.PayFromFavorite(*favoriteID)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return newPaymentOutput(*payment), nil
+	case "list":
+		accountID := flags.Int64("account", 0, "account id")
+		err := parseFlags(flags, args)
+		if err != nil {
+			return nil, err
+		}
+
+		favorites, err := svc.FavoritesForAccount(*accountID)
+		if err != nil {
+			return nil, err
+		}
+
+		result := []favoriteOutput{}
+		for _, favorite := range favorites {
+			result = append(result, newFavoriteOutput(favorite))
+		}
+
+		return result, nil
+	case "delete":
+		favoriteID := flags.String("id", "", "favorite id")
+		err := parseFlags(flags, args)
+		if err != nil {
+			return nil, err
+		}
+
+		err = svc.DeleteFavorite(*favoriteID)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]string{"deleted": *favoriteID}, nil
+	}
+
+	return nil, usageError("favorite: unknown action %q", action)
+}
+
+func historyCommand(svc *wallet.Service, args []string, stderr io.Writer) (interface{}, error) {
+	flags := newFlagSet("history", stderr)
+	accountID := flags.Int64("account", 0, "account id")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	payments, err := svc.ExportAccountHistory(*accountID)
+	if err != nil && !errors.Is(err, wallet.ErrPaymentNotFound) {
+		return nil, err
+	}
+
+	result := []paymentOutput{}
+	for _, payment := range payments {
+		result = append(result, newPaymentOutput(payment))
+	}
+
+	return result, nil
+}
+
+func sumCommand(svc *wallet.Service, args []string, stderr io.Writer) (interface{}, error) {
+	flags := newFlagSet("sum", stderr)
+	goroutines := flags.Int("goroutines", 1, "number of goroutines")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	sum, err := svc.SumPaymentsContext(context.Background(), *goroutines)
+	if err != nil {
+		return nil, err
+	}
+
+	return sumOutput{Sum: sum}, nil
+}
+
+func exportCommand(svc *wallet.Service, args []string, stderr io.Writer) (interface{}, error) {
+	flags := newFlagSet("export", stderr)
+	to := flags.String("to", "", "target directory")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if *to == "" {
+		return nil, usageError("export: -to is required")
+	}
+
+	err = save(svc, *to)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{"exported": *to}, nil
+}
+
+func importCommand(svc *wallet.Service, args []string, stderr io.Writer) (interface{}, error) {
+	flags := newFlagSet("import", stderr)
+	from := flags.String("from", "", "source directory")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if *from == "" {
+		return nil, usageError("import: -from is required")
+	}
+
+	err = svc.Import(*from)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{"imported": *from}, nil
+}
+
+// convertFormatCommand переводит данные между каталогом дампов (dir)
+// и однофайловым форматом ExportToFile/ImportFromFile (file). Каталог данных не используется.
+// Однофайловый формат хранит только аккаунты.
+func convertFormatCommand(args []string, stderr io.Writer) (interface{}, error) {
+	flags := newFlagSet("convert-format", stderr)
+	from := flags.String("from", "", "source format: dir or file")
+	in := flags.String("in", "", "source path")
+	to := flags.String("to", "", "target format: dir or file")
+	out := flags.String("out", "", "target path")
+	err := parseFlags(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if *in == "" || *out == "" {
+		return nil, usageError("convert-format: -in and -out are required")
+	}
+
+	svc := &wallet.Service{}
+	switch *from {
+	case "dir":
+		svc, err = load(*in)
+	case "file":
+		err = svc.ImportFromFile(*in)
+	default:
+		return nil, usageError("convert-format: unknown format %q", *from)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch *to {
+	case "dir":
+		err = save(svc, *out)
+	case "file":
+		err = svc.ExportToFile(*out)
+	default:
+		return nil, usageError("convert-format: unknown format %q", *to)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{"from": *from, "to": *to, "out": *out}, nil
+}
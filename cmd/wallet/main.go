@@ -0,0 +1,101 @@
+// Команда wallet работает с каталогом данных кошелька: загружает его через Import,
+// выполняет подкоманду и сохраняет изменения через Export. Результат выводится в JSON.
+//
+// Использование:
+//
+//	wallet [-dir DIR] [-actor NAME] [-verbose] <command> [flags]
+//
+// Без -verbose журнал библиотеки (например, об отсутствующих необязательных дампах) не выводится,
+// поэтому в stderr попадает только JSON с ошибкой.
+//
+// Команды:
+//
+//	register        -phone PHONE
+//	deposit         -account ID -amount N [-key KEY]
+//	pay             -account ID -amount N -category C [-key KEY]
+//	reject          -payment ID
+//	repeat          -payment ID [-key KEY]
+//	favorite add    -payment ID -name NAME
+//	favorite pay    -id ID [-key KEY]
+//	favorite list   -account ID
+//	favorite delete -id ID
+//	history         -account ID
+//	sum             [-goroutines N]
+//	export          -to DIR
+//	import          -from DIR
+//	convert-format  -from dir|file -in PATH -to dir|file -out PATH
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// errUsage - ошибка в аргументах командной строки.
+var errUsage = errors.New("usage: wallet [-dir DIR] [-actor NAME] [-verbose] <command> [flags]")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run выполняет команду и возвращает код завершения:
+// 0 - успех, 1 - ошибка операции, 2 - ошибка в аргументах.
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("wallet", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dir := flags.String("dir", ".", "wallet data directory")
+	actor := flags.String("actor", "", "actor recorded in the audit log")
+	verbose := flags.Bool("verbose", false, "print library log to stderr")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+
+	log.SetOutput(io.Discard)
+	if *verbose {
+		log.SetOutput(stderr)
+	}
+
+	if flags.NArg() == 0 {
+		writeError(stderr, errUsage)
+		return 2
+	}
+
+	result, err := execute(*dir, *actor, flags.Arg(0), flags.Args()[1:], stderr)
+	if err != nil {
+		writeError(stderr, err)
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		return 1
+	}
+
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(result)
+	if err != nil {
+		writeError(stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+type errorOutput struct {
+	Error string `json:"error"`
+}
+
+func writeError(w io.Writer, err error) {
+	_ = json.NewEncoder(w).Encode(errorOutput{Error: err.Error()})
+}
+
+// usageError сообщает об ошибке в аргументах подкоманды.
+func usageError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: "+format, append([]interface{}{errUsage}, args...)...)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// runJSON выполняет команду и декодирует её вывод в result.
+func runJSON(t *testing.T, result interface{}, args ...string) {
+	t.Helper()
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	code := run(args, stdout, stderr)
+	if code != 0 {
+		t.Fatalf("%v: exit code %v, stderr %v", args, code, stderr)
+	}
+
+	if result != nil {
+		err := json.Unmarshal(stdout.Bytes(), result)
+		if err != nil {
+			t.Fatalf("%v: invalid output %q: %v", args, stdout, err)
+		}
+	}
+}
+
+func TestRun_paymentFlow(t *testing.T) {
+	dir := t.TempDir()
+
+	account := accountOutput{}
+	runJSON(t, &account, "-dir", dir, "register", "-phone", "+992000000001")
+	if account.ID != 1 {
+		t.Fatalf("invalid account: %+v", account)
+	}
+	id := strconv.FormatInt(account.ID, 10)
+
+	runJSON(t, &account, "-dir", dir, "deposit", "-account", id, "-amount", "1000", "-key", "d1")
+	runJSON(t, &account, "-dir", dir, "deposit", "-account", id, "-amount", "1000", "-key", "d1")
+	if account.Balance != 1000 {
+		t.Fatalf("invalid balance after idempotent deposit: %+v", account)
+	}
+
+	payment := paymentOutput{}
+	runJSON(t, &payment, "-dir", dir, "pay", "-account", id, "-amount", "300", "-category", "auto")
+
+	repeated := paymentOutput{}
+	runJSON(t, &repeated, "-dir", dir, "repeat", "-payment", payment.ID)
+
+	rejected := paymentOutput{}
+	runJSON(t, &rejected, "-dir", dir, "-actor", "support", "reject", "-payment", payment.ID)
+	if rejected.Status != "FAIL" {
+		t.Errorf("invalid rejected payment: %+v", rejected)
+	}
+
+	favorite := favoriteOutput{}
+	runJSON(t, &favorite, "-dir", dir, "favorite", "add", "-payment", repeated.ID, "-name", "car")
+	runJSON(t, nil, "-dir", dir, "favorite", "pay", "-id", favorite.ID)
+
+	// list только читает каталог и не перезаписывает его
+	dump := filepath.Join(dir, "favorites.dump")
+	old := time.Date(2021, 3, 15, 10, 0, 0, 0, time.UTC)
+	err := os.Chtimes(dump, old, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	favorites := []favoriteOutput{}
+	runJSON(t, &favorites, "-dir", dir, "favorite", "list", "-account", id)
+	if len(favorites) != 1 || favorites[0].Name != "car" {
+		t.Errorf("invalid favorites: %+v", favorites)
+	}
+
+	info, err := os.Stat(dump)
+	if err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("favorite list must not save the directory: %v, %v", info, err)
+	}
+
+	history := []paymentOutput{}
+	runJSON(t, &history, "-dir", dir, "history", "-account", id)
+	if len(history) != 3 {
+		t.Errorf("invalid history: %+v", history)
+	}
+
+	sum := sumOutput{}
+	runJSON(t, &sum, "-dir", dir, "sum", "-goroutines", "2")
+	if sum.Sum != 900 {
+		t.Errorf("invalid sum, got %v, want 900", sum.Sum)
+	}
+
+	// после перезапуска новый аккаунт получает следующий ID
+	runJSON(t, &account, "-dir", dir, "register", "-phone", "+992000000002")
+	if account.ID != 2 {
+		t.Errorf("invalid id of second account: %+v", account)
+	}
+}
+
+func TestRun_exportImport(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(t.TempDir(), "snapshot")
+
+	account := accountOutput{}
+	runJSON(t, &account, "-dir", dir, "register", "-phone", "+992000000001")
+	runJSON(t, nil, "-dir", dir, "export", "-to", target)
+
+	_, err := os.Stat(filepath.Join(target, "accounts.dump"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := t.TempDir()
+	runJSON(t, nil, "-dir", other, "import", "-from", target)
+	runJSON(t, &account, "-dir", other, "deposit", "-account", "1", "-amount", "50")
+	if account.Balance != 50 || account.Phone != "+992000000001" {
+		t.Errorf("invalid imported account: %+v", account)
+	}
+}
+
+func TestRun_convertFormat(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(t.TempDir(), "accounts.txt")
+
+	runJSON(t, nil, "-dir", dir, "register", "-phone", "+992000000001")
+	runJSON(t, nil, "-dir", dir, "deposit", "-account", "1", "-amount", "70")
+	runJSON(t, nil, "convert-format", "-from", "dir", "-in", dir, "-to", "file", "-out", file)
+
+	converted := filepath.Join(t.TempDir(), "converted")
+	runJSON(t, nil, "convert-format", "-from", "file", "-in", file, "-to", "dir", "-out", converted)
+
+	account := accountOutput{}
+	runJSON(t, &account, "-dir", converted, "deposit", "-account", "1", "-amount", "30")
+	if account.Balance != 100 {
+		t.Errorf("invalid converted account: %+v", account)
+	}
+}
+
+func TestRun_errors(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"no command", []string{"-dir", dir}, 2},
+		{"unknown command", []string{"-dir", dir, "transfer"}, 2},
+		{"unknown flag", []string{"-dir", dir, "pay", "-sum", "1"}, 2},
+		{"unknown favorite action", []string{"-dir", dir, "favorite", "rename"}, 2},
+		{"account not found", []string{"-dir", dir, "deposit", "-account", "1", "-amount", "1"}, 1},
+		{"payment not found", []string{"-dir", dir, "reject", "-payment", "unknown"}, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+			code := run(test.args, stdout, stderr)
+			if code != test.code {
+				t.Fatalf("invalid exit code, got %v, want %v", code, test.code)
+			}
+
+			// flag может вывести справку обычным текстом, но последней строкой всегда идёт JSON с ошибкой
+			lines := bytes.Split(bytes.TrimSpace(stderr.Bytes()), []byte("\n"))
+			output := errorOutput{}
+			err := json.Unmarshal(lines[len(lines)-1], &output)
+			if err != nil || output.Error == "" {
+				t.Errorf("invalid error output: %q", stderr)
+			}
+
+			if stdout.Len() != 0 {
+				t.Errorf("unexpected output: %v", stdout)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+type accountOutput struct {
+	ID        int64               `json:"id"`
+	Phone     types.Phone         `json:"phone"`
+	Balance   types.Money         `json:"balance"`
+	Available types.Money         `json:"available"`
+	Overdraft types.Money         `json:"overdraft"`
+	Tier      types.AccountTier   `json:"tier,omitempty"`
+	Status    types.AccountStatus `json:"status"`
+}
+
+type paymentOutput struct {
+	ID        string                `json:"id"`
+	AccountID int64                 `json:"accountId"`
+	Amount    types.Money           `json:"amount"`
+	Fee       types.Money           `json:"fee"`
+	Category  types.PaymentCategory `json:"category"`
+	Status    types.PaymentStatus   `json:"status"`
+	CreatedAt time.Time             `json:"createdAt"`
+}
+
+type favoriteOutput struct {
+	ID        string                `json:"id"`
+	AccountID int64                 `json:"accountId"`
+	Name      string                `json:"name"`
+	Amount    types.Money           `json:"amount"`
+	Category  types.PaymentCategory `json:"category"`
+}
+
+type sumOutput struct {
+	Sum types.Money `json:"sum"`
+}
+
+func newAccountOutput(account *types.Account) accountOutput {
+	return accountOutput{
+		ID:        account.ID,
+		Phone:     account.Phone,
+		Balance:   account.Balance,
+		Available: account.Available(),
+		Overdraft: account.Overdraft,
+		Tier:      account.Tier,
+		Status:    account.Status,
+	}
+}
+
+func newPaymentOutput(payment types.Payment) paymentOutput {
+	return paymentOutput{
+		ID:        payment.ID,
+		AccountID: payment.AccountID,
+		Amount:    payment.Amount,
+		Fee:       payment.Fee,
+		Category:  payment.Category,
+		Status:    payment.Status,
+		CreatedAt: payment.CreatedAt,
+	}
+}
+
+func newFavoriteOutput(favorite types.Favorite) favoriteOutput {
+	return favoriteOutput{
+		ID:        favorite.ID,
+		AccountID: favorite.AccountID,
+		Name:      favorite.Name,
+		Amount:    favorite.Amount,
+		Category:  favorite.Category,
+	}
+}
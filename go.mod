@@ -2,4 +2,10 @@ module github.com/Ulugbek999/wallet
 
 go 1.16
 
-require github.com/google/uuid v1.2.0 // direct
+require (
+	github.com/google/uuid v1.3.0 // direct
+	go.etcd.io/bbolt v1.3.6
+	google.golang.org/grpc v1.43.0
+	google.golang.org/protobuf v1.27.1
+	modernc.org/sqlite v1.14.6
+)
@@ -0,0 +1,531 @@
+// Package auth реализует аутентификацию по PIN аккаунта или паролю администратора,
+// выдачу токенов сессий и проверку прав на операции с wallet.Service.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+	"github.com/Ulugbek999/wallet/pkg/wallet"
+)
+
+var (
+	ErrUnauthenticated    = errors.New("unauthenticated")
+	ErrForbidden          = errors.New("forbidden")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrCredentialsLocked  = errors.New("credentials locked after too many failed attempts")
+	ErrInvalidPIN         = errors.New("pin must be 4 to 12 digits")
+	ErrInvalidPassword    = errors.New("password must be at least 8 characters")
+	ErrInvalidAdminName   = errors.New("invalid admin name")
+)
+
+// DefaultSessionTTL - время жизни токена, пока не вызван SetSessionTTL.
+const DefaultSessionTTL = 15 * time.Minute
+
+// MaxFailedAttempts - после стольких неверных попыток подряд вход блокируется
+// на время блокировки (SetLockout) или до смены PIN (SetPIN) или пароля (AddAdmin).
+const MaxFailedAttempts = 5
+
+// DefaultLockout - время блокировки входа после MaxFailedAttempts неверных попыток, пока не вызван SetLockout.
+const DefaultLockout = 15 * time.Minute
+
+// hashIterations - число итераций PBKDF2 при хешировании PIN и паролей.
+const hashIterations = 10_000
+
+// Role - роль субъекта.
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
+// Principal - аутентифицированный субъект: владелец аккаунта или администратор.
+type Principal struct {
+	Role      Role
+	AccountID int64
+	// Name - имя администратора; для владельца аккаунта пустое
+	Name string
+}
+
+// Actor возвращает имя субъекта для журнала аудита (wallet.Service.SetActor).
+func (p Principal) Actor() string {
+	if p.Role == RoleAdmin {
+		return "admin:" + p.Name
+	}
+
+	return "account:" + strconv.FormatInt(p.AccountID, 10)
+}
+
+// Action - операция, на которую проверяются права.
+type Action string
+
+const (
+	ActionView    Action = "view"
+	ActionDeposit Action = "deposit"
+	ActionPay     Action = "pay"
+	// ActionManage - изменение избранного, PIN и других настроек аккаунта
+	ActionManage Action = "manage"
+	ActionReject Action = "reject"
+	// ActionReview - просмотр и выпуск платежей, задержанных антифродом
+	ActionReview Action = "review"
+	ActionImport Action = "import"
+	ActionExport Action = "export"
+	// ActionReport - сводные данные по всем аккаунтам (например, сумма всех платежей)
+	ActionReport Action = "report"
+)
+
+// Authorize проверяет, может ли principal выполнить action над аккаунтом accountID.
+// Администратор может всё; владелец - всё, кроме Reject, проверки платежей, Import, Export
+// и сводных отчётов, и только со своим аккаунтом.
+func Authorize(principal Principal, action Action, accountID int64) error {
+	if principal.Role == RoleAdmin {
+		return nil
+	}
+
+	switch action {
+	case ActionReject, ActionReview, ActionImport, ActionExport, ActionReport:
+		return ErrForbidden
+	}
+
+	if principal.Role != RoleUser || principal.AccountID != accountID {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+type credential struct {
+	salt     []byte
+	hash     []byte
+	failures int
+	// lockedUntil - до какого момента вход заблокирован; нулевое значение - не заблокирован
+	lockedUntil time.Time
+}
+
+type session struct {
+	principal Principal
+	expiresAt time.Time
+}
+
+// Authenticator хранит хеши PIN аккаунтов и паролей администраторов и выдаёт токены сессий.
+// Безопасен для конкурентного использования.
+type Authenticator struct {
+	mu       sync.Mutex
+	svc      *wallet.Service
+	pins     map[int64]*credential
+	admins   map[string]*credential
+	sessions map[string]*session
+	ttl      time.Duration
+	lockout  time.Duration
+	clock    func() time.Time
+}
+
+// NewAuthenticator создаёт аутентификатор для аккаунтов svc.
+func NewAuthenticator(svc *wallet.Service) *Authenticator {
+	return &Authenticator{
+		svc:      svc,
+		pins:     map[int64]*credential{},
+		admins:   map[string]*credential{},
+		sessions: map[string]*session{},
+	}
+}
+
+// SetClock подменяет источник текущего времени (для тестов).
+func (a *Authenticator) SetClock(now func() time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.clock = now
+}
+
+// SetSessionTTL задаёт время жизни новых токенов.
+func (a *Authenticator) SetSessionTTL(ttl time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.ttl = ttl
+}
+
+// SetLockout задаёт время блокировки входа после MaxFailedAttempts неверных попыток.
+func (a *Authenticator) SetLockout(lockout time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.lockout = lockout
+}
+
+func (a *Authenticator) now() time.Time {
+	if a.clock == nil {
+		return time.Now()
+	}
+
+	return a.clock()
+}
+
+func (a *Authenticator) sessionTTL() time.Duration {
+	if a.ttl <= 0 {
+		return DefaultSessionTTL
+	}
+
+	return a.ttl
+}
+
+func (a *Authenticator) lockoutDuration() time.Duration {
+	if a.lockout <= 0 {
+		return DefaultLockout
+	}
+
+	return a.lockout
+}
+
+// ValidatePIN проверяет формат PIN: от 4 до 12 цифр.
+func ValidatePIN(pin string) error {
+	if len(pin) < 4 || len(pin) > 12 {
+		return ErrInvalidPIN
+	}
+
+	for _, r := range pin {
+		if r < '0' || r > '9' {
+			return ErrInvalidPIN
+		}
+	}
+
+	return nil
+}
+
+// SetPIN задаёт PIN аккаунта и снимает блокировку после неудачных попыток.
+func (a *Authenticator) SetPIN(accountID int64, pin string) error {
+	err := ValidatePIN(pin)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.svc.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+
+	cred, err := newCredential(pin)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pins[accountID] = cred
+	return nil
+}
+
+// AddAdmin добавляет администратора или меняет его пароль.
+func (a *Authenticator) AddAdmin(name string, password string) error {
+	if name == "" || strings.ContainsAny(name, ";\n") {
+		return ErrInvalidAdminName
+	}
+
+	if len(password) < 8 {
+		return ErrInvalidPassword
+	}
+
+	cred, err := newCredential(password)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.admins[name] = cred
+	return nil
+}
+
+// LoginAccount проверяет PIN и возвращает токен сессии владельца аккаунта.
+// Замороженный или закрытый аккаунт войти не может: возвращается wallet.ErrAccountFrozen или wallet.ErrAccountClosed.
+func (a *Authenticator) LoginAccount(accountID int64, pin string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	err := a.verify(a.pins[accountID], pin)
+	if err != nil {
+		return "", err
+	}
+
+	account, err := a.svc.FindAccountByID(accountID)
+	if err != nil {
+		return "", err
+	}
+
+	switch account.Status {
+	case types.AccountStatusFrozen:
+		return "", wallet.ErrAccountFrozen
+	case types.AccountStatusClosed:
+		return "", wallet.ErrAccountClosed
+	}
+
+	return a.newSession(Principal{Role: RoleUser, AccountID: accountID})
+}
+
+// LoginAdmin проверяет пароль и возвращает токен сессии администратора.
+func (a *Authenticator) LoginAdmin(name string, password string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	err := a.verify(a.admins[name], password)
+	if err != nil {
+		return "", err
+	}
+
+	return a.newSession(Principal{Role: RoleAdmin, Name: name})
+}
+
+// Authenticate возвращает субъекта по токену. Просроченные токены удаляются.
+func (a *Authenticator) Authenticate(token string) (Principal, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := tokenKey(token)
+	current, ok := a.sessions[key]
+	if !ok {
+		return Principal{}, ErrUnauthenticated
+	}
+
+	if !a.now().Before(current.expiresAt) {
+		delete(a.sessions, key)
+		return Principal{}, ErrUnauthenticated
+	}
+
+	return current.principal, nil
+}
+
+// Logout отзывает токен.
+func (a *Authenticator) Logout(token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.sessions, tokenKey(token))
+}
+
+// newSession выдаёт случайный токен. Хранится только его хеш.
+// Заодно удаляются просроченные сессии, к которым больше не обращаются.
+func (a *Authenticator) newSession(principal Principal) (string, error) {
+	a.purgeSessions()
+
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	token := hex.EncodeToString(buf)
+	a.sessions[tokenKey(token)] = &session{
+		principal: principal,
+		expiresAt: a.now().Add(a.sessionTTL()),
+	}
+
+	return token, nil
+}
+
+// purgeSessions удаляет просроченные сессии.
+func (a *Authenticator) purgeSessions() {
+	now := a.now()
+	for key, current := range a.sessions {
+		if !now.Before(current.expiresAt) {
+			delete(a.sessions, key)
+		}
+	}
+}
+
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newCredential(secret string) (*credential, error) {
+	salt := make([]byte, 16)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &credential{salt: salt, hash: hashSecret(secret, salt)}, nil
+}
+
+// verify сравнивает secret с хешем и считает неудачные попытки.
+// После MaxFailedAttempts неудачных попыток подряд вход блокируется на время блокировки.
+func (a *Authenticator) verify(cred *credential, secret string) error {
+	if cred == nil {
+		return ErrInvalidCredentials
+	}
+
+	now := a.now()
+	if now.Before(cred.lockedUntil) {
+		return ErrCredentialsLocked
+	}
+
+	if !hmac.Equal(hashSecret(secret, cred.salt), cred.hash) {
+		cred.failures++
+		if cred.failures >= MaxFailedAttempts {
+			cred.failures = 0
+			cred.lockedUntil = now.Add(a.lockoutDuration())
+		}
+		return ErrInvalidCredentials
+	}
+
+	cred.failures = 0
+	cred.lockedUntil = time.Time{}
+	return nil
+}
+
+// hashSecret - PBKDF2-HMAC-SHA256 с одним блоком результата.
+func hashSecret(secret string, salt []byte) []byte {
+	prf := hmac.New(sha256.New, []byte(secret))
+	prf.Write(salt)
+	prf.Write([]byte{0, 0, 0, 1})
+	u := prf.Sum(nil)
+
+	result := append([]byte{}, u...)
+	for i := 1; i < hashIterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+
+	return result
+}
+
+// credentialsFile - файл, в который Export сохраняет хеши PIN и паролей.
+const credentialsFile = "credentials.dump"
+
+// Export сохраняет хеши PIN аккаунтов и паролей администраторов в dir/credentials.dump.
+// Строки имеют вид "account;id;salt;hash;failures;lockedUntil" и "admin;name;salt;hash;failures;lockedUntil",
+// lockedUntil - время в секундах Unix, 0 - вход не заблокирован.
+// Токены сессий не сохраняются.
+func (a *Authenticator) Export(dir string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	lines := make([]string, 0, len(a.pins)+len(a.admins))
+	for accountID, cred := range a.pins {
+		lines = append(lines, encodeCredential("account", strconv.FormatInt(accountID, 10), cred))
+	}
+	for name, cred := range a.admins {
+		lines = append(lines, encodeCredential("admin", name, cred))
+	}
+	sort.Strings(lines)
+
+	return os.WriteFile(filepath.Join(dir, credentialsFile), []byte(strings.Join(lines, "\n")), 0600)
+}
+
+// Import загружает учётные данные из dir/credentials.dump, заменяя совпадающие.
+// Отсутствие файла не считается ошибкой.
+func (a *Authenticator) Import(dir string) error {
+	data, err := os.ReadFile(filepath.Join(dir, credentialsFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+
+		kind, key, cred, err := decodeCredential(line)
+		if err != nil {
+			return err
+		}
+
+		switch kind {
+		case "account":
+			accountID, err := strconv.ParseInt(key, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid credential %q: %w", line, err)
+			}
+			a.pins[accountID] = cred
+		case "admin":
+			a.admins[key] = cred
+		default:
+			return fmt.Errorf("invalid credential %q", line)
+		}
+	}
+
+	return nil
+}
+
+func encodeCredential(kind string, key string, cred *credential) string {
+	return strings.Join([]string{
+		kind,
+		key,
+		hex.EncodeToString(cred.salt),
+		hex.EncodeToString(cred.hash),
+		strconv.Itoa(cred.failures),
+		encodeLockedUntil(cred.lockedUntil),
+	}, ";")
+}
+
+func decodeCredential(line string) (string, string, *credential, error) {
+	fields := strings.Split(line, ";")
+	if len(fields) != 5 && len(fields) != 6 {
+		return "", "", nil, fmt.Errorf("invalid credential %q", line)
+	}
+
+	salt, err := hex.DecodeString(fields[2])
+	if err != nil {
+		return "", "", nil, fmt.Errorf("invalid credential %q: %w", line, err)
+	}
+
+	hash, err := hex.DecodeString(fields[3])
+	if err != nil {
+		return "", "", nil, fmt.Errorf("invalid credential %q: %w", line, err)
+	}
+
+	failures, err := strconv.Atoi(fields[4])
+	if err != nil {
+		return "", "", nil, fmt.Errorf("invalid credential %q: %w", line, err)
+	}
+
+	cred := &credential{salt: salt, hash: hash, failures: failures}
+
+	// в файлах, сохранённых до появления времени блокировки, его нет
+	if len(fields) == 6 {
+		lockedUntil, err := strconv.ParseInt(fields[5], 10, 64)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("invalid credential %q: %w", line, err)
+		}
+		if lockedUntil != 0 {
+			cred.lockedUntil = time.Unix(lockedUntil, 0)
+		}
+	}
+
+	return fields[0], fields[1], cred, nil
+}
+
+func encodeLockedUntil(lockedUntil time.Time) string {
+	if lockedUntil.IsZero() {
+		return "0"
+	}
+
+	return strconv.FormatInt(lockedUntil.Unix(), 10)
+}
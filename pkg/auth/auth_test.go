@@ -0,0 +1,305 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/wallet"
+)
+
+func newTestAuthenticator(t *testing.T) (*Authenticator, int64) {
+	svc := &wallet.Service{}
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator := NewAuthenticator(svc)
+	err = authenticator.SetPIN(account.ID, "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return authenticator, account.ID
+}
+
+func TestAuthenticator_LoginAccount(t *testing.T) {
+	authenticator, accountID := newTestAuthenticator(t)
+
+	token, err := authenticator.LoginAccount(accountID, "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	principal, err := authenticator.Authenticate(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.Role != RoleUser || principal.AccountID != accountID {
+		t.Errorf("invalid principal: %+v", principal)
+	}
+
+	authenticator.Logout(token)
+	_, err = authenticator.Authenticate(token)
+	if !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("invalid error after logout: %v", err)
+	}
+}
+
+func TestAuthenticator_LoginAccount_inactive(t *testing.T) {
+	authenticator, accountID := newTestAuthenticator(t)
+
+	err := authenticator.svc.FreezeAccount(accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = authenticator.LoginAccount(accountID, "1234")
+	if !errors.Is(err, wallet.ErrAccountFrozen) {
+		t.Errorf("invalid error for frozen account, got %v, want %v", err, wallet.ErrAccountFrozen)
+	}
+
+	err = authenticator.svc.CloseAccount(accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = authenticator.LoginAccount(accountID, "1234")
+	if !errors.Is(err, wallet.ErrAccountClosed) {
+		t.Errorf("invalid error for closed account, got %v, want %v", err, wallet.ErrAccountClosed)
+	}
+}
+
+func TestAuthenticator_SetPIN_invalid(t *testing.T) {
+	authenticator, accountID := newTestAuthenticator(t)
+
+	for _, pin := range []string{"", "123", "12a4", "1234567890123"} {
+		err := authenticator.SetPIN(accountID, pin)
+		if !errors.Is(err, ErrInvalidPIN) {
+			t.Errorf("pin %q: got %v, want %v", pin, err, ErrInvalidPIN)
+		}
+	}
+
+	err := authenticator.SetPIN(accountID+1, "1234")
+	if !errors.Is(err, wallet.ErrAccountNotFound) {
+		t.Errorf("unknown account: got %v, want %v", err, wallet.ErrAccountNotFound)
+	}
+}
+
+func TestAuthenticator_LoginAccount_locked(t *testing.T) {
+	authenticator, accountID := newTestAuthenticator(t)
+
+	for i := 0; i < MaxFailedAttempts; i++ {
+		_, err := authenticator.LoginAccount(accountID, "0000")
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %v: got %v, want %v", i, err, ErrInvalidCredentials)
+		}
+	}
+
+	_, err := authenticator.LoginAccount(accountID, "1234")
+	if !errors.Is(err, ErrCredentialsLocked) {
+		t.Fatalf("got %v, want %v", err, ErrCredentialsLocked)
+	}
+
+	err = authenticator.SetPIN(accountID, "4321")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = authenticator.LoginAccount(accountID, "4321")
+	if err != nil {
+		t.Errorf("login after pin reset: %v", err)
+	}
+}
+
+func TestAuthenticator_LoginAccount_lockoutExpires(t *testing.T) {
+	authenticator, accountID := newTestAuthenticator(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	authenticator.SetClock(func() time.Time { return now })
+	authenticator.SetLockout(time.Minute)
+
+	for i := 0; i < MaxFailedAttempts; i++ {
+		_, _ = authenticator.LoginAccount(accountID, "0000")
+	}
+
+	now = now.Add(time.Minute - time.Second)
+	_, err := authenticator.LoginAccount(accountID, "1234")
+	if !errors.Is(err, ErrCredentialsLocked) {
+		t.Fatalf("got %v, want %v", err, ErrCredentialsLocked)
+	}
+
+	now = now.Add(time.Second)
+	_, err = authenticator.LoginAccount(accountID, "1234")
+	if err != nil {
+		t.Errorf("login after lockout: %v", err)
+	}
+}
+
+func TestAuthenticator_LoginAccount_purgesSessions(t *testing.T) {
+	authenticator, accountID := newTestAuthenticator(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	authenticator.SetClock(func() time.Time { return now })
+	authenticator.SetSessionTTL(time.Minute)
+
+	for i := 0; i < 3; i++ {
+		_, err := authenticator.LoginAccount(accountID, "1234")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now = now.Add(time.Minute)
+	_, err := authenticator.LoginAccount(accountID, "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(authenticator.sessions) != 1 {
+		t.Errorf("expired sessions not purged: %v", len(authenticator.sessions))
+	}
+}
+
+func TestAuthenticator_Authenticate_expired(t *testing.T) {
+	authenticator, accountID := newTestAuthenticator(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	authenticator.SetClock(func() time.Time { return now })
+	authenticator.SetSessionTTL(time.Minute)
+
+	token, err := authenticator.LoginAccount(accountID, "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(time.Minute)
+	_, err = authenticator.Authenticate(token)
+	if !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("got %v, want %v", err, ErrUnauthenticated)
+	}
+}
+
+func TestAuthenticator_LoginAdmin(t *testing.T) {
+	authenticator, _ := newTestAuthenticator(t)
+
+	err := authenticator.AddAdmin("support", "short")
+	if !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("got %v, want %v", err, ErrInvalidPassword)
+	}
+
+	err = authenticator.AddAdmin("support", "secret-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = authenticator.LoginAdmin("support", "wrong-password")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("got %v, want %v", err, ErrInvalidCredentials)
+	}
+
+	token, err := authenticator.LoginAdmin("support", "secret-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	principal, err := authenticator.Authenticate(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.Role != RoleAdmin || principal.Actor() != "admin:support" {
+		t.Errorf("invalid principal: %+v", principal)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	owner := Principal{Role: RoleUser, AccountID: 1}
+	admin := Principal{Role: RoleAdmin, Name: "support"}
+
+	tests := []struct {
+		name      string
+		principal Principal
+		action    Action
+		accountID int64
+		err       error
+	}{
+		{"owner pays", owner, ActionPay, 1, nil},
+		{"owner pays from other account", owner, ActionPay, 2, ErrForbidden},
+		{"owner rejects", owner, ActionReject, 1, ErrForbidden},
+		{"owner imports", owner, ActionImport, 0, ErrForbidden},
+		{"owner approves", owner, ActionReview, 1, ErrForbidden},
+		{"owner sums all payments", owner, ActionReport, 0, ErrForbidden},
+		{"admin rejects", admin, ActionReject, 2, nil},
+		{"admin imports", admin, ActionImport, 0, nil},
+		{"empty principal", Principal{}, ActionView, 0, ErrForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Authorize(test.principal, test.action, test.accountID)
+			if !errors.Is(err, test.err) {
+				t.Errorf("got %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestAuthenticator_ExportImport(t *testing.T) {
+	authenticator, accountID := newTestAuthenticator(t)
+	err := authenticator.AddAdmin("support", "secret-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = authenticator.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported := NewAuthenticator(authenticator.svc)
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = imported.LoginAccount(accountID, "1234")
+	if err != nil {
+		t.Errorf("login after import: %v", err)
+	}
+
+	_, err = imported.LoginAdmin("support", "secret-password")
+	if err != nil {
+		t.Errorf("admin login after import: %v", err)
+	}
+}
+
+func TestAuthenticator_ExportImport_locked(t *testing.T) {
+	authenticator, accountID := newTestAuthenticator(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	authenticator.SetClock(func() time.Time { return now })
+
+	for i := 0; i < MaxFailedAttempts; i++ {
+		_, _ = authenticator.LoginAccount(accountID, "0000")
+	}
+
+	dir := t.TempDir()
+	err := authenticator.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported := NewAuthenticator(authenticator.svc)
+	imported.SetClock(func() time.Time { return now })
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = imported.LoginAccount(accountID, "1234")
+	if !errors.Is(err, ErrCredentialsLocked) {
+		t.Errorf("got %v, want %v", err, ErrCredentialsLocked)
+	}
+
+	now = now.Add(DefaultLockout)
+	_, err = imported.LoginAccount(accountID, "1234")
+	if err != nil {
+		t.Errorf("login after lockout: %v", err)
+	}
+}
@@ -0,0 +1,464 @@
+// Package boltstore реализует wallet.Store во встроенном key-value файле bbolt.
+//
+// Бакеты:
+//
+//	accounts             ID аккаунта (8 байт big-endian) -> JSON types.Account
+//	payments             ID платежа -> JSON types.Payment
+//	payments_by_account  ID аккаунта (8 байт) + ID платежа -> пусто (вторичный индекс)
+//	favorites            ID избранного -> JSON types.Favorite
+//	holds                ID резервирования -> JSON types.Hold
+//	schedules            ID повторяющегося платежа -> JSON wallet.ScheduleRecord
+//	phone_changes        ID аккаунта (8 байт) + время (8 байт) + новый номер -> JSON wallet.PhoneChange
+//	outbox               номер события (8 байт big-endian) -> JSON wallet.OutboxEntry
+//	idempotency          ключ идемпотентности -> JSON wallet.IdempotencyRecord
+//	audit                номер записи (8 байт big-endian) -> JSON wallet.AuditRecord
+package boltstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+	"github.com/Ulugbek999/wallet/pkg/wallet"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	accountsBucket          = []byte("accounts")
+	paymentsBucket          = []byte("payments")
+	paymentsByAccountBucket = []byte("payments_by_account")
+	favoritesBucket         = []byte("favorites")
+	holdsBucket             = []byte("holds")
+	schedulesBucket         = []byte("schedules")
+	phoneChangesBucket      = []byte("phone_changes")
+	outboxBucket            = []byte("outbox")
+	idempotencyBucket       = []byte("idempotency")
+	auditBucket             = []byte("audit")
+)
+
+// openTimeout - сколько ждать блокировки файла, если его держит другой процесс.
+const openTimeout = time.Second
+
+// Store - хранилище аккаунтов, платежей, избранного, резервирований, повторяющихся платежей,
+// истории смен номеров, outbox, ключей идемпотентности и журнала аудита в файле bbolt.
+// Каждый Apply выполняется одной транзакцией записи, поэтому изменения вызова сервиса
+// записываются атомарно и надёжно (fsync при фиксации).
+type Store struct {
+	db *bolt.DB
+}
+
+// Open открывает (или создаёт) файл хранилища path и создаёт недостающие бакеты.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{accountsBucket, paymentsBucket, paymentsByAccountBucket, favoritesBucket, holdsBucket, schedulesBucket,
+			phoneChangesBucket, outboxBucket, idempotencyBucket, auditBucket} {
+			_, err := tx.CreateBucketIfNotExists(name)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close закрывает файл хранилища.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func accountKey(accountID int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(accountID))
+	return key
+}
+
+// seqKey - ключ события outbox или записи аудита: записи идут в порядке номеров.
+func seqKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+// phoneChangeKey - ключ смены номера: смены аккаунта идут подряд в порядке времени.
+func phoneChangeKey(change wallet.PhoneChange) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(change.Time.UnixNano()))
+	return append(append(accountKey(change.AccountID), key...), change.NewPhone...)
+}
+
+// indexKey - ключ платежа во вторичном индексе: платежи аккаунта идут подряд.
+func indexKey(accountID int64, paymentID string) []byte {
+	return append(accountKey(accountID), paymentID...)
+}
+
+// Load загружает все аккаунты, платежи, избранное, резервирования, повторяющиеся платежи,
+// историю смен номеров, outbox, ключи идемпотентности и журнал аудита. Аккаунты упорядочены по ID,
+// платежи, резервирования и смены номеров - по времени, избранное и повторяющиеся платежи - по ID,
+// события и записи аудита - по номеру, ключи идемпотентности - по ключу.
+func (s *Store) Load() (wallet.StoreChanges, error) {
+	result := wallet.StoreChanges{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		err := tx.Bucket(accountsBucket).ForEach(func(key, value []byte) error {
+			account := types.Account{}
+			err := json.Unmarshal(value, &account)
+			result.Accounts = append(result.Accounts, account)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		err = tx.Bucket(paymentsBucket).ForEach(func(key, value []byte) error {
+			payment := types.Payment{}
+			err := json.Unmarshal(value, &payment)
+			result.Payments = append(result.Payments, payment)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		err = tx.Bucket(favoritesBucket).ForEach(func(key, value []byte) error {
+			favorite := types.Favorite{}
+			err := json.Unmarshal(value, &favorite)
+			result.Favorites = append(result.Favorites, favorite)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		err = tx.Bucket(holdsBucket).ForEach(func(key, value []byte) error {
+			hold := types.Hold{}
+			err := json.Unmarshal(value, &hold)
+			result.Holds = append(result.Holds, hold)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		err = tx.Bucket(schedulesBucket).ForEach(func(key, value []byte) error {
+			record := wallet.ScheduleRecord{}
+			err := json.Unmarshal(value, &record)
+			result.Schedules = append(result.Schedules, record)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		err = tx.Bucket(phoneChangesBucket).ForEach(func(key, value []byte) error {
+			change := wallet.PhoneChange{}
+			err := json.Unmarshal(value, &change)
+			result.PhoneChanges = append(result.PhoneChanges, change)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		err = tx.Bucket(outboxBucket).ForEach(func(key, value []byte) error {
+			entry := wallet.OutboxEntry{}
+			err := json.Unmarshal(value, &entry)
+			result.Outbox = append(result.Outbox, entry)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		err = tx.Bucket(idempotencyBucket).ForEach(func(key, value []byte) error {
+			record := wallet.IdempotencyRecord{}
+			err := json.Unmarshal(value, &record)
+			result.Idempotency = append(result.Idempotency, record)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(auditBucket).ForEach(func(key, value []byte) error {
+			record := wallet.AuditRecord{}
+			err := json.Unmarshal(value, &record)
+			result.Audit = append(result.Audit, record)
+			return err
+		})
+	})
+	if err != nil {
+		return result, err
+	}
+
+	sort.SliceStable(result.Payments, func(i, j int) bool {
+		return result.Payments[i].CreatedAt.Before(result.Payments[j].CreatedAt)
+	})
+	sort.SliceStable(result.Holds, func(i, j int) bool {
+		return result.Holds[i].CreatedAt.Before(result.Holds[j].CreatedAt)
+	})
+	sort.SliceStable(result.PhoneChanges, func(i, j int) bool {
+		return result.PhoneChanges[i].Time.Before(result.PhoneChanges[j].Time)
+	})
+
+	return result, nil
+}
+
+// Apply записывает изменения одной транзакцией и поддерживает индекс платежей по аккаунту.
+func (s *Store) Apply(changes wallet.StoreChanges) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		accounts := tx.Bucket(accountsBucket)
+		for _, account := range changes.Accounts {
+			value, err := json.Marshal(account)
+			if err != nil {
+				return err
+			}
+
+			err = accounts.Put(accountKey(account.ID), value)
+			if err != nil {
+				return err
+			}
+		}
+
+		payments := tx.Bucket(paymentsBucket)
+		index := tx.Bucket(paymentsByAccountBucket)
+		for _, payment := range changes.Payments {
+			previous := payments.Get([]byte(payment.ID))
+			if previous != nil {
+				old := types.Payment{}
+				err := json.Unmarshal(previous, &old)
+				if err != nil {
+					return err
+				}
+
+				err = index.Delete(indexKey(old.AccountID, old.ID))
+				if err != nil {
+					return err
+				}
+			}
+
+			value, err := json.Marshal(payment)
+			if err != nil {
+				return err
+			}
+
+			err = payments.Put([]byte(payment.ID), value)
+			if err != nil {
+				return err
+			}
+
+			err = index.Put(indexKey(payment.AccountID, payment.ID), nil)
+			if err != nil {
+				return err
+			}
+		}
+
+		favorites := tx.Bucket(favoritesBucket)
+		for _, favorite := range changes.Favorites {
+			value, err := json.Marshal(favorite)
+			if err != nil {
+				return err
+			}
+
+			err = favorites.Put([]byte(favorite.ID), value)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, id := range changes.DeletedFavorites {
+			err := favorites.Delete([]byte(id))
+			if err != nil {
+				return err
+			}
+		}
+
+		holds := tx.Bucket(holdsBucket)
+		for _, hold := range changes.Holds {
+			value, err := json.Marshal(hold)
+			if err != nil {
+				return err
+			}
+
+			err = holds.Put([]byte(hold.ID), value)
+			if err != nil {
+				return err
+			}
+		}
+
+		schedules := tx.Bucket(schedulesBucket)
+		for _, record := range changes.Schedules {
+			value, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+
+			err = schedules.Put([]byte(record.ID), value)
+			if err != nil {
+				return err
+			}
+		}
+		for _, id := range changes.DeletedSchedules {
+			err := schedules.Delete([]byte(id))
+			if err != nil {
+				return err
+			}
+		}
+
+		phoneChanges := tx.Bucket(phoneChangesBucket)
+		for _, change := range changes.PhoneChanges {
+			value, err := json.Marshal(change)
+			if err != nil {
+				return err
+			}
+
+			err = phoneChanges.Put(phoneChangeKey(change), value)
+			if err != nil {
+				return err
+			}
+		}
+
+		outbox := tx.Bucket(outboxBucket)
+		for _, entry := range changes.Outbox {
+			value, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+
+			err = outbox.Put(seqKey(entry.Event.Seq), value)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, seq := range changes.DeletedOutbox {
+			err := outbox.Delete(seqKey(seq))
+			if err != nil {
+				return err
+			}
+		}
+
+		idempotency := tx.Bucket(idempotencyBucket)
+		for _, record := range changes.Idempotency {
+			value, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+
+			err = idempotency.Put([]byte(record.Key), value)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, key := range changes.DeletedIdempotency {
+			err := idempotency.Delete([]byte(key))
+			if err != nil {
+				return err
+			}
+		}
+
+		audit := tx.Bucket(auditBucket)
+		for _, record := range changes.Audit {
+			value, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+
+			err = audit.Put(seqKey(record.Seq), value)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// PaymentsByAccount возвращает сохранённые платежи аккаунта по вторичному индексу, не просматривая остальные.
+func (s *Store) PaymentsByAccount(accountID int64) ([]types.Payment, error) {
+	result := []types.Payment{}
+	prefix := accountKey(accountID)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		payments := tx.Bucket(paymentsBucket)
+		cursor := tx.Bucket(paymentsByAccountBucket).Cursor()
+		for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+			payment := types.Payment{}
+			err := json.Unmarshal(payments.Get(key[len(prefix):]), &payment)
+			if err != nil {
+				return err
+			}
+			result = append(result, payment)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+// Backup записывает согласованную копию хранилища в w. Копия снимается в транзакции чтения,
+// поэтому сервис продолжает записывать изменения во время резервного копирования.
+func (s *Store) Backup(w io.Writer) (int64, error) {
+	written := int64(0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		written, err = tx.WriteTo(w)
+		return err
+	})
+
+	return written, err
+}
+
+// BackupToFile сохраняет копию хранилища в файл path. Копия пишется во временный файл рядом
+// и переименовывается только после успешной записи, поэтому path всегда содержит целую копию.
+func (s *Store) BackupToFile(path string) (err error) {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(file.Name())
+		}
+	}()
+
+	_, err = s.Backup(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	err = file.Sync()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+var _ wallet.Store = (*Store)(nil)
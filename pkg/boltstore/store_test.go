@@ -0,0 +1,142 @@
+package boltstore
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+	"github.com/Ulugbek999/wallet/pkg/wallet"
+	"github.com/Ulugbek999/wallet/pkg/wallet/wallettest"
+)
+
+// openService открывает сервис с хранилищем в файле path.
+func openService(t *testing.T, path string) (*wallet.Service, *Store) {
+	t.Helper()
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	svc := &wallet.Service{}
+	err = svc.SetStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return svc, store
+}
+
+func TestStore_suite(t *testing.T) {
+	wallettest.Run(t, func(t *testing.T) (*wallet.Service, func() *wallet.Service) {
+		path := filepath.Join(t.TempDir(), "wallet.bolt")
+		svc, store := openService(t, path)
+
+		return svc, func() *wallet.Service {
+			err := store.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			svc, store = openService(t, path)
+			return svc
+		}
+	})
+}
+
+func TestStore_PaymentsByAccount(t *testing.T) {
+	svc, store := openService(t, filepath.Join(t.TempDir(), "wallet.bolt"))
+
+	first, _ := svc.RegisterAccount("+992000000001")
+	second, _ := svc.RegisterAccount("+992000000002")
+	for _, account := range []*types.Account{first, second} {
+		err := svc.Deposit(account.ID, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := svc.Pay(first.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, err := svc.Pay(second.ID, 200, "food")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payments, err := store.PaymentsByAccount(second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payments) != 1 || payments[0].ID != payment.ID || payments[0].Status != types.PaymentStatusFail {
+		t.Errorf("invalid payments of account: %+v", payments)
+	}
+
+	payments, err = store.PaymentsByAccount(second.ID + 1)
+	if err != nil || len(payments) != 0 {
+		t.Errorf("invalid payments of unknown account: %+v, %v", payments, err)
+	}
+}
+
+func TestStore_BackupToFile_online(t *testing.T) {
+	dir := t.TempDir()
+	svc, store := openService(t, filepath.Join(dir, "wallet.bolt"))
+
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Deposit(account.ID, 1_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// сервис продолжает платить, пока снимаются копии
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			_, err := svc.Pay(account.ID, 1, "auto")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	backup := filepath.Join(dir, "backup.bolt")
+	for i := 0; i < 5; i++ {
+		err = store.BackupToFile(backup)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	err = store.BackupToFile(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, _ := openService(t, backup)
+	got, err := restored.FindAccountByID(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Balance != 1_000_000-50 {
+		t.Errorf("invalid balance in backup, got %v, want %v", got.Balance, 1_000_000-50)
+	}
+
+	history, err := restored.ExportAccountHistory(account.ID)
+	if err != nil || len(history) != 50 {
+		t.Errorf("invalid history in backup: %v payments, %v", len(history), err)
+	}
+}
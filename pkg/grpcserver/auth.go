@@ -0,0 +1,240 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/Ulugbek999/wallet/pkg/auth"
+	"github.com/Ulugbek999/wallet/pkg/walletpb"
+)
+
+var errAuthDisabled = errors.New("authentication is not enabled")
+
+type principalKey struct{}
+
+// SetAuthenticator включает аутентификацию для вызовов, прошедших через UnaryInterceptor и StreamInterceptor:
+// все методы, кроме RegisterAccount, CreateSession и DeleteSession, требуют метаданные
+// "authorization: Bearer <token>", а права проверяются через auth.Authorize так же, как в HTTP API.
+// Регистрация аккаунта в этом режиме требует PIN.
+func (s *Server) SetAuthenticator(authenticator *auth.Authenticator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.auth = authenticator
+}
+
+// UnaryInterceptor проверяет токен и права для unary-вызовов:
+//
+//	grpc.NewServer(grpc.UnaryInterceptor(server.UnaryInterceptor()), grpc.StreamInterceptor(server.StreamInterceptor()))
+func (s *Server) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := s.authorize(ctx, request)
+		if err != nil {
+			return nil, statusError(err)
+		}
+
+		return handler(ctx, request)
+	}
+}
+
+// StreamInterceptor проверяет токен и права для потоковых вызовов, когда прочитан запрос.
+func (s *Server) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(server interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(server, &authorizedStream{ServerStream: stream, server: s, ctx: stream.Context()})
+	}
+}
+
+// authorizedStream проверяет права по полученному запросу и отдаёт обработчику контекст с субъектом.
+type authorizedStream struct {
+	grpc.ServerStream
+	server *Server
+	ctx    context.Context
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *authorizedStream) RecvMsg(message interface{}) error {
+	err := s.ServerStream.RecvMsg(message)
+	if err != nil {
+		return err
+	}
+
+	ctx, err := s.server.authorize(s.ServerStream.Context(), message)
+	if err != nil {
+		return statusError(err)
+	}
+
+	s.ctx = ctx
+	return nil
+}
+
+// authorize находит субъекта по токену и проверяет его право на запрос.
+// Возвращает контекст с субъектом для журнала аудита. Без аутентификатора проверка не выполняется.
+func (s *Server) authorize(ctx context.Context, request interface{}) (context.Context, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.auth == nil {
+		return ctx, nil
+	}
+
+	action, accountID, public := s.permission(request)
+	if public {
+		return ctx, nil
+	}
+
+	token, ok := bearerToken(ctx)
+	if !ok {
+		return nil, auth.ErrUnauthenticated
+	}
+
+	principal, err := s.auth.Authenticate(token)
+	if err != nil {
+		return nil, err
+	}
+
+	err = auth.Authorize(principal, action, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	return context.WithValue(ctx, principalKey{}, principal), nil
+}
+
+// permission возвращает право, нужное для запроса, и аккаунт, к которому он относится;
+// public - запрос доступен без токена. Вызывается под блокировкой сервиса.
+// Для неизвестных запросов возвращается пустое право над аккаунтом 0: их выполнит только администратор.
+func (s *Server) permission(request interface{}) (action auth.Action, accountID int64, public bool) {
+	switch request := request.(type) {
+	case *walletpb.RegisterAccountRequest, *walletpb.CreateSessionRequest, *walletpb.DeleteSessionRequest:
+		return "", 0, true
+	case *walletpb.GetAccountRequest:
+		return auth.ActionView, request.AccountId, false
+	case *walletpb.DepositRequest:
+		return auth.ActionDeposit, request.AccountId, false
+	case *walletpb.PayRequest:
+		return auth.ActionPay, request.AccountId, false
+	case *walletpb.GetPaymentRequest:
+		return auth.ActionView, s.paymentOwner(request.PaymentId), false
+	case *walletpb.RejectRequest:
+		return auth.ActionReject, s.paymentOwner(request.PaymentId), false
+	case *walletpb.ApprovePaymentRequest:
+		return auth.ActionReview, s.paymentOwner(request.PaymentId), false
+	case *walletpb.PaymentsInReviewRequest:
+		return auth.ActionReview, 0, false
+	case *walletpb.RepeatRequest:
+		return auth.ActionPay, s.paymentOwner(request.PaymentId), false
+	case *walletpb.FavoritePaymentRequest:
+		return auth.ActionManage, s.paymentOwner(request.PaymentId), false
+	case *walletpb.UpdateFavoriteRequest:
+		return auth.ActionManage, s.favoriteOwner(request.FavoriteId), false
+	case *walletpb.DeleteFavoriteRequest:
+		return auth.ActionManage, s.favoriteOwner(request.FavoriteId), false
+	case *walletpb.ListFavoritesRequest:
+		return auth.ActionView, request.AccountId, false
+	case *walletpb.PayFromFavoriteRequest:
+		return auth.ActionPay, s.favoriteOwner(request.FavoriteId), false
+	case *walletpb.PaymentHistoryRequest:
+		return auth.ActionView, request.AccountId, false
+	case *walletpb.SumPaymentsRequest:
+		return auth.ActionReport, 0, false
+	case *walletpb.ExportRequest:
+		return auth.ActionExport, 0, false
+	case *walletpb.ImportRequest:
+		return auth.ActionImport, 0, false
+	}
+
+	return "", 0, false
+}
+
+func bearerToken(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	const prefix = "Bearer "
+	for _, value := range md.Get("authorization") {
+		if strings.HasPrefix(value, prefix) {
+			return strings.TrimPrefix(value, prefix), true
+		}
+	}
+
+	return "", false
+}
+
+// actor возвращает имя субъекта вызова для журнала аудита.
+func actor(ctx context.Context) string {
+	principal, ok := ctx.Value(principalKey{}).(auth.Principal)
+	if !ok {
+		return ""
+	}
+
+	return principal.Actor()
+}
+
+// paymentOwner возвращает ID аккаунта платежа или 0, если платёж не найден.
+// Тогда владелец аккаунта получит отказ в доступе, а администратор - ErrPaymentNotFound от обработчика.
+func (s *Server) paymentOwner(paymentID string) int64 {
+	payment, err := s.svc.FindPaymentByID(paymentID)
+	if err != nil {
+		return 0
+	}
+
+	return payment.AccountID
+}
+
+// favoriteOwner возвращает ID аккаунта избранного или 0, если оно не найдено.
+func (s *Server) favoriteOwner(favoriteID string) int64 {
+	favorite, err := s.svc.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return 0
+	}
+
+	return favorite.AccountID
+}
+
+// CreateSession выдаёт токен по PIN аккаунта или паролю администратора.
+func (s *Server) CreateSession(ctx context.Context, request *walletpb.CreateSessionRequest) (*walletpb.CreateSessionResponse, error) {
+	defer s.lock(ctx)()
+
+	if s.auth == nil {
+		return nil, statusError(errAuthDisabled)
+	}
+
+	var token string
+	var err error
+	if request.Admin != "" {
+		token, err = s.auth.LoginAdmin(request.Admin, request.Password)
+	} else {
+		token, err = s.auth.LoginAccount(request.AccountId, request.Pin)
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &walletpb.CreateSessionResponse{Token: token}, nil
+}
+
+// DeleteSession отзывает токен из метаданных вызова.
+func (s *Server) DeleteSession(ctx context.Context, request *walletpb.DeleteSessionRequest) (*walletpb.DeleteSessionResponse, error) {
+	defer s.lock(ctx)()
+
+	if s.auth == nil {
+		return nil, statusError(errAuthDisabled)
+	}
+
+	token, ok := bearerToken(ctx)
+	if !ok {
+		return nil, statusError(auth.ErrUnauthenticated)
+	}
+
+	s.auth.Logout(token)
+	return &walletpb.DeleteSessionResponse{}, nil
+}
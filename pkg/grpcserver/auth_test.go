@@ -0,0 +1,159 @@
+package grpcserver
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/Ulugbek999/wallet/pkg/auth"
+	"github.com/Ulugbek999/wallet/pkg/wallet"
+	"github.com/Ulugbek999/wallet/pkg/walletpb"
+)
+
+func newAuthTestClient(t *testing.T) (walletpb.WalletServiceClient, *wallet.Service) {
+	svc := &wallet.Service{}
+	authenticator := auth.NewAuthenticator(svc)
+	err := authenticator.AddAdmin("support", "secret-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(svc, t.TempDir())
+	server.SetAuthenticator(authenticator)
+
+	return dialServer(t, server), svc
+}
+
+// login возвращает контекст с токеном сессии в метаданных.
+func login(t *testing.T, client walletpb.WalletServiceClient, request *walletpb.CreateSessionRequest) context.Context {
+	t.Helper()
+
+	session, err := client.CreateSession(context.Background(), request)
+	if err != nil || session.Token == "" {
+		t.Fatalf("invalid session response: %v, %v", session, err)
+	}
+
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+session.Token)
+}
+
+func TestServer_auth(t *testing.T) {
+	ctx := context.Background()
+	client, svc := newAuthTestClient(t)
+
+	_, err := client.RegisterAccount(ctx, &walletpb.RegisterAccountRequest{Phone: "+992000000001"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("register without pin: got %v, want %v", status.Code(err), codes.InvalidArgument)
+	}
+
+	owner, err := client.RegisterAccount(ctx, &walletpb.RegisterAccountRequest{Phone: "+992000000001", Pin: "1234"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := client.RegisterAccount(ctx, &walletpb.RegisterAccountRequest{Phone: "+992000000002", Pin: "5678"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Deposit(ctx, &walletpb.DepositRequest{AccountId: owner.Id, Amount: 1000})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("deposit without token: got %v, want %v", status.Code(err), codes.Unauthenticated)
+	}
+
+	_, err = client.CreateSession(ctx, &walletpb.CreateSessionRequest{AccountId: owner.Id, Pin: "0000"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("login with wrong pin: got %v, want %v", status.Code(err), codes.Unauthenticated)
+	}
+
+	ownerCtx := login(t, client, &walletpb.CreateSessionRequest{AccountId: owner.Id, Pin: "1234"})
+	otherCtx := login(t, client, &walletpb.CreateSessionRequest{AccountId: other.Id, Pin: "5678"})
+	adminCtx := login(t, client, &walletpb.CreateSessionRequest{Admin: "support", Password: "secret-password"})
+
+	_, err = client.Deposit(ownerCtx, &walletpb.DepositRequest{AccountId: owner.Id, Amount: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := client.Pay(ownerCtx, &walletpb.PayRequest{AccountId: owner.Id, Amount: 100, Category: "auto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Pay(otherCtx, &walletpb.PayRequest{AccountId: owner.Id, Amount: 100, Category: "auto"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("pay from other account: got %v, want %v", status.Code(err), codes.PermissionDenied)
+	}
+
+	_, err = client.GetPayment(otherCtx, &walletpb.GetPaymentRequest{PaymentId: payment.Id})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("other account payment: got %v, want %v", status.Code(err), codes.PermissionDenied)
+	}
+
+	_, err = client.Reject(ownerCtx, &walletpb.RejectRequest{PaymentId: payment.Id})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("reject by owner: got %v, want %v", status.Code(err), codes.PermissionDenied)
+	}
+
+	history, err := client.PaymentHistory(otherCtx, &walletpb.PaymentHistoryRequest{AccountId: owner.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = history.Recv()
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("other account history: got %v, want %v", status.Code(err), codes.PermissionDenied)
+	}
+
+	sum, err := client.SumPaymentsWithProgress(ownerCtx, &walletpb.SumPaymentsRequest{Goroutines: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sum.Recv()
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("sum of all payments by owner: got %v, want %v", status.Code(err), codes.PermissionDenied)
+	}
+
+	_, err = client.Reject(adminCtx, &walletpb.RejectRequest{PaymentId: payment.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err = client.SumPaymentsWithProgress(adminCtx, &walletpb.SumPaymentsRequest{Goroutines: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for err == nil {
+		_, err = sum.Recv()
+	}
+	if err != io.EOF {
+		t.Errorf("sum by admin: %v", err)
+	}
+
+	actors := map[string]string{}
+	for _, record := range svc.AuditLog() {
+		actors[record.Operation] = record.Actor
+	}
+	if actors["Pay"] != "account:1" || actors["Reject"] != "admin:support" {
+		t.Errorf("invalid audit actors: %v", actors)
+	}
+
+	_, err = client.DeleteSession(ownerCtx, &walletpb.DeleteSessionRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.GetAccount(ownerCtx, &walletpb.GetAccountRequest{AccountId: owner.Id})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("get account after logout: got %v, want %v", status.Code(err), codes.Unauthenticated)
+	}
+}
+
+func TestServer_CreateSession_disabled(t *testing.T) {
+	client := newTestClient(t, &wallet.Service{}, t.TempDir())
+
+	_, err := client.CreateSession(context.Background(), &walletpb.CreateSessionRequest{AccountId: 1, Pin: "1234"})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("invalid code without authenticator: %v", err)
+	}
+}
@@ -0,0 +1,461 @@
+// Package grpcserver реализует gRPC-сервис walletpb.WalletService поверх wallet.Service.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/Ulugbek999/wallet/pkg/auth"
+	"github.com/Ulugbek999/wallet/pkg/types"
+	"github.com/Ulugbek999/wallet/pkg/wallet"
+	"github.com/Ulugbek999/wallet/pkg/walletpb"
+)
+
+// Server - реализация walletpb.WalletServiceServer.
+type Server struct {
+	walletpb.UnimplementedWalletServiceServer
+
+	// mu - очередь вызовов Service, не рассчитанного на конкурентные вызовы. Потоковые ответы
+	// передаются без неё, чтобы медленный клиент не задерживал остальные вызовы.
+	mu        sync.Mutex
+	svc       *wallet.Service
+	exportDir string
+	auth      *auth.Authenticator
+}
+
+// NewServer создаёт сервер для svc. Export и Import работают с каталогом exportDir.
+func NewServer(svc *wallet.Service, exportDir string) *Server {
+	return &Server{svc: svc, exportDir: exportDir}
+}
+
+// Code возвращает код статуса gRPC для ошибки сервиса.
+func Code(err error) codes.Code {
+	switch {
+	case err == nil:
+		return codes.OK
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	case errors.Is(err, wallet.ErrAccountNotFound),
+		errors.Is(err, wallet.ErrPaymentNotFound),
+		errors.Is(err, wallet.ErrFavoriteNotFound),
+		errors.Is(err, wallet.ErrFileNotFound):
+		return codes.NotFound
+	case errors.Is(err, wallet.ErrAmountMustBePositive),
+		errors.Is(err, wallet.ErrInvalidPhone),
+		errors.Is(err, wallet.ErrInvalidIdempotencyKey),
+		errors.Is(err, wallet.ErrInvalidText),
+		errors.Is(err, auth.ErrInvalidPIN):
+		return codes.InvalidArgument
+	case errors.Is(err, auth.ErrUnauthenticated),
+		errors.Is(err, auth.ErrInvalidCredentials):
+		return codes.Unauthenticated
+	case errors.Is(err, auth.ErrForbidden):
+		return codes.PermissionDenied
+	case errors.Is(err, errAuthDisabled):
+		return codes.Unimplemented
+	case errors.Is(err, wallet.ErrPhoneNumberRegistred),
+		errors.Is(err, wallet.ErrFavoriteNameTaken):
+		return codes.AlreadyExists
+	case errors.Is(err, wallet.ErrNotEnoughBalance),
+		errors.Is(err, wallet.ErrAccountFrozen),
+		errors.Is(err, wallet.ErrAccountClosed),
+		errors.Is(err, wallet.ErrIdempotencyKeyReused),
+		errors.Is(err, wallet.ErrPaymentBlocked),
+		errors.Is(err, wallet.ErrPaymentNotInReview):
+		return codes.FailedPrecondition
+	case errors.Is(err, wallet.ErrLimitExceeded),
+		errors.Is(err, auth.ErrCredentialsLocked):
+		return codes.ResourceExhausted
+	}
+
+	return codes.Internal
+}
+
+func statusError(err error) error {
+	return status.Error(Code(err), err.Error())
+}
+
+// lock захватывает блокировку сервиса и, если включена аутентификация,
+// записывает субъекта вызова из ctx в журнал аудита. Возвращает функцию, снимающую блокировку.
+func (s *Server) lock(ctx context.Context) func() {
+	s.mu.Lock()
+	if s.auth == nil {
+		return s.mu.Unlock
+	}
+
+	s.svc.SetActor(actor(ctx))
+	return func() {
+		s.svc.SetActor("")
+		s.mu.Unlock()
+	}
+}
+
+func accountToProto(account *types.Account) *walletpb.Account {
+	return &walletpb.Account{
+		Id:        account.ID,
+		Phone:     string(account.Phone),
+		Balance:   int64(account.Balance),
+		Overdraft: int64(account.Overdraft),
+		Tier:      string(account.Tier),
+		Status:    accountStatusToProto(account.Status),
+		Held:      int64(account.Held),
+	}
+}
+
+func accountStatusToProto(accountStatus types.AccountStatus) walletpb.AccountStatus {
+	switch accountStatus {
+	case types.AccountStatusActive, "":
+		return walletpb.AccountStatus_ACCOUNT_STATUS_ACTIVE
+	case types.AccountStatusFrozen:
+		return walletpb.AccountStatus_ACCOUNT_STATUS_FROZEN
+	case types.AccountStatusClosed:
+		return walletpb.AccountStatus_ACCOUNT_STATUS_CLOSED
+	}
+
+	return walletpb.AccountStatus_ACCOUNT_STATUS_UNSPECIFIED
+}
+
+func paymentToProto(payment *types.Payment) *walletpb.Payment {
+	result := &walletpb.Payment{
+		Id:        payment.ID,
+		AccountId: payment.AccountID,
+		Amount:    int64(payment.Amount),
+		Category:  string(payment.Category),
+		Status:    paymentStatusToProto(payment.Status),
+		Fee:       int64(payment.Fee),
+	}
+	if !payment.CreatedAt.IsZero() {
+		result.CreatedAt = timestamppb.New(payment.CreatedAt)
+	}
+
+	return result
+}
+
+func paymentStatusToProto(paymentStatus types.PaymentStatus) walletpb.PaymentStatus {
+	switch paymentStatus {
+	case types.PaymentStatusOk:
+		return walletpb.PaymentStatus_PAYMENT_STATUS_OK
+	case types.PaymentStatusFail:
+		return walletpb.PaymentStatus_PAYMENT_STATUS_FAIL
+	case types.PaymentStatusInProgress:
+		return walletpb.PaymentStatus_PAYMENT_STATUS_IN_PROGRESS
+	case types.PaymentStatusReview:
+		return walletpb.PaymentStatus_PAYMENT_STATUS_REVIEW
+	}
+
+	return walletpb.PaymentStatus_PAYMENT_STATUS_UNSPECIFIED
+}
+
+func favoriteToProto(favorite *types.Favorite) *walletpb.Favorite {
+	return &walletpb.Favorite{
+		Id:        favorite.ID,
+		AccountId: favorite.AccountID,
+		Name:      favorite.Name,
+		Amount:    int64(favorite.Amount),
+		Category:  string(favorite.Category),
+	}
+}
+
+func progressToProto(progress types.Progress) *walletpb.Progress {
+	return &walletpb.Progress{
+		Part:      int32(progress.Part),
+		Result:    int64(progress.Result),
+		Processed: int64(progress.Processed),
+		Total:     int64(progress.Total),
+		Eta:       durationpb.New(progress.ETA),
+	}
+}
+
+func (s *Server) RegisterAccount(ctx context.Context, request *walletpb.RegisterAccountRequest) (*walletpb.Account, error) {
+	defer s.lock(ctx)()
+
+	if s.auth != nil {
+		err := auth.ValidatePIN(request.Pin)
+		if err != nil {
+			return nil, statusError(err)
+		}
+	}
+
+	account, err := s.svc.RegisterAccount(types.Phone(request.Phone))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	if s.auth != nil {
+		err = s.auth.SetPIN(account.ID, request.Pin)
+		if err != nil {
+			return nil, statusError(err)
+		}
+	}
+
+	return accountToProto(account), nil
+}
+
+func (s *Server) GetAccount(ctx context.Context, request *walletpb.GetAccountRequest) (*walletpb.Account, error) {
+	defer s.lock(ctx)()
+
+	account, err := s.svc.FindAccountByID(request.AccountId)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return accountToProto(account), nil
+}
+
+func (s *Server) Deposit(ctx context.Context, request *walletpb.DepositRequest) (*walletpb.Account, error) {
+	defer s.lock(ctx)()
+
+	var err error
+	if request.IdempotencyKey != "" {
+		err = s.svc.DepositIdempotent(request.IdempotencyKey, request.AccountId, types.Money(request.Amount))
+	} else {
+		err = s.svc.Deposit(request.AccountId, types.Money(request.Amount))
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	account, err := s.svc.FindAccountByID(request.AccountId)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return accountToProto(account), nil
+}
+
+func (s *Server) Pay(ctx context.Context, request *walletpb.PayRequest) (*walletpb.Payment, error) {
+	defer s.lock(ctx)()
+
+	var payment *types.Payment
+	var err error
+	if request.IdempotencyKey != "" {
+		payment, err = s.svc.PayIdempotent(request.IdempotencyKey, request.AccountId, types.Money(request.Amount), types.PaymentCategory(request.Category))
+	} else {
+		payment, err = s.svc.Pay(request.AccountId, types.Money(request.Amount), types.PaymentCategory(request.Category))
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return paymentToProto(payment), nil
+}
+
+func (s *Server) GetPayment(ctx context.Context, request *walletpb.GetPaymentRequest) (*walletpb.Payment, error) {
+	defer s.lock(ctx)()
+
+	payment, err := s.svc.FindPaymentByID(request.PaymentId)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return paymentToProto(payment), nil
+}
+
+func (s *Server) Reject(ctx context.Context, request *walletpb.RejectRequest) (*walletpb.Payment, error) {
+	defer s.lock(ctx)()
+
+	err := s.svc.Reject(request.PaymentId)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	payment, err := s.svc.FindPaymentByID(request.PaymentId)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return paymentToProto(payment), nil
+}
+
+func (s *Server) ApprovePayment(ctx context.Context, request *walletpb.ApprovePaymentRequest) (*walletpb.Payment, error) {
+	defer s.lock(ctx)()
+
+	err := s.svc.ApprovePayment(request.PaymentId)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	payment, err := s.svc.FindPaymentByID(request.PaymentId)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return paymentToProto(payment), nil
+}
+
+func (s *Server) PaymentsInReview(ctx context.Context, request *walletpb.PaymentsInReviewRequest) (*walletpb.PaymentsInReviewResponse, error) {
+	defer s.lock(ctx)()
+
+	payments := s.svc.PaymentsInReview()
+	response := &walletpb.PaymentsInReviewResponse{}
+	for i := range payments {
+		response.Payments = append(response.Payments, paymentToProto(&payments[i]))
+	}
+
+	return response, nil
+}
+
+func (s *Server) Repeat(ctx context.Context, request *walletpb.RepeatRequest) (*walletpb.Payment, error) {
+	defer s.lock(ctx)()
+
+	var payment *types.Payment
+	var err error
+	if request.IdempotencyKey != "" {
+		payment, err = s.svc.RepeatIdempotent(request.IdempotencyKey, request.PaymentId)
+	} else {
+		payment, err = s.svc.Repeat(request.PaymentId)
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return paymentToProto(payment), nil
+}
+
+func (s *Server) FavoritePayment(ctx context.Context, request *walletpb.FavoritePaymentRequest) (*walletpb.Favorite, error) {
+	defer s.lock(ctx)()
+
+	favorite, err := s.svc.FavoritePayment(request.PaymentId, request.Name)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return favoriteToProto(favorite), nil
+}
+
+func (s *Server) UpdateFavorite(ctx context.Context, request *walletpb.UpdateFavoriteRequest) (*walletpb.Favorite, error) {
+	defer s.lock(ctx)()
+
+	favorite, err := s.svc.UpdateFavorite(request.FavoriteId, request.Name, types.Money(request.Amount))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return favoriteToProto(favorite), nil
+}
+
+func (s *Server) DeleteFavorite(ctx context.Context, request *walletpb.DeleteFavoriteRequest) (*walletpb.DeleteFavoriteResponse, error) {
+	defer s.lock(ctx)()
+
+	err := s.svc.DeleteFavorite(request.FavoriteId)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &walletpb.DeleteFavoriteResponse{}, nil
+}
+
+func (s *Server) ListFavorites(ctx context.Context, request *walletpb.ListFavoritesRequest) (*walletpb.ListFavoritesResponse, error) {
+	defer s.lock(ctx)()
+
+	favorites, err := s.svc.FavoritesForAccount(request.AccountId)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	response := &walletpb.ListFavoritesResponse{}
+	for i := range favorites {
+		response.Favorites = append(response.Favorites, favoriteToProto(&favorites[i]))
+	}
+
+	return response, nil
+}
+
+func (s *Server) PayFromFavorite(ctx context.Context, request *walletpb.PayFromFavoriteRequest) (*walletpb.Payment, error) {
+	defer s.lock(ctx)()
+
+	var payment *types.Payment
+	var err error
+	if request.IdempotencyKey != "" {
+		payment, err = s.svc.PayFromFavoriteIdempotent(request.IdempotencyKey, request.FavoriteId)
+	} else {
+		payment, err = s.svc.PayFromFavorite(request.FavoriteId)
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return paymentToProto(payment), nil
+}
+
+// PaymentHistory копирует историю под блокировкой и передаёт её уже без блокировки.
+func (s *Server) PaymentHistory(request *walletpb.PaymentHistoryRequest, stream walletpb.WalletService_PaymentHistoryServer) error {
+	unlock := s.lock(stream.Context())
+	payments, err := s.svc.ExportAccountHistory(request.AccountId)
+	unlock()
+	if err != nil && !errors.Is(err, wallet.ErrPaymentNotFound) {
+		return statusError(err)
+	}
+
+	for i := range payments {
+		err = stream.Send(paymentToProto(&payments[i]))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SumPaymentsWithProgress передаёт отчёт о каждой части суммирования, как только она посчитана.
+// Суммируется копия платежей, снятая под блокировкой.
+// Если клиент отключился, суммирование прерывается.
+func (s *Server) SumPaymentsWithProgress(request *walletpb.SumPaymentsRequest, stream walletpb.WalletService_SumPaymentsWithProgressServer) error {
+	unlock := s.lock(stream.Context())
+	snapshot := s.svc.SnapshotPayments()
+	unlock()
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	// вызовы fn сериализованы, поэтому Send не выполняется конкурентно
+	var sendErr error
+	_, err := snapshot.SumWithProgressFunc(ctx, int(request.Goroutines), func(progress types.Progress) {
+		if sendErr != nil {
+			return
+		}
+
+		sendErr = stream.Send(progressToProto(progress))
+		if sendErr != nil {
+			cancel()
+		}
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		return statusError(err)
+	}
+
+	return nil
+}
+
+func (s *Server) Export(ctx context.Context, request *walletpb.ExportRequest) (*walletpb.ExportResponse, error) {
+	defer s.lock(ctx)()
+
+	err := s.svc.ExportWithProgress(ctx, s.exportDir, nil)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &walletpb.ExportResponse{}, nil
+}
+
+func (s *Server) Import(ctx context.Context, request *walletpb.ImportRequest) (*walletpb.ImportResponse, error) {
+	defer s.lock(ctx)()
+
+	err := s.svc.ImportWithProgress(ctx, s.exportDir, nil)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &walletpb.ImportResponse{}, nil
+}
@@ -0,0 +1,386 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+	"github.com/Ulugbek999/wallet/pkg/wallet"
+	"github.com/Ulugbek999/wallet/pkg/walletpb"
+)
+
+func newTestClient(t *testing.T, svc *wallet.Service, dir string) walletpb.WalletServiceClient {
+	return dialServer(t, NewServer(svc, dir))
+}
+
+// dialServer запускает walletServer с проверкой прав в памяти и возвращает клиента к нему.
+func dialServer(t *testing.T, walletServer *Server) walletpb.WalletServiceClient {
+	listener := bufconn.Listen(1024 * 1024)
+
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(walletServer.UnaryInterceptor()),
+		grpc.StreamInterceptor(walletServer.StreamInterceptor()),
+	)
+	walletpb.RegisterWalletServiceServer(server, walletServer)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, address string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+
+	return walletpb.NewWalletServiceClient(conn)
+}
+
+func TestServer_paymentFlow(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, &wallet.Service{}, t.TempDir())
+
+	account, err := client.RegisterAccount(ctx, &walletpb.RegisterAccountRequest{Phone: "+992000000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Status != walletpb.AccountStatus_ACCOUNT_STATUS_ACTIVE {
+		t.Errorf("invalid account status: %v", account.Status)
+	}
+
+	account, err = client.Deposit(ctx, &walletpb.DepositRequest{AccountId: account.Id, Amount: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 1000 {
+		t.Errorf("invalid balance: %v", account.Balance)
+	}
+
+	payment, err := client.Pay(ctx, &walletpb.PayRequest{AccountId: account.Id, Amount: 300, Category: "auto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Amount != 300 || payment.CreatedAt == nil {
+		t.Errorf("invalid payment: %v", payment)
+	}
+
+	repeated, err := client.Repeat(ctx, &walletpb.RepeatRequest{PaymentId: payment.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rejected, err := client.Reject(ctx, &walletpb.RejectRequest{PaymentId: payment.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rejected.Status != walletpb.PaymentStatus_PAYMENT_STATUS_FAIL {
+		t.Errorf("invalid rejected status: %v", rejected.Status)
+	}
+
+	favorite, err := client.FavoritePayment(ctx, &walletpb.FavoritePaymentRequest{PaymentId: repeated.Id, Name: "car"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.PayFromFavorite(ctx, &walletpb.PayFromFavoriteRequest{FavoriteId: favorite.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	favorites, err := client.ListFavorites(ctx, &walletpb.ListFavoritesRequest{AccountId: account.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(favorites.Favorites) != 1 || favorites.Favorites[0].Name != "car" {
+		t.Errorf("invalid favorites: %v", favorites.Favorites)
+	}
+
+	account, err = client.GetAccount(ctx, &walletpb.GetAccountRequest{AccountId: account.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 400 {
+		t.Errorf("invalid balance, got %v, want 400", account.Balance)
+	}
+}
+
+func TestServer_statusCodes(t *testing.T) {
+	ctx := context.Background()
+	svc := &wallet.Service{}
+	client := newTestClient(t, svc, filepath.Join(t.TempDir(), "missing"))
+
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		code codes.Code
+	}{
+		{"account not found", func() error {
+			_, err := client.GetAccount(ctx, &walletpb.GetAccountRequest{AccountId: 99})
+			return err
+		}, codes.NotFound},
+		{"payment not found", func() error {
+			_, err := client.Reject(ctx, &walletpb.RejectRequest{PaymentId: "unknown"})
+			return err
+		}, codes.NotFound},
+		{"phone registered", func() error {
+			_, err := client.RegisterAccount(ctx, &walletpb.RegisterAccountRequest{Phone: "+992000000001"})
+			return err
+		}, codes.AlreadyExists},
+		{"invalid amount", func() error {
+			_, err := client.Deposit(ctx, &walletpb.DepositRequest{AccountId: account.ID, Amount: -1})
+			return err
+		}, codes.InvalidArgument},
+		{"not enough balance", func() error {
+			_, err := client.Pay(ctx, &walletpb.PayRequest{AccountId: account.ID, Amount: 100, Category: "auto"})
+			return err
+		}, codes.FailedPrecondition},
+		{"import without dir", func() error {
+			_, err := client.Import(ctx, &walletpb.ImportRequest{})
+			return err
+		}, codes.NotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.call()
+			if status.Code(err) != test.code {
+				t.Errorf("invalid code, got %v (%v), want %v", status.Code(err), err, test.code)
+			}
+		})
+	}
+}
+
+func TestServer_PaymentHistory(t *testing.T) {
+	ctx := context.Background()
+	svc := &wallet.Service{}
+	client := newTestClient(t, svc, t.TempDir())
+
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []string{}
+	for i := 0; i < 5; i++ {
+		payment, err := svc.Pay(account.ID, types.Money(10*(i+1)), "auto")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, payment.ID)
+	}
+
+	stream, err := client.PaymentHistory(ctx, &walletpb.PaymentHistoryRequest{AccountId: account.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := []string{}
+	for {
+		payment, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		received = append(received, payment.Id)
+	}
+
+	if len(received) != len(ids) {
+		t.Fatalf("invalid history size, got %v, want %v", len(received), len(ids))
+	}
+	for i := range ids {
+		if received[i] != ids[i] {
+			t.Errorf("payment %v: got %v, want %v", i, received[i], ids[i])
+		}
+	}
+
+	stream, err = client.PaymentHistory(ctx, &walletpb.PaymentHistoryRequest{AccountId: 99})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = stream.Recv()
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("invalid code for unknown account: %v", err)
+	}
+}
+
+func TestServer_SumPaymentsWithProgress(t *testing.T) {
+	ctx := context.Background()
+	svc := &wallet.Service{}
+	client := newTestClient(t, svc, t.TempDir())
+
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Deposit(account.ID, 1_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := int64(0)
+	for i := 0; i < 100; i++ {
+		_, err = svc.Pay(account.ID, types.Money(i+1), "auto")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want += int64(i + 1)
+	}
+
+	stream, err := client.SumPaymentsWithProgress(ctx, &walletpb.SumPaymentsRequest{Goroutines: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := int64(0)
+	reports := []*walletpb.Progress{}
+	for {
+		progress, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		reports = append(reports, progress)
+		sum += progress.Result
+	}
+
+	if len(reports) != 4 || reports[3].Processed != 100 || reports[3].Total != 100 {
+		t.Errorf("invalid progress reports: %v", reports)
+	}
+
+	if sum != want {
+		t.Errorf("invalid sum, got %v, want %v", sum, want)
+	}
+}
+
+func TestServer_ExportImport(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	svc := &wallet.Service{}
+	client := newTestClient(t, svc, dir)
+
+	account, err := client.RegisterAccount(ctx, &walletpb.RegisterAccountRequest{Phone: "+992000000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Deposit(ctx, &walletpb.DepositRequest{AccountId: account.Id, Amount: 500, IdempotencyKey: "deposit-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Pay(ctx, &walletpb.PayRequest{AccountId: account.Id, Amount: 100, Category: "auto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Export(ctx, &walletpb.ExportRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newClient := newTestClient(t, &wallet.Service{}, dir)
+	_, err = newClient.Import(ctx, &walletpb.ImportRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// повтор пополнения с тем же ключом после импорта не меняет баланс
+	imported, err := newClient.Deposit(ctx, &walletpb.DepositRequest{AccountId: account.Id, Amount: 500, IdempotencyKey: "deposit-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.Balance != 400 {
+		t.Errorf("invalid imported balance, got %v, want 400", imported.Balance)
+	}
+}
+
+func TestServer_review(t *testing.T) {
+	ctx := context.Background()
+	svc := &wallet.Service{}
+	svc.SetRiskRules(wallet.UnusualAmountRule(5, 1))
+	client := newTestClient(t, svc, t.TempDir())
+
+	account, err := client.RegisterAccount(ctx, &walletpb.RegisterAccountRequest{Phone: "+992000000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Deposit(ctx, &walletpb.DepositRequest{AccountId: account.Id, Amount: 10_000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Pay(ctx, &walletpb.PayRequest{AccountId: account.Id, Amount: 100, Category: "auto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := client.Pay(ctx, &walletpb.PayRequest{AccountId: account.Id, Amount: 600, Category: "auto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Status != walletpb.PaymentStatus_PAYMENT_STATUS_REVIEW {
+		t.Fatalf("invalid payment status: %v", payment.Status)
+	}
+
+	review, err := client.PaymentsInReview(ctx, &walletpb.PaymentsInReviewRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(review.Payments) != 1 || review.Payments[0].Id != payment.Id {
+		t.Errorf("invalid payments in review: %v", review.Payments)
+	}
+
+	approved, err := client.ApprovePayment(ctx, &walletpb.ApprovePaymentRequest{PaymentId: payment.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approved.Status != walletpb.PaymentStatus_PAYMENT_STATUS_IN_PROGRESS {
+		t.Errorf("invalid approved status: %v", approved.Status)
+	}
+
+	_, err = client.ApprovePayment(ctx, &walletpb.ApprovePaymentRequest{PaymentId: payment.Id})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("invalid code for repeated approve: %v", err)
+	}
+
+	review, err = client.PaymentsInReview(ctx, &walletpb.PaymentsInReviewRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(review.Payments) != 0 {
+		t.Errorf("invalid payments in review after approve: %v", review.Payments)
+	}
+}
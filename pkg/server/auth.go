@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/Ulugbek999/wallet/pkg/auth"
+)
+
+type principalKey struct{}
+
+type sessionRequest struct {
+	AccountID int64  `json:"accountId,omitempty"`
+	PIN       string `json:"pin,omitempty"`
+	Admin     string `json:"admin,omitempty"`
+	Password  string `json:"password,omitempty"`
+}
+
+type sessionResponse struct {
+	Token string `json:"token"`
+}
+
+type pinRequest struct {
+	PIN string `json:"pin"`
+}
+
+// SetAuthenticator включает аутентификацию: все маршруты, кроме POST /accounts и POST /sessions,
+// требуют заголовок "Authorization: Bearer <token>", а права проверяются через auth.Authorize.
+// Регистрация аккаунта в этом режиме требует PIN.
+func (s *Server) SetAuthenticator(authenticator *auth.Authenticator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.auth = authenticator
+}
+
+// authenticate находит субъекта по токену из заголовка Authorization и сохраняет его в контексте запроса.
+// Запрос без заголовка проходит дальше без субъекта: его отклонит guard защищённого маршрута.
+func (s *Server) authenticate(r *http.Request) (*http.Request, error) {
+	if s.auth == nil {
+		return r, nil
+	}
+
+	token, ok := bearerToken(r)
+	if !ok {
+		return r, nil
+	}
+
+	principal, err := s.auth.Authenticate(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)), nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if !strings.HasPrefix(header, prefix) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(header, prefix), true
+}
+
+// actor возвращает имя субъекта запроса для журнала аудита.
+func actor(r *http.Request) string {
+	principal, ok := r.Context().Value(principalKey{}).(auth.Principal)
+	if !ok {
+		return ""
+	}
+
+	return principal.Actor()
+}
+
+// guard оборачивает handler проверкой права на action над аккаунтом accountID.
+// Без аутентификатора проверка не выполняется.
+func (s *Server) guard(action auth.Action, accountID int64, handler func(r *http.Request) (int, interface{}, error)) func(r *http.Request) (int, interface{}, error) {
+	return func(r *http.Request) (int, interface{}, error) {
+		if s.auth != nil {
+			principal, ok := r.Context().Value(principalKey{}).(auth.Principal)
+			if !ok {
+				return 0, nil, auth.ErrUnauthenticated
+			}
+
+			err := auth.Authorize(principal, action, accountID)
+			if err != nil {
+				return 0, nil, err
+			}
+		}
+
+		return handler(r)
+	}
+}
+
+// paymentOwner возвращает ID аккаунта платежа или 0, если платёж не найден.
+// Тогда владелец аккаунта получит отказ в доступе, а администратор - ErrPaymentNotFound от обработчика.
+func (s *Server) paymentOwner(paymentID string) int64 {
+	payment, err := s.svc.FindPaymentByID(paymentID)
+	if err != nil {
+		return 0
+	}
+
+	return payment.AccountID
+}
+
+// favoriteOwner возвращает ID аккаунта избранного или 0, если оно не найдено.
+func (s *Server) favoriteOwner(favoriteID string) int64 {
+	favorite, err := s.svc.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return 0
+	}
+
+	return favorite.AccountID
+}
+
+// routeSessions выдаёт токен (POST) по PIN аккаунта или паролю администратора и отзывает его (DELETE).
+func (s *Server) routeSessions(r *http.Request) (int, interface{}, error) {
+	if s.auth == nil {
+		return 0, nil, errRouteNotFound
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		request := sessionRequest{}
+		err := readJSON(r, &request)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		var token string
+		if request.Admin != "" {
+			token, err = s.auth.LoginAdmin(request.Admin, request.Password)
+		} else {
+			token, err = s.auth.LoginAccount(request.AccountID, request.PIN)
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+
+		return http.StatusCreated, sessionResponse{Token: token}, nil
+	case http.MethodDelete:
+		token, ok := bearerToken(r)
+		if !ok {
+			return 0, nil, auth.ErrUnauthenticated
+		}
+
+		s.auth.Logout(token)
+		return http.StatusNoContent, nil, nil
+	}
+
+	return 0, nil, errMethodNotAllowed
+}
+
+func (s *Server) setPIN(r *http.Request, accountID int64) (int, interface{}, error) {
+	if s.auth == nil {
+		return 0, nil, errRouteNotFound
+	}
+
+	request := pinRequest{}
+	err := readJSON(r, &request)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	err = s.auth.SetPIN(accountID, request.PIN)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusNoContent, nil, nil
+}
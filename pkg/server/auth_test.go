@@ -0,0 +1,132 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Ulugbek999/wallet/pkg/auth"
+	"github.com/Ulugbek999/wallet/pkg/wallet"
+)
+
+func newAuthTestClient(t *testing.T) (*testClient, *wallet.Service) {
+	svc := &wallet.Service{}
+	authenticator := auth.NewAuthenticator(svc)
+	err := authenticator.AddAdmin("support", "secret-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(svc, t.TempDir())
+	server.SetAuthenticator(authenticator)
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	return &testClient{t: t, url: ts.URL}, svc
+}
+
+// login возвращает заголовки с токеном сессии.
+func (c *testClient) login(request sessionRequest) map[string]string {
+	c.t.Helper()
+
+	session := sessionResponse{}
+	status := c.do(http.MethodPost, "/sessions", nil, request, &session)
+	if status != http.StatusCreated || session.Token == "" {
+		c.t.Fatalf("invalid session response: %v %+v", status, session)
+	}
+
+	return map[string]string{"Authorization": "Bearer " + session.Token}
+}
+
+func TestServer_auth(t *testing.T) {
+	client, _ := newAuthTestClient(t)
+
+	status := client.do(http.MethodPost, "/accounts", nil, registerRequest{Phone: "+992000000001"}, &errorResponse{})
+	if status != http.StatusBadRequest {
+		t.Fatalf("register without pin: got %v, want %v", status, http.StatusBadRequest)
+	}
+
+	owner := accountResponse{}
+	client.do(http.MethodPost, "/accounts", nil, registerRequest{Phone: "+992000000001", PIN: "1234"}, &owner)
+	other := accountResponse{}
+	client.do(http.MethodPost, "/accounts", nil, registerRequest{Phone: "+992000000002", PIN: "5678"}, &other)
+
+	ownerPath := fmt.Sprintf("/accounts/%v", owner.ID)
+	status = client.do(http.MethodPost, ownerPath+"/deposit", nil, depositRequest{Amount: 1000}, &errorResponse{})
+	if status != http.StatusUnauthorized {
+		t.Fatalf("deposit without token: got %v, want %v", status, http.StatusUnauthorized)
+	}
+
+	status = client.do(http.MethodPost, "/sessions", nil, sessionRequest{AccountID: owner.ID, PIN: "0000"}, &errorResponse{})
+	if status != http.StatusUnauthorized {
+		t.Fatalf("login with wrong pin: got %v, want %v", status, http.StatusUnauthorized)
+	}
+
+	ownerHeaders := client.login(sessionRequest{AccountID: owner.ID, PIN: "1234"})
+	otherHeaders := client.login(sessionRequest{AccountID: other.ID, PIN: "5678"})
+	adminHeaders := client.login(sessionRequest{Admin: "support", Password: "secret-password"})
+
+	status = client.do(http.MethodPost, ownerPath+"/deposit", ownerHeaders, depositRequest{Amount: 1000}, &owner)
+	if status != http.StatusOK || owner.Balance != 1000 {
+		t.Fatalf("invalid deposit response: %v %+v", status, owner)
+	}
+
+	payment := paymentResponse{}
+	status = client.do(http.MethodPost, ownerPath+"/payments", ownerHeaders, payRequest{Amount: 300, Category: "auto"}, &payment)
+	if status != http.StatusCreated {
+		t.Fatalf("invalid pay response: %v %+v", status, payment)
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		headers map[string]string
+		body    interface{}
+		status  int
+	}{
+		{"pay from other account", http.MethodPost, ownerPath + "/payments", otherHeaders, payRequest{Amount: 1, Category: "auto"}, http.StatusForbidden},
+		{"view other payment", http.MethodGet, "/payments/" + payment.ID, otherHeaders, nil, http.StatusForbidden},
+		{"owner reject", http.MethodPost, "/payments/" + payment.ID + "/reject", ownerHeaders, nil, http.StatusForbidden},
+		{"owner import", http.MethodPost, "/import", ownerHeaders, nil, http.StatusForbidden},
+		{"owner approve", http.MethodPost, "/payments/" + payment.ID + "/approve", ownerHeaders, nil, http.StatusForbidden},
+		{"owner review", http.MethodGet, "/review", ownerHeaders, nil, http.StatusForbidden},
+		{"invalid token", http.MethodGet, ownerPath, map[string]string{"Authorization": "Bearer unknown"}, nil, http.StatusUnauthorized},
+		{"admin reject", http.MethodPost, "/payments/" + payment.ID + "/reject", adminHeaders, nil, http.StatusOK},
+		{"admin export", http.MethodPost, "/export", adminHeaders, nil, http.StatusNoContent},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var result interface{}
+			if test.status != http.StatusNoContent {
+				result = &map[string]interface{}{}
+			}
+
+			status := client.do(test.method, test.path, test.headers, test.body, result)
+			if status != test.status {
+				t.Errorf("invalid status, got %v, want %v", status, test.status)
+			}
+		})
+	}
+
+	status = client.do(http.MethodDelete, "/sessions", ownerHeaders, nil, nil)
+	if status != http.StatusNoContent {
+		t.Fatalf("invalid logout status: %v", status)
+	}
+
+	status = client.do(http.MethodGet, ownerPath, ownerHeaders, nil, &errorResponse{})
+	if status != http.StatusUnauthorized {
+		t.Errorf("request after logout: got %v, want %v", status, http.StatusUnauthorized)
+	}
+}
+
+func TestServer_withoutAuth_sessionsNotFound(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	status := client.do(http.MethodPost, "/sessions", nil, sessionRequest{AccountID: 1, PIN: "1234"}, &errorResponse{})
+	if status != http.StatusNotFound {
+		t.Errorf("invalid status, got %v, want %v", status, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,645 @@
+// Package server предоставляет HTTP/JSON API для wallet.Service.
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/auth"
+	"github.com/Ulugbek999/wallet/pkg/types"
+	"github.com/Ulugbek999/wallet/pkg/wallet"
+)
+
+// IdempotencyKeyHeader - заголовок с ключом идемпотентности для пополнений и платежей.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxBodySize - наибольший размер тела запроса.
+const maxBodySize = 1 << 20
+
+var (
+	errRouteNotFound    = errors.New("route not found")
+	errMethodNotAllowed = errors.New("method not allowed")
+	errInvalidBody      = errors.New("invalid request body")
+	errInvalidID        = errors.New("invalid id")
+)
+
+// Server - http.Handler поверх wallet.Service.
+//
+// Маршруты:
+//
+//	POST   /accounts                       {"phone","pin"}      - RegisterAccount
+//	GET    /accounts/{id}                                       - аккаунт
+//	POST   /accounts/{id}/deposit          {"amount"}           - Deposit
+//	POST   /accounts/{id}/payments         {"amount","category"} - Pay
+//	GET    /accounts/{id}/payments                              - история платежей
+//	GET    /accounts/{id}/favorites                             - избранные платежи
+//	PUT    /accounts/{id}/pin              {"pin"}              - смена PIN
+//	GET    /payments/{id}                                       - платёж
+//	POST   /payments/{id}/reject                                - Reject
+//	POST   /payments/{id}/approve                               - ApprovePayment
+//	GET    /review                                              - PaymentsInReview
+//	POST   /payments/{id}/repeat                                - Repeat
+//	POST   /payments/{id}/favorite         {"name"}             - FavoritePayment
+//	PUT    /favorites/{id}                 {"name","amount"}    - UpdateFavorite
+//	DELETE /favorites/{id}                                      - DeleteFavorite
+//	POST   /favorites/{id}/pay                                  - PayFromFavorite
+//	POST   /export                                              - Export в каталог сервера
+//	POST   /import                                              - Import из каталога сервера
+//	POST   /sessions                       {"accountId","pin"} или {"admin","password"} - токен
+//	DELETE /sessions                                            - отзыв токена
+//
+// Пополнения и платежи с заголовком Idempotency-Key выполняются идемпотентно.
+// Маршруты PIN и сессий доступны после SetAuthenticator.
+type Server struct {
+	// mu - очередь вызовов Service, не рассчитанного на конкурентные вызовы. Тело запроса читается,
+	// а ответ записывается без неё, чтобы медленный клиент не задерживал остальных.
+	mu        sync.Mutex
+	svc       *wallet.Service
+	exportDir string
+	auth      *auth.Authenticator
+}
+
+// NewServer создаёт сервер для svc. Export и Import работают с каталогом exportDir.
+func NewServer(svc *wallet.Service, exportDir string) *Server {
+	return &Server{svc: svc, exportDir: exportDir}
+}
+
+type accountResponse struct {
+	ID        int64               `json:"id"`
+	Phone     types.Phone         `json:"phone"`
+	Balance   types.Money         `json:"balance"`
+	Available types.Money         `json:"available"`
+	Overdraft types.Money         `json:"overdraft"`
+	Tier      types.AccountTier   `json:"tier,omitempty"`
+	Status    types.AccountStatus `json:"status"`
+}
+
+type paymentResponse struct {
+	ID        string                `json:"id"`
+	AccountID int64                 `json:"accountId"`
+	Amount    types.Money           `json:"amount"`
+	Fee       types.Money           `json:"fee"`
+	Category  types.PaymentCategory `json:"category"`
+	Status    types.PaymentStatus   `json:"status"`
+	CreatedAt time.Time             `json:"createdAt"`
+}
+
+type favoriteResponse struct {
+	ID        string                `json:"id"`
+	AccountID int64                 `json:"accountId"`
+	Name      string                `json:"name"`
+	Amount    types.Money           `json:"amount"`
+	Category  types.PaymentCategory `json:"category"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type registerRequest struct {
+	Phone types.Phone `json:"phone"`
+	PIN   string      `json:"pin,omitempty"`
+}
+
+type depositRequest struct {
+	Amount types.Money `json:"amount"`
+}
+
+type payRequest struct {
+	Amount   types.Money           `json:"amount"`
+	Category types.PaymentCategory `json:"category"`
+}
+
+type favoriteRequest struct {
+	Name   string      `json:"name"`
+	Amount types.Money `json:"amount"`
+}
+
+func newAccountResponse(account *types.Account) accountResponse {
+	return accountResponse{
+		ID:        account.ID,
+		Phone:     account.Phone,
+		Balance:   account.Balance,
+		Available: account.Available(),
+		Overdraft: account.Overdraft,
+		Tier:      account.Tier,
+		Status:    account.Status,
+	}
+}
+
+func newPaymentResponse(payment types.Payment) paymentResponse {
+	return paymentResponse{
+		ID:        payment.ID,
+		AccountID: payment.AccountID,
+		Amount:    payment.Amount,
+		Fee:       payment.Fee,
+		Category:  payment.Category,
+		Status:    payment.Status,
+		CreatedAt: payment.CreatedAt,
+	}
+}
+
+func newFavoriteResponse(favorite types.Favorite) favoriteResponse {
+	return favoriteResponse{
+		ID:        favorite.ID,
+		AccountID: favorite.AccountID,
+		Name:      favorite.Name,
+		Amount:    favorite.Amount,
+		Category:  favorite.Category,
+	}
+}
+
+// StatusCode возвращает HTTP-статус для ошибки сервиса.
+func StatusCode(err error) int {
+	switch {
+	case errors.Is(err, errRouteNotFound),
+		errors.Is(err, wallet.ErrAccountNotFound),
+		errors.Is(err, wallet.ErrPaymentNotFound),
+		errors.Is(err, wallet.ErrFavoriteNotFound),
+		errors.Is(err, wallet.ErrFileNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errMethodNotAllowed):
+		return http.StatusMethodNotAllowed
+	case errors.Is(err, errInvalidBody),
+		errors.Is(err, errInvalidID),
+		errors.Is(err, wallet.ErrAmountMustBePositive),
+		errors.Is(err, wallet.ErrInvalidPhone),
+		errors.Is(err, wallet.ErrInvalidIdempotencyKey),
+		errors.Is(err, wallet.ErrInvalidText),
+		errors.Is(err, auth.ErrInvalidPIN):
+		return http.StatusBadRequest
+	case errors.Is(err, auth.ErrUnauthenticated),
+		errors.Is(err, auth.ErrInvalidCredentials):
+		return http.StatusUnauthorized
+	case errors.Is(err, auth.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, auth.ErrCredentialsLocked):
+		return http.StatusLocked
+	case errors.Is(err, wallet.ErrPhoneNumberRegistred),
+		errors.Is(err, wallet.ErrFavoriteNameTaken),
+		errors.Is(err, wallet.ErrAccountFrozen),
+		errors.Is(err, wallet.ErrAccountClosed),
+		errors.Is(err, wallet.ErrIdempotencyKeyReused),
+		errors.Is(err, wallet.ErrPaymentNotInReview):
+		return http.StatusConflict
+	case errors.Is(err, wallet.ErrNotEnoughBalance),
+		errors.Is(err, wallet.ErrLimitExceeded),
+		errors.Is(err, wallet.ErrPaymentBlocked):
+		return http.StatusUnprocessableEntity
+	}
+
+	return http.StatusInternalServerError
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, err := readBody(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	status, body, err := s.serve(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if body == nil {
+		w.WriteHeader(status)
+		return
+	}
+
+	writeJSON(w, status, body)
+}
+
+// serve выполняет запрос с уже прочитанным телом под блокировкой сервиса.
+// Ответ содержит копии данных сервиса, поэтому его можно записывать после снятия блокировки.
+func (s *Server) serve(r *http.Request) (int, interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, err := s.authenticate(r)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if s.auth != nil {
+		s.svc.SetActor(actor(r))
+		defer s.svc.SetActor("")
+	}
+
+	return s.route(r)
+}
+
+// readBody читает тело запроса целиком в память до захвата блокировки.
+func readBody(w http.ResponseWriter, r *http.Request) (*http.Request, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		return nil, errInvalidBody
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	return r, nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := StatusCode(err)
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func readJSON(r *http.Request, target interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(target)
+	if err != nil {
+		return errInvalidBody
+	}
+
+	return nil
+}
+
+// route разбирает путь запроса и вызывает соответствующий обработчик.
+func (s *Server) route(r *http.Request) (int, interface{}, error) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case len(parts) == 1 && parts[0] == "accounts":
+		return s.method(r, http.MethodPost, s.registerAccount)
+	case len(parts) == 1 && parts[0] == "sessions":
+		return s.routeSessions(r)
+	case len(parts) == 1 && parts[0] == "export":
+		return s.method(r, http.MethodPost, s.guard(auth.ActionExport, 0, s.export))
+	case len(parts) == 1 && parts[0] == "import":
+		return s.method(r, http.MethodPost, s.guard(auth.ActionImport, 0, s.importDump))
+	case len(parts) == 1 && parts[0] == "review":
+		return s.method(r, http.MethodGet, s.guard(auth.ActionReview, 0, s.paymentsInReview))
+	case len(parts) >= 2 && parts[0] == "accounts":
+		return s.routeAccount(r, parts[1], parts[2:])
+	case len(parts) >= 2 && parts[0] == "payments":
+		return s.routePayment(r, parts[1], parts[2:])
+	case len(parts) >= 2 && parts[0] == "favorites":
+		return s.routeFavorite(r, parts[1], parts[2:])
+	}
+
+	return 0, nil, errRouteNotFound
+}
+
+func (s *Server) method(r *http.Request, method string, handler func(r *http.Request) (int, interface{}, error)) (int, interface{}, error) {
+	if r.Method != method {
+		return 0, nil, errMethodNotAllowed
+	}
+
+	return handler(r)
+}
+
+func (s *Server) routeAccount(r *http.Request, rawID string, rest []string) (int, interface{}, error) {
+	accountID, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		return 0, nil, errInvalidID
+	}
+
+	switch {
+	case len(rest) == 0:
+		return s.method(r, http.MethodGet, s.guard(auth.ActionView, accountID, func(r *http.Request) (int, interface{}, error) {
+			return s.getAccount(accountID)
+		}))
+	case len(rest) == 1 && rest[0] == "deposit":
+		return s.method(r, http.MethodPost, s.guard(auth.ActionDeposit, accountID, func(r *http.Request) (int, interface{}, error) {
+			return s.deposit(r, accountID)
+		}))
+	case len(rest) == 1 && rest[0] == "payments" && r.Method == http.MethodPost:
+		return s.guard(auth.ActionPay, accountID, func(r *http.Request) (int, interface{}, error) {
+			return s.pay(r, accountID)
+		})(r)
+	case len(rest) == 1 && rest[0] == "payments":
+		return s.method(r, http.MethodGet, s.guard(auth.ActionView, accountID, func(r *http.Request) (int, interface{}, error) {
+			return s.history(accountID)
+		}))
+	case len(rest) == 1 && rest[0] == "favorites":
+		return s.method(r, http.MethodGet, s.guard(auth.ActionView, accountID, func(r *http.Request) (int, interface{}, error) {
+			return s.favorites(accountID)
+		}))
+	case len(rest) == 1 && rest[0] == "pin":
+		return s.method(r, http.MethodPut, s.guard(auth.ActionManage, accountID, func(r *http.Request) (int, interface{}, error) {
+			return s.setPIN(r, accountID)
+		}))
+	}
+
+	return 0, nil, errRouteNotFound
+}
+
+func (s *Server) routePayment(r *http.Request, paymentID string, rest []string) (int, interface{}, error) {
+	accountID := s.paymentOwner(paymentID)
+
+	switch {
+	case len(rest) == 0:
+		return s.method(r, http.MethodGet, s.guard(auth.ActionView, accountID, func(r *http.Request) (int, interface{}, error) {
+			return s.getPayment(paymentID)
+		}))
+	case len(rest) == 1 && rest[0] == "reject":
+		return s.method(r, http.MethodPost, s.guard(auth.ActionReject, accountID, func(r *http.Request) (int, interface{}, error) {
+			return s.reject(paymentID)
+		}))
+	case len(rest) == 1 && rest[0] == "approve":
+		return s.method(r, http.MethodPost, s.guard(auth.ActionReview, accountID, func(r *http.Request) (int, interface{}, error) {
+			return s.approvePayment(paymentID)
+		}))
+	case len(rest) == 1 && rest[0] == "repeat":
+		return s.method(r, http.MethodPost, s.guard(auth.ActionPay, accountID, func(r *http.Request) (int, interface{}, error) {
+			return s.repeat(r, paymentID)
+		}))
+	case len(rest) == 1 && rest[0] == "favorite":
+		return s.method(r, http.MethodPost, s.guard(auth.ActionManage, accountID, func(r *http.Request) (int, interface{}, error) {
+			return s.favoritePayment(r, paymentID)
+		}))
+	}
+
+	return 0, nil, errRouteNotFound
+}
+
+func (s *Server) routeFavorite(r *http.Request, favoriteID string, rest []string) (int, interface{}, error) {
+	accountID := s.favoriteOwner(favoriteID)
+
+	switch {
+	case len(rest) == 0 && r.Method == http.MethodPut:
+		return s.guard(auth.ActionManage, accountID, func(r *http.Request) (int, interface{}, error) {
+			return s.updateFavorite(r, favoriteID)
+		})(r)
+	case len(rest) == 0:
+		return s.method(r, http.MethodDelete, s.guard(auth.ActionManage, accountID, func(r *http.Request) (int, interface{}, error) {
+			return s.deleteFavorite(favoriteID)
+		}))
+	case len(rest) == 1 && rest[0] == "pay":
+		return s.method(r, http.MethodPost, s.guard(auth.ActionPay, accountID, func(r *http.Request) (int, interface{}, error) {
+			return s.payFromFavorite(r, favoriteID)
+		}))
+	}
+
+	return 0, nil, errRouteNotFound
+}
+
+func (s *Server) registerAccount(r *http.Request) (int, interface{}, error) {
+	request := registerRequest{}
+	err := readJSON(r, &request)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if s.auth != nil {
+		err = auth.ValidatePIN(request.PIN)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	account, err := s.svc.RegisterAccount(request.Phone)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if s.auth != nil {
+		err = s.auth.SetPIN(account.ID, request.PIN)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	return http.StatusCreated, newAccountResponse(account), nil
+}
+
+func (s *Server) getAccount(accountID int64) (int, interface{}, error) {
+	account, err := s.svc.FindAccountByID(accountID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, newAccountResponse(account), nil
+}
+
+func (s *Server) deposit(r *http.Request, accountID int64) (int, interface{}, error) {
+	request := depositRequest{}
+	err := readJSON(r, &request)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if key != "" {
+		err = s.svc.DepositIdempotent(key, accountID, request.Amount)
+	} else {
+		err = s.svc.Deposit(accountID, request.Amount)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return s.getAccount(accountID)
+}
+
+func (s *Server) pay(r *http.Request, accountID int64) (int, interface{}, error) {
+	request := payRequest{}
+	err := readJSON(r, &request)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var payment *types.Payment
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if key != "" {
+		payment, err = s.svc.PayIdempotent(key, accountID, request.Amount, request.Category)
+	} else {
+		payment, err = s.svc.Pay(accountID, request.Amount, request.Category)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusCreated, newPaymentResponse(*payment), nil
+}
+
+func (s *Server) history(accountID int64) (int, interface{}, error) {
+	payments, err := s.svc.ExportAccountHistory(accountID)
+	if err != nil && !errors.Is(err, wallet.ErrPaymentNotFound) {
+		return 0, nil, err
+	}
+
+	result := []paymentResponse{}
+	for _, payment := range payments {
+		result = append(result, newPaymentResponse(payment))
+	}
+
+	return http.StatusOK, result, nil
+}
+
+func (s *Server) favorites(accountID int64) (int, interface{}, error) {
+	favorites, err := s.svc.FavoritesForAccount(accountID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	result := []favoriteResponse{}
+	for _, favorite := range favorites {
+		result = append(result, newFavoriteResponse(favorite))
+	}
+
+	return http.StatusOK, result, nil
+}
+
+func (s *Server) getPayment(paymentID string) (int, interface{}, error) {
+	payment, err := s.svc.FindPaymentByID(paymentID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, newPaymentResponse(*payment), nil
+}
+
+func (s *Server) reject(paymentID string) (int, interface{}, error) {
+	err := s.svc.Reject(paymentID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return s.getPayment(paymentID)
+}
+
+func (s *Server) approvePayment(paymentID string) (int, interface{}, error) {
+	err := s.svc.ApprovePayment(paymentID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return s.getPayment(paymentID)
+}
+
+func (s *Server) paymentsInReview(r *http.Request) (int, interface{}, error) {
+	result := []paymentResponse{}
+	for _, payment := range s.svc.PaymentsInReview() {
+		result = append(result, newPaymentResponse(payment))
+	}
+
+	return http.StatusOK, result, nil
+}
+
+func (s *Server) repeat(r *http.Request, paymentID string) (int, interface{}, error) {
+	var payment *types.Payment
+	var err error
+
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if key != "" {
+		payment, err = s.svc.RepeatIdempotent(key, paymentID)
+	} else {
+		payment, err = s.svc.Repeat(paymentID)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusCreated, newPaymentResponse(*payment), nil
+}
+
+func (s *Server) favoritePayment(r *http.Request, paymentID string) (int, interface{}, error) {
+	request := favoriteRequest{}
+	err := readJSON(r, &request)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	favorite, err := s.svc.FavoritePayment(paymentID, request.Name)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusCreated, newFavoriteResponse(*favorite), nil
+}
+
+func (s *Server) updateFavorite(r *http.Request, favoriteID string) (int, interface{}, error) {
+	request := favoriteRequest{}
+	err := readJSON(r, &request)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	favorite, err := s.svc.UpdateFavorite(favoriteID, request.Name, request.Amount)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, newFavoriteResponse(*favorite), nil
+}
+
+func (s *Server) deleteFavorite(favoriteID string) (int, interface{}, error) {
+	err := s.svc.DeleteFavorite(favoriteID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusNoContent, nil, nil
+}
+
+func (s *Server) payFromFavorite(r *http.Request, favoriteID string) (int, interface{}, error) {
+	var payment *types.Payment
+	var err error
+
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if key != "" {
+		payment, err = s.svc.PayFromFavoriteIdempotent(key, favoriteID)
+	} else {
+		payment, err = s.svc.PayFromFavorite(favoriteID)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusCreated, newPaymentResponse(*payment), nil
+}
+
+func (s *Server) export(r *http.Request) (int, interface{}, error) {
+	err := s.svc.ExportWithProgress(r.Context(), s.exportDir, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if s.auth != nil {
+		err = s.auth.Export(s.exportDir)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	return http.StatusNoContent, nil, nil
+}
+
+func (s *Server) importDump(r *http.Request) (int, interface{}, error) {
+	err := s.svc.ImportWithProgress(r.Context(), s.exportDir, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if s.auth != nil {
+		err = s.auth.Import(s.exportDir)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	return http.StatusNoContent, nil, nil
+}
@@ -0,0 +1,386 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+	"github.com/Ulugbek999/wallet/pkg/wallet"
+)
+
+type testClient struct {
+	t   *testing.T
+	url string
+}
+
+func newTestClient(t *testing.T) (*testClient, *wallet.Service) {
+	svc := &wallet.Service{}
+	ts := httptest.NewServer(NewServer(svc, t.TempDir()))
+	t.Cleanup(ts.Close)
+
+	return &testClient{t: t, url: ts.URL}, svc
+}
+
+// do выполняет запрос и декодирует ответ в result (если он не nil). Возвращает статус ответа.
+func (c *testClient) do(method string, path string, headers map[string]string, body interface{}, result interface{}) int {
+	c.t.Helper()
+
+	var reader *bytes.Reader
+	if body == nil {
+		reader = bytes.NewReader(nil)
+	} else {
+		data, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.url+path, reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if result != nil {
+		err = json.NewDecoder(resp.Body).Decode(result)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+	}
+
+	return resp.StatusCode
+}
+
+func TestServer_paymentFlow(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	account := accountResponse{}
+	status := client.do(http.MethodPost, "/accounts", nil, registerRequest{Phone: "+992000000001"}, &account)
+	if status != http.StatusCreated || account.ID != 1 || account.Status != "ACTIVE" {
+		t.Fatalf("invalid register response: %v %+v", status, account)
+	}
+
+	accountPath := fmt.Sprintf("/accounts/%v", account.ID)
+	status = client.do(http.MethodPost, accountPath+"/deposit", nil, depositRequest{Amount: 1000}, &account)
+	if status != http.StatusOK || account.Balance != 1000 {
+		t.Fatalf("invalid deposit response: %v %+v", status, account)
+	}
+
+	payment := paymentResponse{}
+	status = client.do(http.MethodPost, accountPath+"/payments", nil, payRequest{Amount: 300, Category: "auto"}, &payment)
+	if status != http.StatusCreated || payment.Amount != 300 || payment.AccountID != account.ID {
+		t.Fatalf("invalid pay response: %v %+v", status, payment)
+	}
+
+	repeated := paymentResponse{}
+	status = client.do(http.MethodPost, "/payments/"+payment.ID+"/repeat", nil, nil, &repeated)
+	if status != http.StatusCreated || repeated.ID == payment.ID || repeated.Amount != 300 {
+		t.Fatalf("invalid repeat response: %v %+v", status, repeated)
+	}
+
+	rejected := paymentResponse{}
+	status = client.do(http.MethodPost, "/payments/"+payment.ID+"/reject", nil, nil, &rejected)
+	if status != http.StatusOK || rejected.Status != "FAIL" {
+		t.Fatalf("invalid reject response: %v %+v", status, rejected)
+	}
+
+	history := []paymentResponse{}
+	status = client.do(http.MethodGet, accountPath+"/payments", nil, nil, &history)
+	if status != http.StatusOK || len(history) != 2 || history[0].ID != payment.ID {
+		t.Fatalf("invalid history response: %v %+v", status, history)
+	}
+
+	status = client.do(http.MethodGet, accountPath, nil, nil, &account)
+	if status != http.StatusOK || account.Balance != 700 {
+		t.Errorf("invalid account response: %v %+v", status, account)
+	}
+}
+
+func TestServer_review(t *testing.T) {
+	client, svc := newTestClient(t)
+	svc.SetRiskRules(wallet.UnusualAmountRule(5, 1))
+
+	account := accountResponse{}
+	client.do(http.MethodPost, "/accounts", nil, registerRequest{Phone: "+992000000001"}, &account)
+	accountPath := fmt.Sprintf("/accounts/%v", account.ID)
+	client.do(http.MethodPost, accountPath+"/deposit", nil, depositRequest{Amount: 10_000}, &account)
+	client.do(http.MethodPost, accountPath+"/payments", nil, payRequest{Amount: 100, Category: "auto"}, &paymentResponse{})
+
+	payment := paymentResponse{}
+	status := client.do(http.MethodPost, accountPath+"/payments", nil, payRequest{Amount: 600, Category: "auto"}, &payment)
+	if status != http.StatusCreated || payment.Status != "REVIEW" {
+		t.Fatalf("invalid pay response: %v %+v", status, payment)
+	}
+
+	review := []paymentResponse{}
+	status = client.do(http.MethodGet, "/review", nil, nil, &review)
+	if status != http.StatusOK || len(review) != 1 || review[0].ID != payment.ID {
+		t.Fatalf("invalid review response: %v %+v", status, review)
+	}
+
+	approved := paymentResponse{}
+	status = client.do(http.MethodPost, "/payments/"+payment.ID+"/approve", nil, nil, &approved)
+	if status != http.StatusOK || approved.Status != types.PaymentStatusInProgress {
+		t.Fatalf("invalid approve response: %v %+v", status, approved)
+	}
+
+	status = client.do(http.MethodPost, "/payments/"+payment.ID+"/approve", nil, nil, &errorResponse{})
+	if status != http.StatusConflict {
+		t.Errorf("invalid status for repeated approve: %v", status)
+	}
+
+	review = []paymentResponse{}
+	status = client.do(http.MethodGet, "/review", nil, nil, &review)
+	if status != http.StatusOK || len(review) != 0 {
+		t.Errorf("invalid review response after approve: %v %+v", status, review)
+	}
+}
+
+func TestServer_favorites(t *testing.T) {
+	client, svc := newTestClient(t)
+
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := svc.Pay(account.ID, 100, "internet")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	favorite := favoriteResponse{}
+	status := client.do(http.MethodPost, "/payments/"+payment.ID+"/favorite", nil, favoriteRequest{Name: "home"}, &favorite)
+	if status != http.StatusCreated || favorite.Name != "home" || favorite.Amount != 100 {
+		t.Fatalf("invalid favorite response: %v %+v", status, favorite)
+	}
+
+	status = client.do(http.MethodPut, "/favorites/"+favorite.ID, nil, favoriteRequest{Name: "home", Amount: 150}, &favorite)
+	if status != http.StatusOK || favorite.Amount != 150 {
+		t.Fatalf("invalid update response: %v %+v", status, favorite)
+	}
+
+	paid := paymentResponse{}
+	status = client.do(http.MethodPost, "/favorites/"+favorite.ID+"/pay", nil, nil, &paid)
+	if status != http.StatusCreated || paid.Amount != 150 || paid.Category != "internet" {
+		t.Fatalf("invalid pay from favorite response: %v %+v", status, paid)
+	}
+
+	favorites := []favoriteResponse{}
+	status = client.do(http.MethodGet, fmt.Sprintf("/accounts/%v/favorites", account.ID), nil, nil, &favorites)
+	if status != http.StatusOK || len(favorites) != 1 {
+		t.Fatalf("invalid favorites response: %v %+v", status, favorites)
+	}
+
+	status = client.do(http.MethodDelete, "/favorites/"+favorite.ID, nil, nil, nil)
+	if status != http.StatusNoContent {
+		t.Fatalf("invalid delete status: %v", status)
+	}
+
+	status = client.do(http.MethodDelete, "/favorites/"+favorite.ID, nil, nil, nil)
+	if status != http.StatusNotFound {
+		t.Errorf("invalid delete status for removed favorite: %v", status)
+	}
+}
+
+func TestServer_errors(t *testing.T) {
+	client, svc := newTestClient(t)
+
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accountPath := fmt.Sprintf("/accounts/%v", account.ID)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		status int
+	}{
+		{"account not found", http.MethodGet, "/accounts/99", nil, http.StatusNotFound},
+		{"invalid account id", http.MethodGet, "/accounts/abc", nil, http.StatusBadRequest},
+		{"payment not found", http.MethodPost, "/payments/unknown/reject", nil, http.StatusNotFound},
+		{"phone registered", http.MethodPost, "/accounts", registerRequest{Phone: "+992000000001"}, http.StatusConflict},
+		{"invalid phone", http.MethodPost, "/accounts", registerRequest{Phone: "abc"}, http.StatusBadRequest},
+		{"invalid body", http.MethodPost, accountPath + "/deposit", map[string]string{"sum": "1"}, http.StatusBadRequest},
+		{"negative amount", http.MethodPost, accountPath + "/deposit", depositRequest{Amount: -1}, http.StatusBadRequest},
+		{"not enough balance", http.MethodPost, accountPath + "/payments", payRequest{Amount: 100, Category: "auto"}, http.StatusUnprocessableEntity},
+		{"method not allowed", http.MethodDelete, accountPath, nil, http.StatusMethodNotAllowed},
+		{"unknown route", http.MethodGet, "/unknown", nil, http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := errorResponse{}
+			status := client.do(test.method, test.path, nil, test.body, &response)
+			if status != test.status || response.Error == "" {
+				t.Errorf("invalid response, got %v %+v, want %v", status, response, test.status)
+			}
+		})
+	}
+
+	err = svc.FreezeAccount(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := client.do(http.MethodPost, accountPath+"/deposit", nil, depositRequest{Amount: 100}, nil)
+	if status != http.StatusConflict {
+		t.Errorf("invalid status for frozen account, got %v, want %v", status, http.StatusConflict)
+	}
+}
+
+func TestServer_idempotency(t *testing.T) {
+	client, svc := newTestClient(t)
+
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accountPath := fmt.Sprintf("/accounts/%v", account.ID)
+	headers := map[string]string{IdempotencyKeyHeader: "deposit-1"}
+
+	for i := 0; i < 2; i++ {
+		status := client.do(http.MethodPost, accountPath+"/deposit", headers, depositRequest{Amount: 500}, nil)
+		if status != http.StatusOK {
+			t.Fatalf("invalid deposit status: %v", status)
+		}
+	}
+
+	if account.Balance != 500 {
+		t.Errorf("deposit applied twice, balance %v", account.Balance)
+	}
+
+	status := client.do(http.MethodPost, accountPath+"/deposit", headers, depositRequest{Amount: 700}, nil)
+	if status != http.StatusConflict {
+		t.Errorf("invalid status for reused key, got %v, want %v", status, http.StatusConflict)
+	}
+}
+
+func TestServer_exportImport(t *testing.T) {
+	svc := &wallet.Service{}
+	dir := t.TempDir()
+	handler := NewServer(svc, dir)
+
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/export", nil))
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("invalid export status: %v %v", recorder.Code, recorder.Body)
+	}
+
+	newSvc := &wallet.Service{}
+	recorder = httptest.NewRecorder()
+	NewServer(newSvc, dir).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/import", nil))
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("invalid import status: %v %v", recorder.Code, recorder.Body)
+	}
+
+	imported, err := newSvc.FindAccountByID(account.ID)
+	if err != nil || imported.Balance != 900 {
+		t.Errorf("invalid imported account: %+v, %v", imported, err)
+	}
+
+	recorder = httptest.NewRecorder()
+	NewServer(&wallet.Service{}, filepath.Join(t.TempDir(), "missing")).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/import", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("invalid import status for missing dir: %v", recorder.Code)
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	limitErr := &wallet.LimitError{Rule: wallet.LimitRuleMaxPayment}
+	if StatusCode(limitErr) != http.StatusUnprocessableEntity {
+		t.Errorf("invalid status for limit error: %v", StatusCode(limitErr))
+	}
+
+	riskErr := &wallet.RiskError{}
+	if StatusCode(riskErr) != http.StatusUnprocessableEntity {
+		t.Errorf("invalid status for risk error: %v", StatusCode(riskErr))
+	}
+
+	if StatusCode(errors.New("unexpected")) != http.StatusInternalServerError {
+		t.Errorf("invalid status for unknown error: %v", StatusCode(errors.New("unexpected")))
+	}
+}
+
+func TestServer_slowBody(t *testing.T) {
+	svc := &wallet.Service{}
+	handler := NewServer(svc, t.TempDir())
+
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// тело медленного запроса не приходит, пока не закрыт writer
+	reader, writer := io.Pipe()
+	slowDone := make(chan struct{})
+	go func() {
+		defer close(slowDone)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, fmt.Sprintf("/accounts/%v/deposit", account.ID), reader))
+	}()
+
+	done := make(chan int)
+	go func() {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/accounts/%v", account.ID), nil))
+		done <- recorder.Code
+	}()
+
+	select {
+	case code := <-done:
+		if code != http.StatusOK {
+			t.Errorf("invalid status: %v", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("request blocked by slow client")
+	}
+
+	_, _ = writer.Write([]byte(`{"amount":100}`))
+	writer.Close()
+	<-slowDone
+
+	if account.Balance != 100 {
+		t.Errorf("invalid balance after slow deposit: %v", account.Balance)
+	}
+}
@@ -0,0 +1,587 @@
+// Package sqlitestore реализует wallet.Store во встроенной базе SQLite (драйвер modernc.org/sqlite на чистом Go).
+package sqlitestore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+	"github.com/Ulugbek999/wallet/pkg/wallet"
+
+	// драйвер "sqlite"
+	_ "modernc.org/sqlite"
+)
+
+// migrations - схема базы: migrations[i] переводит её с версии i на i+1.
+// Применённые миграции не меняются, новые добавляются в конец.
+var migrations = []string{
+	`CREATE TABLE accounts (
+		id         INTEGER PRIMARY KEY,
+		phone      TEXT    NOT NULL UNIQUE,
+		balance    INTEGER NOT NULL,
+		overdraft  INTEGER NOT NULL DEFAULT 0,
+		tier       TEXT    NOT NULL DEFAULT '',
+		status     TEXT    NOT NULL,
+		held       INTEGER NOT NULL DEFAULT 0,
+		created_at INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE payments (
+		id         TEXT    PRIMARY KEY,
+		account_id INTEGER NOT NULL,
+		amount     INTEGER NOT NULL,
+		category   TEXT    NOT NULL,
+		status     TEXT    NOT NULL,
+		created_at INTEGER NOT NULL DEFAULT 0,
+		fee        INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE favorites (
+		id         TEXT    PRIMARY KEY,
+		account_id INTEGER NOT NULL,
+		name       TEXT    NOT NULL,
+		amount     INTEGER NOT NULL,
+		category   TEXT    NOT NULL
+	);`,
+	`CREATE INDEX payments_account ON payments (account_id, created_at);
+	CREATE INDEX favorites_account ON favorites (account_id);`,
+	`CREATE TABLE holds (
+		id         TEXT    PRIMARY KEY,
+		account_id INTEGER NOT NULL,
+		amount     INTEGER NOT NULL,
+		category   TEXT    NOT NULL,
+		status     TEXT    NOT NULL,
+		payment_id TEXT    NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL,
+		expires_at INTEGER NOT NULL
+	);`,
+	`CREATE TABLE phone_changes (
+		account_id INTEGER NOT NULL,
+		old_phone  TEXT    NOT NULL,
+		new_phone  TEXT    NOT NULL,
+		time       INTEGER NOT NULL,
+		UNIQUE (account_id, old_phone, new_phone, time)
+	);`,
+	`CREATE TABLE outbox (
+		seq         INTEGER PRIMARY KEY,
+		type        TEXT    NOT NULL,
+		time        INTEGER NOT NULL,
+		account_id  INTEGER NOT NULL,
+		amount      INTEGER NOT NULL,
+		category    TEXT    NOT NULL,
+		phone       TEXT    NOT NULL,
+		payment_id  TEXT    NOT NULL,
+		favorite_id TEXT    NOT NULL,
+		hold_id     TEXT    NOT NULL,
+		delivered   INTEGER NOT NULL,
+		attempts    INTEGER NOT NULL
+	);
+	CREATE TABLE idempotency (
+		key        TEXT    PRIMARY KEY,
+		request    TEXT    NOT NULL,
+		payment_id TEXT    NOT NULL,
+		error      TEXT    NOT NULL,
+		created_at INTEGER NOT NULL
+	);
+	CREATE TABLE audit (
+		seq        INTEGER PRIMARY KEY,
+		time       INTEGER NOT NULL,
+		actor      TEXT    NOT NULL,
+		operation  TEXT    NOT NULL,
+		account_id INTEGER NOT NULL,
+		payment_id TEXT    NOT NULL,
+		result     TEXT    NOT NULL,
+		error      TEXT    NOT NULL,
+		args       TEXT    NOT NULL,
+		prev_hash  TEXT    NOT NULL,
+		hash       TEXT    NOT NULL
+	);`,
+	// risk - проверки антифрода платежа в JSON, пустая строка - проверок нет
+	`ALTER TABLE payments ADD COLUMN risk TEXT NOT NULL DEFAULT '';`,
+	// fee - комиссия, зарезервированная вместе с суммой
+	`ALTER TABLE holds ADD COLUMN fee INTEGER NOT NULL DEFAULT 0;`,
+	// fee_account_id - аккаунт, на который зачислена комиссия платежа
+	`ALTER TABLE payments ADD COLUMN fee_account_id INTEGER NOT NULL DEFAULT 0;`,
+	`CREATE TABLE schedules (
+		id          TEXT    PRIMARY KEY,
+		favorite_id TEXT    NOT NULL,
+		cron        TEXT    NOT NULL,
+		next_run    INTEGER NOT NULL,
+		retries     INTEGER NOT NULL
+	);`,
+}
+
+// Store - хранилище аккаунтов, платежей, избранного, резервирований, повторяющихся платежей,
+// истории смен номеров, outbox, ключей идемпотентности и журнала аудита в файле SQLite.
+type Store struct {
+	db *sql.DB
+}
+
+// Open открывает (или создаёт) базу в файле path и применяет недостающие миграции.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// одно соединение: SQLite всё равно сериализует запись, а транзакции не ждут друг друга на блокировках
+	db.SetMaxOpenConns(1)
+
+	store := &Store{db: db}
+	err = store.migrate()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Close закрывает базу.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Version возвращает версию схемы базы - число применённых миграций.
+func (s *Store) Version() (int, error) {
+	version := 0
+	err := s.db.QueryRow(`PRAGMA user_version`).Scan(&version)
+	return version, err
+}
+
+// migrate применяет миграции, которых ещё нет в базе, каждую в своей транзакции.
+// Номер версии хранится в PRAGMA user_version.
+func (s *Store) migrate() error {
+	version, err := s.Version()
+	if err != nil {
+		return err
+	}
+
+	if version > len(migrations) {
+		return fmt.Errorf("database schema version %v is newer than supported %v", version, len(migrations))
+	}
+
+	for ; version < len(migrations); version++ {
+		err = s.transaction(func(tx *sql.Tx) error {
+			_, err := tx.Exec(migrations[version])
+			if err != nil {
+				return fmt.Errorf("migration %v: %w", version+1, err)
+			}
+
+			_, err = tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version+1))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// transaction выполняет fn в транзакции: фиксирует её, если fn не вернула ошибку, иначе откатывает.
+func (s *Store) transaction(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Load загружает все аккаунты, платежи, избранное, резервирования, историю смен номеров,
+// outbox, ключи идемпотентности и журнал аудита.
+func (s *Store) Load() (wallet.StoreChanges, error) {
+	result := wallet.StoreChanges{}
+
+	rows, err := s.db.Query(`SELECT id, phone, balance, overdraft, tier, status, held, created_at FROM accounts ORDER BY id`)
+	if err != nil {
+		return result, err
+	}
+	for rows.Next() {
+		account := types.Account{}
+		var createdAt int64
+		err = rows.Scan(&account.ID, &account.Phone, &account.Balance, &account.Overdraft, &account.Tier, &account.Status, &account.Held, &createdAt)
+		if err != nil {
+			rows.Close()
+			return result, err
+		}
+		account.CreatedAt = decodeTime(createdAt)
+		result.Accounts = append(result.Accounts, account)
+	}
+	err = closeRows(rows)
+	if err != nil {
+		return result, err
+	}
+
+	rows, err = s.db.Query(`SELECT id, account_id, amount, category, status, created_at, fee, risk, fee_account_id FROM payments ORDER BY rowid`)
+	if err != nil {
+		return result, err
+	}
+	for rows.Next() {
+		payment := types.Payment{}
+		var createdAt int64
+		var risk string
+		err = rows.Scan(&payment.ID, &payment.AccountID, &payment.Amount, &payment.Category, &payment.Status, &createdAt, &payment.Fee, &risk, &payment.FeeAccountID)
+		if err != nil {
+			rows.Close()
+			return result, err
+		}
+		payment.CreatedAt = decodeTime(createdAt)
+		payment.Risk, err = decodeRisk(risk)
+		if err != nil {
+			rows.Close()
+			return result, err
+		}
+		result.Payments = append(result.Payments, payment)
+	}
+	err = closeRows(rows)
+	if err != nil {
+		return result, err
+	}
+
+	rows, err = s.db.Query(`SELECT id, account_id, name, amount, category FROM favorites ORDER BY rowid`)
+	if err != nil {
+		return result, err
+	}
+	for rows.Next() {
+		favorite := types.Favorite{}
+		err = rows.Scan(&favorite.ID, &favorite.AccountID, &favorite.Name, &favorite.Amount, &favorite.Category)
+		if err != nil {
+			rows.Close()
+			return result, err
+		}
+		result.Favorites = append(result.Favorites, favorite)
+	}
+	err = closeRows(rows)
+	if err != nil {
+		return result, err
+	}
+
+	rows, err = s.db.Query(`SELECT id, account_id, amount, category, status, payment_id, created_at, expires_at, fee FROM holds ORDER BY rowid`)
+	if err != nil {
+		return result, err
+	}
+	for rows.Next() {
+		hold := types.Hold{}
+		var createdAt, expiresAt int64
+		err = rows.Scan(&hold.ID, &hold.AccountID, &hold.Amount, &hold.Category, &hold.Status, &hold.PaymentID, &createdAt, &expiresAt, &hold.Fee)
+		if err != nil {
+			rows.Close()
+			return result, err
+		}
+		hold.CreatedAt = decodeTime(createdAt)
+		hold.ExpiresAt = decodeTime(expiresAt)
+		result.Holds = append(result.Holds, hold)
+	}
+	err = closeRows(rows)
+	if err != nil {
+		return result, err
+	}
+
+	rows, err = s.db.Query(`SELECT id, favorite_id, cron, next_run, retries FROM schedules ORDER BY rowid`)
+	if err != nil {
+		return result, err
+	}
+	for rows.Next() {
+		record := wallet.ScheduleRecord{}
+		var nextRun int64
+		err = rows.Scan(&record.ID, &record.FavoriteID, &record.Cron, &nextRun, &record.Retries)
+		if err != nil {
+			rows.Close()
+			return result, err
+		}
+		record.NextRun = decodeTime(nextRun)
+		result.Schedules = append(result.Schedules, record)
+	}
+	err = closeRows(rows)
+	if err != nil {
+		return result, err
+	}
+
+	rows, err = s.db.Query(`SELECT account_id, old_phone, new_phone, time FROM phone_changes ORDER BY rowid`)
+	if err != nil {
+		return result, err
+	}
+	for rows.Next() {
+		change := wallet.PhoneChange{}
+		var changedAt int64
+		err = rows.Scan(&change.AccountID, &change.OldPhone, &change.NewPhone, &changedAt)
+		if err != nil {
+			rows.Close()
+			return result, err
+		}
+		change.Time = decodeTime(changedAt)
+		result.PhoneChanges = append(result.PhoneChanges, change)
+	}
+	err = closeRows(rows)
+	if err != nil {
+		return result, err
+	}
+
+	rows, err = s.db.Query(`SELECT seq, type, time, account_id, amount, category, phone, payment_id, favorite_id, hold_id, delivered, attempts
+		FROM outbox ORDER BY seq`)
+	if err != nil {
+		return result, err
+	}
+	for rows.Next() {
+		entry := wallet.OutboxEntry{}
+		event := &entry.Event
+		var eventTime int64
+		err = rows.Scan(&event.Seq, &event.Type, &eventTime, &event.AccountID, &event.Amount, &event.Category, &event.Phone,
+			&event.PaymentID, &event.FavoriteID, &event.HoldID, &entry.Delivered, &entry.Attempts)
+		if err != nil {
+			rows.Close()
+			return result, err
+		}
+		event.Time = decodeTime(eventTime)
+		result.Outbox = append(result.Outbox, entry)
+	}
+	err = closeRows(rows)
+	if err != nil {
+		return result, err
+	}
+
+	rows, err = s.db.Query(`SELECT key, request, payment_id, error, created_at FROM idempotency ORDER BY key`)
+	if err != nil {
+		return result, err
+	}
+	for rows.Next() {
+		record := wallet.IdempotencyRecord{}
+		var createdAt int64
+		err = rows.Scan(&record.Key, &record.Request, &record.PaymentID, &record.Error, &createdAt)
+		if err != nil {
+			rows.Close()
+			return result, err
+		}
+		record.CreatedAt = decodeTime(createdAt)
+		result.Idempotency = append(result.Idempotency, record)
+	}
+	err = closeRows(rows)
+	if err != nil {
+		return result, err
+	}
+
+	rows, err = s.db.Query(`SELECT seq, time, actor, operation, account_id, payment_id, result, error, args, prev_hash, hash
+		FROM audit ORDER BY seq`)
+	if err != nil {
+		return result, err
+	}
+	for rows.Next() {
+		record := wallet.AuditRecord{}
+		var recordTime int64
+		err = rows.Scan(&record.Seq, &recordTime, &record.Actor, &record.Operation, &record.AccountID, &record.PaymentID,
+			&record.Result, &record.Error, &record.Args, &record.PrevHash, &record.Hash)
+		if err != nil {
+			rows.Close()
+			return result, err
+		}
+		record.Time = decodeTime(recordTime)
+		result.Audit = append(result.Audit, record)
+	}
+	err = closeRows(rows)
+
+	return result, err
+}
+
+func closeRows(rows *sql.Rows) error {
+	err := rows.Err()
+	closeErr := rows.Close()
+	if err != nil {
+		return err
+	}
+
+	return closeErr
+}
+
+// Apply записывает изменения одной транзакцией: либо все, либо ничего.
+func (s *Store) Apply(changes wallet.StoreChanges) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		for _, account := range changes.Accounts {
+			_, err := tx.Exec(`INSERT INTO accounts (id, phone, balance, overdraft, tier, status, held, created_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT (id) DO UPDATE SET phone = excluded.phone, balance = excluded.balance,
+					overdraft = excluded.overdraft, tier = excluded.tier, status = excluded.status,
+					held = excluded.held, created_at = excluded.created_at`,
+				account.ID, account.Phone, account.Balance, account.Overdraft, account.Tier, account.Status, account.Held, encodeTime(account.CreatedAt))
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, payment := range changes.Payments {
+			risk, err := encodeRisk(payment.Risk)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec(`INSERT INTO payments (id, account_id, amount, category, status, created_at, fee, risk, fee_account_id)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT (id) DO UPDATE SET account_id = excluded.account_id, amount = excluded.amount,
+					category = excluded.category, status = excluded.status,
+					created_at = excluded.created_at, fee = excluded.fee, risk = excluded.risk,
+					fee_account_id = excluded.fee_account_id`,
+				payment.ID, payment.AccountID, payment.Amount, payment.Category, payment.Status, encodeTime(payment.CreatedAt), payment.Fee, risk, payment.FeeAccountID)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, favorite := range changes.Favorites {
+			_, err := tx.Exec(`INSERT INTO favorites (id, account_id, name, amount, category)
+				VALUES (?, ?, ?, ?, ?)
+				ON CONFLICT (id) DO UPDATE SET account_id = excluded.account_id, name = excluded.name,
+					amount = excluded.amount, category = excluded.category`,
+				favorite.ID, favorite.AccountID, favorite.Name, favorite.Amount, favorite.Category)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, id := range changes.DeletedFavorites {
+			_, err := tx.Exec(`DELETE FROM favorites WHERE id = ?`, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, hold := range changes.Holds {
+			_, err := tx.Exec(`INSERT INTO holds (id, account_id, amount, category, status, payment_id, created_at, expires_at, fee)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT (id) DO UPDATE SET account_id = excluded.account_id, amount = excluded.amount,
+					category = excluded.category, status = excluded.status, payment_id = excluded.payment_id,
+					created_at = excluded.created_at, expires_at = excluded.expires_at, fee = excluded.fee`,
+				hold.ID, hold.AccountID, hold.Amount, hold.Category, hold.Status, hold.PaymentID, encodeTime(hold.CreatedAt), encodeTime(hold.ExpiresAt), hold.Fee)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, record := range changes.Schedules {
+			_, err := tx.Exec(`INSERT INTO schedules (id, favorite_id, cron, next_run, retries)
+				VALUES (?, ?, ?, ?, ?)
+				ON CONFLICT (id) DO UPDATE SET favorite_id = excluded.favorite_id, cron = excluded.cron,
+					next_run = excluded.next_run, retries = excluded.retries`,
+				record.ID, record.FavoriteID, record.Cron, encodeTime(record.NextRun), record.Retries)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, id := range changes.DeletedSchedules {
+			_, err := tx.Exec(`DELETE FROM schedules WHERE id = ?`, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, change := range changes.PhoneChanges {
+			_, err := tx.Exec(`INSERT INTO phone_changes (account_id, old_phone, new_phone, time)
+				VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+				change.AccountID, change.OldPhone, change.NewPhone, encodeTime(change.Time))
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, entry := range changes.Outbox {
+			event := entry.Event
+			_, err := tx.Exec(`INSERT INTO outbox (seq, type, time, account_id, amount, category, phone, payment_id, favorite_id, hold_id, delivered, attempts)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT (seq) DO UPDATE SET type = excluded.type, time = excluded.time, account_id = excluded.account_id,
+					amount = excluded.amount, category = excluded.category, phone = excluded.phone,
+					payment_id = excluded.payment_id, favorite_id = excluded.favorite_id, hold_id = excluded.hold_id,
+					delivered = excluded.delivered, attempts = excluded.attempts`,
+				event.Seq, event.Type, encodeTime(event.Time), event.AccountID, event.Amount, event.Category, event.Phone,
+				event.PaymentID, event.FavoriteID, event.HoldID, entry.Delivered, entry.Attempts)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, seq := range changes.DeletedOutbox {
+			_, err := tx.Exec(`DELETE FROM outbox WHERE seq = ?`, seq)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, record := range changes.Idempotency {
+			_, err := tx.Exec(`INSERT INTO idempotency (key, request, payment_id, error, created_at)
+				VALUES (?, ?, ?, ?, ?)
+				ON CONFLICT (key) DO UPDATE SET request = excluded.request, payment_id = excluded.payment_id,
+					error = excluded.error, created_at = excluded.created_at`,
+				record.Key, record.Request, record.PaymentID, record.Error, encodeTime(record.CreatedAt))
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, key := range changes.DeletedIdempotency {
+			_, err := tx.Exec(`DELETE FROM idempotency WHERE key = ?`, key)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, record := range changes.Audit {
+			_, err := tx.Exec(`INSERT INTO audit (seq, time, actor, operation, account_id, payment_id, result, error, args, prev_hash, hash)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT (seq) DO UPDATE SET time = excluded.time, actor = excluded.actor, operation = excluded.operation,
+					account_id = excluded.account_id, payment_id = excluded.payment_id, result = excluded.result,
+					error = excluded.error, args = excluded.args, prev_hash = excluded.prev_hash, hash = excluded.hash`,
+				record.Seq, encodeTime(record.Time), record.Actor, record.Operation, record.AccountID, record.PaymentID,
+				record.Result, record.Error, record.Args, record.PrevHash, record.Hash)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// encodeTime хранит время в наносекундах Unix, нулевое время - как 0.
+func encodeTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.UnixNano()
+}
+
+func decodeTime(value int64) time.Time {
+	if value == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, value)
+}
+
+// encodeRisk хранит проверки антифрода в JSON, отсутствие проверок - как пустую строку.
+func encodeRisk(checks []types.RiskCheck) (string, error) {
+	if len(checks) == 0 {
+		return "", nil
+	}
+
+	data, err := json.Marshal(checks)
+	return string(data), err
+}
+
+func decodeRisk(value string) ([]types.RiskCheck, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	checks := []types.RiskCheck{}
+	err := json.Unmarshal([]byte(value), &checks)
+	return checks, err
+}
+
+var _ wallet.Store = (*Store)(nil)
@@ -0,0 +1,151 @@
+package sqlitestore
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+	"github.com/Ulugbek999/wallet/pkg/wallet"
+	"github.com/Ulugbek999/wallet/pkg/wallet/wallettest"
+)
+
+// openService открывает сервис с хранилищем в файле path.
+func openService(t *testing.T, path string) (*wallet.Service, *Store) {
+	t.Helper()
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	svc := &wallet.Service{}
+	err = svc.SetStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return svc, store
+}
+
+func TestStore_suite(t *testing.T) {
+	wallettest.Run(t, func(t *testing.T) (*wallet.Service, func() *wallet.Service) {
+		path := filepath.Join(t.TempDir(), "wallet.db")
+		svc, store := openService(t, path)
+
+		return svc, func() *wallet.Service {
+			err := store.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			svc, store = openService(t, path)
+			return svc
+		}
+	})
+}
+
+func TestOpen_migrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.db")
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := store.Version()
+	if err != nil || version != len(migrations) {
+		t.Errorf("invalid version, got %v, %v, want %v", version, err, len(migrations))
+	}
+	store.Close()
+
+	// повторное открытие не применяет миграции заново
+	store, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	version, err = store.Version()
+	if err != nil || version != len(migrations) {
+		t.Errorf("invalid version after reopen, got %v, %v, want %v", version, err, len(migrations))
+	}
+}
+
+func TestStore_Apply_failureRollsBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.db")
+	svc, store := openService(t, path)
+
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.Close()
+
+	payment, err := svc.Pay(account.ID, 100, "auto")
+	if err == nil {
+		t.Fatalf("payment %+v saved to closed store", payment)
+	}
+
+	if account.Balance != 1000 {
+		t.Errorf("invalid balance after failed save, got %v, want 1000", account.Balance)
+	}
+
+	_, err = svc.ExportAccountHistory(account.ID)
+	if !errors.Is(err, wallet.ErrPaymentNotFound) {
+		t.Errorf("payment kept in memory after failed save: %v", err)
+	}
+}
+
+func TestStore_Apply_atomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.db")
+	_, store := openService(t, path)
+
+	// второй аккаунт нарушает уникальность телефона - первый тоже не должен записаться
+	err := store.Apply(wallet.StoreChanges{Accounts: []types.Account{
+		{ID: 1, Phone: "+992000000001", Status: types.AccountStatusActive},
+		{ID: 2, Phone: "+992000000001", Status: types.AccountStatusActive},
+	}})
+	if err == nil {
+		t.Fatal("duplicate phone saved")
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Accounts) != 0 {
+		t.Errorf("partial changes saved: %+v", loaded.Accounts)
+	}
+}
+
+func TestService_SetStore_savesExisting(t *testing.T) {
+	svc := &wallet.Service{}
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "wallet.db")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.SetStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	reopened, _ := openService(t, path)
+	_, err = reopened.FindAccountByID(account.ID)
+	if err != nil {
+		t.Errorf("existing account not saved: %v", err)
+	}
+}
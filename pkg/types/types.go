@@ -1,5 +1,6 @@
 package types
 
+import "time"
 
 // Money представляет собой в минимальных единицах (центы, копейки, дирамы и т.д.)
 type Money int64
@@ -15,8 +16,26 @@ const (
   PaymentStatusOk PaymentStatus = "OK"
   PaymentStatusFail PaymentStatus = "FAIL"
   PaymentStatusInProgress PaymentStatus = "INPROGREES"
+  // PaymentStatusReview - платёж списан, но задержан антифродом до решения администратора
+  PaymentStatusReview PaymentStatus = "REVIEW"
 )
 
+// RiskDecision представляет решение правила антифрода
+type RiskDecision string
+
+const (
+  RiskAllow RiskDecision = "ALLOW"
+  RiskReview RiskDecision = "REVIEW"
+  RiskBlock RiskDecision = "BLOCK"
+)
+
+// RiskCheck представляет результат одного правила антифрода
+type RiskCheck struct {
+  Rule		string
+  Decision	RiskDecision
+  Reason	string
+}
+
 //Payment представляет информацию о платеже
 type Payment struct {
   ID 			string
@@ -24,14 +43,70 @@ type Payment struct {
   Amount 		Money
   Category		PaymentCategory
   Status		PaymentStatus
+  CreatedAt	time.Time
+  // Fee - комиссия, списанная сверх Amount
+  Fee		Money
+  // FeeAccountID - аккаунт, на который зачислена комиссия; при отказе она возвращается с него
+  FeeAccountID	int64
+  // Risk - результаты правил антифрода, проверенных перед платежом
+  Risk		[]RiskCheck
 }
 
 type Phone string
 
+// AccountStatus представляет состояние аккаунта. Пустое значение равносильно AccountStatusActive.
+type AccountStatus string
+
+const (
+  AccountStatusActive AccountStatus = "ACTIVE"
+  AccountStatusFrozen AccountStatus = "FROZEN"
+  AccountStatusClosed AccountStatus = "CLOSED"
+)
+
+// AccountTier представляет тарифный уровень аккаунта (для расчёта комиссий)
+type AccountTier string
+
 type Account struct {
   ID     	int64
   Phone  	Phone
   Balance 	Money
+  // Overdraft - на сколько баланс может уйти в минус (кредитная линия)
+  Overdraft	Money
+  Tier		AccountTier
+  Status	AccountStatus
+  // Held - сумма, зарезервированная авторизациями (входит в Balance, но недоступна для платежей)
+  Held		Money
+  // CreatedAt - время регистрации; нулевое у аккаунтов, импортированных из старых дампов
+  CreatedAt	time.Time
+}
+
+// Available возвращает доступный для платежей остаток (без учёта овердрафта)
+func (a Account) Available() Money {
+  return a.Balance - a.Held
+}
+
+// HoldStatus представляет состояние резервирования средств
+type HoldStatus string
+
+const (
+  HoldStatusActive HoldStatus = "ACTIVE"
+  HoldStatusCaptured HoldStatus = "CAPTURED"
+  HoldStatusReleased HoldStatus = "RELEASED"
+  HoldStatusExpired HoldStatus = "EXPIRED"
+)
+
+// Hold представляет резервирование средств (авторизацию) до списания или отмены
+type Hold struct {
+  ID		string
+  AccountID	int64
+  Amount	Money
+  Category	PaymentCategory
+  Status	HoldStatus
+  PaymentID	string
+  CreatedAt	time.Time
+  ExpiresAt	time.Time
+  // Fee - зарезервированная вместе с Amount комиссия за будущий платёж
+  Fee		Money
 }
 
 
@@ -46,7 +121,11 @@ type Favorite struct {
 
 // Structure for homework 19
 
+// Progress представляет отчёт о завершении одной части долгой операции
 type Progress struct {
   Part int
   Result Money
+  Processed int
+  Total int
+  ETA time.Duration
 }
\ No newline at end of file
@@ -0,0 +1,371 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+var ErrAuditLogTampered = errors.New("audit log tampered")
+
+// SystemActor записывается в журнал аудита, пока не вызван SetActor.
+const SystemActor = "system"
+
+// AuditRecord - запись журнала аудита об одном вызове сервиса.
+// Hash считается от всех полей записи вместе с PrevHash - хешем предыдущей записи,
+// поэтому изменение или удаление любой записи обнаруживается VerifyAuditLog.
+type AuditRecord struct {
+	Seq       uint64
+	Time      time.Time
+	Actor     string
+	Operation string
+	AccountID int64
+	PaymentID string
+	// Result - идентификатор созданного объекта (аккаунта, платежа, избранного, ...)
+	Result   string
+	Error    string
+	Args     string
+	PrevHash string
+	Hash     string
+}
+
+// SetActor задаёт, от чьего имени выполняются следующие вызовы сервиса.
+func (s *Service) SetActor(actor string) {
+	s.actor = actor
+}
+
+func (s *Service) currentActor() string {
+	if s.actor == "" {
+		return SystemActor
+	}
+
+	return s.actor
+}
+
+// auditCall - вызов сервиса, который будет записан в журнал после завершения.
+// Вложенные вызовы (Repeat -> Pay, RunDuePayments -> PayFromFavorite, ...) не записываются отдельно.
+type auditCall struct {
+	s      *Service
+	record *AuditRecord
+}
+
+// beginAudit начинает запись вызова operation. args - пары имя, значение.
+func (s *Service) beginAudit(operation string, accountID int64, paymentID string, args ...interface{}) *auditCall {
+	s.auditDepth++
+	call := &auditCall{s: s}
+	if s.auditDepth > 1 {
+		return call
+	}
+
+	call.record = &AuditRecord{
+		Time:      s.now(),
+		Actor:     auditText(s.currentActor()),
+		Operation: operation,
+		AccountID: accountID,
+		PaymentID: paymentID,
+		Args:      auditText(formatAuditArgs(args)),
+	}
+
+	return call
+}
+
+func formatAuditArgs(args []interface{}) string {
+	pairs := []string{}
+	for i := 0; i+1 < len(args); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%v=%v", args[i], args[i+1]))
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+// auditText убирает из текста разделители формата дампа.
+func auditText(text string) string {
+	return strings.NewReplacer(";", ",", "\n", " ").Replace(text)
+}
+
+// end завершает вызов: записывает вызов в журнал с результатом result и ошибкой err,
+// сохраняет изменения вместе с записью журнала в хранилище (см. SetStore)
+// и доставляет подписчикам события вызова.
+// Если хранилище не приняло изменения, они откатываются в памяти, события не доставляются,
+// запись журнала переписывается с ошибкой хранилища, а возвращается ошибка хранилища
+// (если у вызова не было своей).
+func (c *auditCall) end(result string, err error) error {
+	c.s.auditDepth--
+	if c.record == nil {
+		return err
+	}
+
+	c.write(result, err)
+	storeErr := c.s.flushStore()
+	if storeErr != nil {
+		c.s.auditLog = c.s.auditLog[:len(c.s.auditLog)-1]
+		c.s.rollbackChanges()
+		if err == nil {
+			err = storeErr
+		}
+		c.write(result, err)
+	} else {
+		c.s.commitChanges()
+	}
+
+	c.s.deliverPending()
+	return err
+}
+
+// endUnchecked завершает вызов, который не возвращает ошибку. Если хранилище не приняло изменения,
+// они откатываются, а ошибка попадает в журнал аудита и лог.
+func (c *auditCall) endUnchecked() {
+	err := c.end("", nil)
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+// write добавляет запись о вызове в журнал.
+func (c *auditCall) write(result string, err error) {
+	record := c.record
+	record.Result = result
+	if err != nil {
+		record.Error = auditText(err.Error())
+	}
+
+	if record.AccountID == 0 && record.PaymentID != "" {
+		payment, findErr := c.s.FindPaymentByID(record.PaymentID)
+		if findErr == nil {
+			record.AccountID = payment.AccountID
+		}
+	}
+
+	c.s.appendAudit(record)
+}
+
+// endPayment записывает вызов, результат которого - платёж.
+func (c *auditCall) endPayment(payment *types.Payment, err error) error {
+	if payment == nil {
+		return c.end("", err)
+	}
+
+	if c.record != nil {
+		c.record.AccountID = payment.AccountID
+		c.record.PaymentID = payment.ID
+	}
+	return c.end(payment.ID, err)
+}
+
+// endAccount записывает вызов, результат которого - аккаунт.
+func (c *auditCall) endAccount(account *types.Account, err error) error {
+	if account == nil {
+		return c.end("", err)
+	}
+
+	if c.record != nil {
+		c.record.AccountID = account.ID
+	}
+	return c.end(strconv.FormatInt(account.ID, 10), err)
+}
+
+// endFavorite записывает вызов, результат которого - избранный платёж.
+func (c *auditCall) endFavorite(favorite *types.Favorite, err error) error {
+	if favorite == nil {
+		return c.end("", err)
+	}
+
+	if c.record != nil {
+		c.record.AccountID = favorite.AccountID
+	}
+	return c.end(favorite.ID, err)
+}
+
+// endHold записывает вызов, результат которого - резервирование.
+func (c *auditCall) endHold(hold *types.Hold, err error) error {
+	if hold == nil {
+		return c.end("", err)
+	}
+
+	return c.end(hold.ID, err)
+}
+
+// endSchedule записывает вызов, результат которого - повторяющийся платёж.
+func (c *auditCall) endSchedule(recurring *RecurringPayment, err error) error {
+	if recurring == nil {
+		return c.end("", err)
+	}
+
+	return c.end(recurring.ID, err)
+}
+
+func (s *Service) appendAudit(record *AuditRecord) {
+	record.Seq = 1
+	record.PrevHash = ""
+	if len(s.auditLog) != 0 {
+		last := s.auditLog[len(s.auditLog)-1]
+		record.Seq = last.Seq + 1
+		record.PrevHash = last.Hash
+	}
+	record.Hash = auditHash(record)
+
+	s.auditLog = append(s.auditLog, record)
+}
+
+func auditHash(record *AuditRecord) string {
+	unhashed := *record
+	unhashed.Hash = ""
+	sum := sha256.Sum256([]byte(encodeAuditRecord(&unhashed)))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditLog возвращает журнал аудита в порядке записи.
+func (s *Service) AuditLog() []AuditRecord {
+	return s.filterAudit(func(record *AuditRecord) bool {
+		return true
+	})
+}
+
+// AuditForAccount возвращает записи журнала об операциях с аккаунтом accountID.
+func (s *Service) AuditForAccount(accountID int64) []AuditRecord {
+	return s.filterAudit(func(record *AuditRecord) bool {
+		return record.AccountID == accountID
+	})
+}
+
+// AuditForPayment возвращает записи журнала об операциях с платежом paymentID.
+func (s *Service) AuditForPayment(paymentID string) []AuditRecord {
+	return s.filterAudit(func(record *AuditRecord) bool {
+		return record.PaymentID == paymentID
+	})
+}
+
+func (s *Service) filterAudit(filter func(record *AuditRecord) bool) []AuditRecord {
+	records := []AuditRecord{}
+	for _, record := range s.auditLog {
+		if filter(record) {
+			records = append(records, *record)
+		}
+	}
+
+	return records
+}
+
+// VerifyAuditLog проверяет цепочку хешей журнала и возвращает ошибку,
+// оборачивающую ErrAuditLogTampered, для первой изменённой записи.
+func (s *Service) VerifyAuditLog() error {
+	prevHash := ""
+	for i, record := range s.auditLog {
+		if record.PrevHash != prevHash || record.Hash != auditHash(record) || (i > 0 && record.Seq != s.auditLog[i-1].Seq+1) {
+			return fmt.Errorf("audit record %v: %w", record.Seq, ErrAuditLogTampered)
+		}
+		prevHash = record.Hash
+	}
+
+	return nil
+}
+
+func auditFields(record *AuditRecord) []string {
+	return []string{
+		strconv.FormatUint(record.Seq, 10),
+		encodeTime(record.Time),
+		record.Actor,
+		record.Operation,
+		strconv.FormatInt(record.AccountID, 10),
+		record.PaymentID,
+		record.Result,
+		record.Error,
+		record.Args,
+		record.PrevHash,
+		record.Hash,
+	}
+}
+
+func encodeAuditRecord(record *AuditRecord) string {
+	return strings.Join(auditFields(record), ";")
+}
+
+func decodeAuditRecord(line string) (*AuditRecord, error) {
+	return decodeAuditFields(strings.Split(line, ";"))
+}
+
+func decodeAuditFields(data []string) (*AuditRecord, error) {
+	if len(data) < 11 {
+		return nil, ErrInvalidDump
+	}
+
+	seq, err := strconv.ParseUint(data[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	recordTime, err := decodeTime(data[1])
+	if err != nil {
+		return nil, err
+	}
+
+	accountID, err := strconv.ParseInt(data[4], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuditRecord{
+		Seq:       seq,
+		Time:      recordTime,
+		Actor:     data[2],
+		Operation: data[3],
+		AccountID: accountID,
+		PaymentID: data[5],
+		Result:    data[6],
+		Error:     data[7],
+		Args:      data[8],
+		PrevHash:  data[9],
+		Hash:      data[10],
+	}, nil
+}
+
+func (s *Service) auditSection() dumpSection {
+	section := dumpSection{name: "audit.dump"}
+	for _, record := range s.auditLog {
+		section.lines = append(section.lines, encodeAuditRecord(record))
+	}
+
+	return section
+}
+
+// auditImporter возвращает импорт записей журнала, пропускающий уже имеющиеся записи.
+// В пустой журнал записи добавляются как есть, их целостность проверяет VerifyAuditLog.
+// В непустой журнал добавляются только записи, продолжающие его цепочку хешей:
+// журнал другого сервиса нельзя дописать к своему, не нарушив цепочку, поэтому он пропускается.
+func (s *Service) auditImporter() func(line string) error {
+	local := len(s.auditLog) != 0
+	count := len(s.auditLog)
+	s.onRollback(func() { s.auditLog = s.auditLog[:count] })
+
+	return func(line string) error {
+		record, err := decodeAuditRecord(line)
+		if err != nil {
+			return err
+		}
+
+		for _, recordCheck := range s.auditLog {
+			if recordCheck.Hash == record.Hash {
+				return nil
+			}
+		}
+
+		if local {
+			last := s.auditLog[len(s.auditLog)-1]
+			if record.PrevHash != last.Hash || record.Seq != last.Seq+1 {
+				log.Printf("audit record %v does not continue local audit log, skipped", record.Seq)
+				return nil
+			}
+		}
+
+		s.auditLog = append(s.auditLog, record)
+		return nil
+	}
+}
@@ -0,0 +1,205 @@
+package wallet
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func auditOperations(records []AuditRecord) []string {
+	result := []string{}
+	for _, record := range records {
+		result = append(result, record.Operation)
+	}
+
+	return result
+}
+
+func TestService_AuditLog(t *testing.T) {
+	svc, account, _ := newTestService(t, 1000)
+	payment := mustPay(t, svc, account.ID, 100, "auto")
+	svc.auditLog = nil
+
+	svc.SetActor("support:alice")
+	err := svc.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc.SetActor("")
+	repeated, err := svc.Repeat(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Pay(account.ID, 100_000, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Fatalf("invalid error, got %v, want %v", err, ErrNotEnoughBalance)
+	}
+
+	records := svc.AuditLog()
+	got := strings.Join(auditOperations(records), ",")
+	if got != "Reject,Repeat,Pay" {
+		t.Fatalf("invalid audit operations: %v", got)
+	}
+
+	reject := records[0]
+	if reject.Actor != "support:alice" || reject.PaymentID != payment.ID || reject.AccountID != account.ID || reject.Error != "" {
+		t.Errorf("invalid reject record: %+v", reject)
+	}
+
+	// вложенный вызов Pay из Repeat не записывается отдельно
+	repeat := records[1]
+	if repeat.Actor != SystemActor || repeat.PaymentID != repeated.ID || repeat.Result != repeated.ID || !strings.Contains(repeat.Args, payment.ID) {
+		t.Errorf("invalid repeat record: %+v", repeat)
+	}
+
+	failed := records[2]
+	if failed.Error != ErrNotEnoughBalance.Error() || failed.Args != "amount=100000 category=auto" {
+		t.Errorf("invalid failed pay record: %+v", failed)
+	}
+
+	byPayment := svc.AuditForPayment(payment.ID)
+	if len(byPayment) != 1 || byPayment[0].Operation != "Reject" {
+		t.Errorf("invalid audit for payment: %+v", byPayment)
+	}
+
+	if len(svc.AuditForAccount(account.ID)) != 3 {
+		t.Errorf("invalid audit for account: %+v", svc.AuditForAccount(account.ID))
+	}
+
+	err = svc.VerifyAuditLog()
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestService_VerifyAuditLog_tampered(t *testing.T) {
+	svc, account, _ := newTestService(t, 1000)
+	mustPay(t, svc, account.ID, 100, "auto")
+
+	err := svc.Deposit(account.ID, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.VerifyAuditLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc.auditLog[1].Actor = "admin"
+	err = svc.VerifyAuditLog()
+	if !errors.Is(err, ErrAuditLogTampered) {
+		t.Errorf("invalid error, got %v, want %v", err, ErrAuditLogTampered)
+	}
+
+	svc.auditLog[1].Actor = SystemActor
+	svc.auditLog = append(svc.auditLog[:1], svc.auditLog[2:]...)
+	err = svc.VerifyAuditLog()
+	if !errors.Is(err, ErrAuditLogTampered) {
+		t.Errorf("removed record not detected, got %v", err)
+	}
+}
+
+func TestService_ExportImport_audit(t *testing.T) {
+	dir := t.TempDir()
+	svc, account, _ := newTestService(t, 1000)
+	payment := mustPay(t, svc, account.ID, 100, "auto")
+
+	err := svc.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newSvc := &Service{}
+	err = newSvc.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = newSvc.VerifyAuditLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// журнал экспортирован до записи о самом Export, после импорта к нему добавлена запись Import
+	records := newSvc.AuditLog()
+	if len(records) != len(svc.AuditLog()) || records[len(records)-1].Operation != "Import" {
+		t.Fatalf("invalid imported audit: %v", auditOperations(records))
+	}
+
+	if len(newSvc.AuditForAccount(account.ID)) != 4 || len(newSvc.AuditForPayment(payment.ID)) != 2 {
+		t.Errorf("invalid imported audit for account: %+v", newSvc.AuditForAccount(account.ID))
+	}
+
+	data, err := ioutil.ReadFile(dir + "/audit.dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := strings.Replace(string(data), "Reject", "Repeat", 1)
+	err = ioutil.WriteFile(dir+"/audit.dump", []byte(tampered), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tamperedSvc := &Service{}
+	err = tamperedSvc.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tamperedSvc.VerifyAuditLog()
+	if !errors.Is(err, ErrAuditLogTampered) {
+		t.Errorf("invalid error, got %v, want %v", err, ErrAuditLogTampered)
+	}
+}
+
+func TestService_Import_auditIntoNonEmptyLog(t *testing.T) {
+	dir := t.TempDir()
+	svc, account, _ := newTestService(t, 1000)
+	mustPay(t, svc, account.ID, 100, "auto")
+
+	err := svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// повторный импорт своего экспорта не дублирует записи
+	err = svc.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.VerifyAuditLog()
+	if err != nil {
+		t.Errorf("invalid log after reimport: %v", err)
+	}
+
+	other := &Service{}
+	_, err = other.RegisterAccount("+992000000009")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = other.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = other.VerifyAuditLog()
+	if err != nil {
+		t.Errorf("invalid log after import into non-empty log: %v", err)
+	}
+
+	operations := auditOperations(other.AuditLog())
+	if len(operations) != 2 || operations[0] != "RegisterAccount" || operations[1] != "Import" {
+		t.Errorf("foreign records attached to local log: %v", operations)
+	}
+}
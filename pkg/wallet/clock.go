@@ -0,0 +1,17 @@
+package wallet
+
+import "time"
+
+// SetClock подменяет источник текущего времени сервиса (например, в тестах).
+// nil возвращает time.Now.
+func (s *Service) SetClock(now func() time.Time) {
+	s.clock = now
+}
+
+func (s *Service) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+
+	return s.clock()
+}
@@ -0,0 +1,257 @@
+package wallet
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+// EventType - тип доменного события.
+type EventType string
+
+const (
+	EventAccountRegistered EventType = "AccountRegistered"
+	EventDeposited         EventType = "Deposited"
+	EventPaymentCreated    EventType = "PaymentCreated"
+	EventPaymentRejected   EventType = "PaymentRejected"
+	EventPaymentInReview   EventType = "PaymentInReview"
+	EventPaymentApproved   EventType = "PaymentApproved"
+	EventFavoriteCreated   EventType = "FavoriteCreated"
+	EventFavoriteUpdated   EventType = "FavoriteUpdated"
+	EventFavoriteDeleted   EventType = "FavoriteDeleted"
+	EventAccountFrozen     EventType = "AccountFrozen"
+	EventAccountUnfrozen   EventType = "AccountUnfrozen"
+	EventAccountClosed     EventType = "AccountClosed"
+	EventPhoneChanged      EventType = "PhoneChanged"
+	EventHoldAuthorized    EventType = "HoldAuthorized"
+	EventHoldCaptured      EventType = "HoldCaptured"
+	EventHoldReleased      EventType = "HoldReleased"
+	EventHoldExpired       EventType = "HoldExpired"
+)
+
+// Event - доменное событие сервиса. Заполнены только поля, относящиеся к его типу.
+// Seq растёт на единицу с каждым событием сервиса.
+type Event struct {
+	Seq        uint64
+	Type       EventType
+	Time       time.Time
+	AccountID  int64
+	Amount     types.Money
+	Category   types.PaymentCategory
+	Phone      types.Phone
+	PaymentID  string
+	FavoriteID string
+	HoldID     string
+}
+
+// EventHandler обрабатывает доменные события.
+type EventHandler func(event Event)
+
+type subscriber struct {
+	id      int
+	handler EventHandler
+	// для асинхронных подписчиков - очереди обработчиков, событие аккаунта всегда попадает в одну и ту же
+	queues []chan Event
+	// done закрывается при отписке вместо очередей: в закрытую очередь публикация упала бы с паникой
+	done chan struct{}
+	// workers - номера горутин обработчиков, exited закрываются при их завершении
+	workers map[int64]bool
+	exited  []chan struct{}
+}
+
+func (sub *subscriber) deliver(event Event) {
+	if sub.queues == nil {
+		sub.handler(event)
+		return
+	}
+
+	select {
+	case <-sub.done:
+		return
+	default:
+	}
+
+	select {
+	case sub.queues[uint64(event.AccountID)%uint64(len(sub.queues))] <- event:
+	case <-sub.done:
+	}
+}
+
+// work обрабатывает события очереди queue до отписки, затем дообрабатывает уже поставленные в неё.
+func (sub *subscriber) work(queue chan Event) {
+	for {
+		select {
+		case event := <-queue:
+			sub.handler(event)
+		case <-sub.done:
+			for {
+				select {
+				case event := <-queue:
+					sub.handler(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Subscribe подписывает handler на события. handler вызывается синхронно в порядке событий
+// сразу после завершения операции, породившей событие. События операции, изменения которой
+// не приняло хранилище (см. Store), не доставляются.
+// Возвращает функцию отписки.
+func (s *Service) Subscribe(handler EventHandler) (unsubscribe func()) {
+	return s.addSubscriber(&subscriber{handler: handler})
+}
+
+// SubscribeAsync подписывает handler на события с доставкой в workers горутинах,
+// у каждой из которых очередь на buffer событий. События одного аккаунта обрабатываются
+// одной горутиной в порядке публикации; при заполненной очереди публикация ждёт,
+// поэтому события не теряются. Отписка дожидается обработки всех уже опубликованных событий;
+// вызванная из самого handler, она только прекращает доставку и не ждёт обработчиков.
+func (s *Service) SubscribeAsync(handler EventHandler, workers int, buffer int) (unsubscribe func()) {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	sub := &subscriber{
+		handler: handler,
+		queues:  make([]chan Event, workers),
+		done:    make(chan struct{}),
+		workers: map[int64]bool{},
+		exited:  make([]chan struct{}, workers),
+	}
+
+	started := make(chan int64)
+	for i := range sub.queues {
+		queue := make(chan Event, buffer)
+		exited := make(chan struct{})
+		sub.queues[i] = queue
+		sub.exited[i] = exited
+
+		go func() {
+			defer close(exited)
+			started <- goroutineID()
+			sub.work(queue)
+		}()
+	}
+	for range sub.queues {
+		sub.workers[<-started] = true
+	}
+
+	return s.addSubscriber(sub)
+}
+
+func (s *Service) addSubscriber(sub *subscriber) func() {
+	s.nextSubscriberID++
+	sub.id = s.nextSubscriberID
+	s.subscribers = append(s.subscribers, sub)
+
+	once := sync.Once{}
+	return func() {
+		once.Do(func() {
+			s.removeSubscriber(sub.id)
+			if sub.done != nil {
+				close(sub.done)
+			}
+		})
+
+		// обработчик, отписывающийся из handler, ждал бы сам себя или другой такой же обработчик
+		if sub.workers[goroutineID()] {
+			return
+		}
+		for _, exited := range sub.exited {
+			<-exited
+		}
+	}
+}
+
+// goroutineID возвращает номер текущей горутины из заголовка её стека ("goroutine 18 [running]:").
+func goroutineID() int64 {
+	buf := make([]byte, 64)
+	fields := bytes.Fields(buf[:runtime.Stack(buf, false)])
+	if len(fields) < 2 {
+		return 0
+	}
+
+	id, _ := strconv.ParseInt(string(fields[1]), 10, 64)
+	return id
+}
+
+func (s *Service) removeSubscriber(id int) {
+	for i, sub := range s.subscribers {
+		if sub.id == id {
+			s.subscribers = append(s.subscribers[:i], s.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// publish присваивает событию номер и время, сохраняет его в исходящей очереди
+// и доставляет подписчикам после завершения текущего вызова.
+func (s *Service) publish(event Event) {
+	eventSeq, count := s.eventSeq, len(s.outbox)
+	s.onRollback(func() {
+		s.eventSeq = eventSeq
+		s.outbox = s.outbox[:count]
+	})
+
+	s.eventSeq++
+	event.Seq = s.eventSeq
+	event.Time = s.now()
+	entry := &OutboxEntry{Event: event}
+	s.outbox = append(s.outbox, entry)
+	s.touchOutbox(entry)
+
+	s.pendingEvents = append(s.pendingEvents, event)
+	if s.auditDepth == 0 {
+		s.deliverPending()
+	}
+}
+
+// deliverPending доставляет подписчикам события завершённого вызова.
+func (s *Service) deliverPending() {
+	events := s.pendingEvents
+	s.pendingEvents = nil
+
+	for _, event := range events {
+		for _, sub := range s.subscribers {
+			sub.deliver(event)
+		}
+	}
+}
+
+func paymentEvent(eventType EventType, payment *types.Payment) Event {
+	return Event{
+		Type:      eventType,
+		AccountID: payment.AccountID,
+		Amount:    payment.Amount,
+		Category:  payment.Category,
+		PaymentID: payment.ID,
+	}
+}
+
+func favoriteEvent(eventType EventType, favorite *types.Favorite) Event {
+	return Event{
+		Type:       eventType,
+		AccountID:  favorite.AccountID,
+		Amount:     favorite.Amount,
+		Category:   favorite.Category,
+		FavoriteID: favorite.ID,
+	}
+}
+
+func holdEvent(eventType EventType, hold *types.Hold) Event {
+	return Event{
+		Type:      eventType,
+		AccountID: hold.AccountID,
+		Amount:    hold.Amount,
+		Category:  hold.Category,
+		HoldID:    hold.ID,
+		PaymentID: hold.PaymentID,
+	}
+}
@@ -0,0 +1,197 @@
+package wallet
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+func eventTypes(events []Event) []EventType {
+	result := []EventType{}
+	for _, event := range events {
+		result = append(result, event.Type)
+	}
+
+	return result
+}
+
+func TestService_Subscribe(t *testing.T) {
+	svc := &Service{}
+
+	events := []Event{}
+	unsubscribe := svc.Subscribe(func(event Event) {
+		events = append(events, event)
+	})
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repeated, err := svc.Repeat(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.FavoritePayment(repeated.ID, "car")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Pay(account.ID, 100_000, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Fatalf("invalid error, got %v, want %v", err, ErrNotEnoughBalance)
+	}
+
+	unsubscribe()
+	err = svc.Deposit(account.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []EventType{
+		EventAccountRegistered,
+		EventDeposited,
+		EventPaymentCreated,
+		EventPaymentCreated,
+		EventPaymentRejected,
+		EventFavoriteCreated,
+	}
+	got := eventTypes(events)
+	if len(got) != len(want) {
+		t.Fatalf("invalid events, got %v, want %v", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] || events[i].Seq != uint64(i+1) || events[i].AccountID != account.ID {
+			t.Errorf("event %v: invalid event %+v, want %v", i, events[i], want[i])
+		}
+	}
+
+	if events[3].PaymentID != repeated.ID || events[4].PaymentID != payment.ID {
+		t.Errorf("invalid payment ids in events: %+v, %+v", events[3], events[4])
+	}
+}
+
+func TestService_SubscribeAsync_orderPerAccount(t *testing.T) {
+	svc := &Service{}
+
+	mu := sync.Mutex{}
+	received := map[int64][]Event{}
+	unsubscribe := svc.SubscribeAsync(func(event Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		received[event.AccountID] = append(received[event.AccountID], event)
+	}, 3, 1)
+
+	accounts := []*types.Account{}
+	for i := 0; i < 5; i++ {
+		account, err := svc.RegisterAccount(types.Phone("+99200000000" + string(rune('0'+i))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		accounts = append(accounts, account)
+	}
+
+	published := 5
+	for i := 0; i < 100; i++ {
+		for _, account := range accounts {
+			err := svc.Deposit(account.ID, types.Money(i+1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			published++
+		}
+	}
+
+	unsubscribe()
+
+	total := 0
+	for _, account := range accounts {
+		events := received[account.ID]
+		total += len(events)
+
+		if len(events) != 101 || events[0].Type != EventAccountRegistered {
+			t.Fatalf("account %v: invalid events count %v", account.ID, len(events))
+		}
+
+		for i := 1; i < len(events); i++ {
+			if events[i].Seq <= events[i-1].Seq || events[i].Amount != types.Money(i) {
+				t.Fatalf("account %v: events out of order at %v", account.ID, i)
+			}
+		}
+	}
+
+	if total != published {
+		t.Errorf("events lost, got %v, want %v", total, published)
+	}
+}
+
+func TestService_SubscribeAsync_unsubscribeTwice(t *testing.T) {
+	svc := &Service{}
+
+	unsubscribe := svc.SubscribeAsync(func(event Event) {}, 2, 0)
+	unsubscribe()
+	unsubscribe()
+
+	_, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestService_SubscribeAsync_unsubscribeFromHandler(t *testing.T) {
+	svc := &Service{}
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := 0
+	unsubscribed := make(chan struct{})
+	var unsubscribe func()
+	unsubscribe = svc.SubscribeAsync(func(event Event) {
+		received++
+		unsubscribe()
+		close(unsubscribed)
+	}, 1, 0)
+
+	err = svc.Deposit(account.ID, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-unsubscribed:
+	case <-time.After(time.Second):
+		t.Fatal("unsubscribe from handler deadlocked")
+	}
+
+	// после отписки публикация не пишет в очередь и не ждёт обработчика
+	err = svc.Deposit(account.ID, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsubscribe()
+	if received != 1 {
+		t.Errorf("invalid received count, got %v, want %v", received, 1)
+	}
+}
@@ -0,0 +1,94 @@
+package wallet
+
+import (
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+// checkFavoriteName проверяет, что у аккаунта нет другого избранного с именем name.
+// exceptID - избранное, которое при проверке не учитывается (при переименовании).
+func (s *Service) checkFavoriteName(accountID int64, name string, exceptID string) error {
+	for _, favorite := range s.favorites {
+		if favorite.AccountID == accountID && favorite.Name == name && favorite.ID != exceptID {
+			return ErrFavoriteNameTaken
+		}
+	}
+
+	return nil
+}
+
+// FavoritesForAccount возвращает избранные платежи аккаунта.
+func (s *Service) FavoritesForAccount(accountID int64) ([]types.Favorite, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	favorites := []types.Favorite{}
+	for _, favorite := range s.favorites {
+		if favorite.AccountID == accountID {
+			favorites = append(favorites, *favorite)
+		}
+	}
+
+	return favorites, nil
+}
+
+// UpdateFavorite меняет имя и сумму избранного платежа.
+func (s *Service) UpdateFavorite(favoriteID string, name string, amount types.Money) (favorite *types.Favorite, err error) {
+	call := s.beginAudit("UpdateFavorite", 0, "", "favoriteID", favoriteID, "name", name, "amount", amount)
+	defer func() { err = call.endFavorite(favorite, err) }()
+
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+
+	err = checkText(name)
+	if err != nil {
+		return nil, err
+	}
+
+	favorite, err = s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkFavoriteName(favorite.AccountID, name, favorite.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.touchFavorite(favorite)
+	favorite.Name = name
+	favorite.Amount = amount
+	s.publish(favoriteEvent(EventFavoriteUpdated, favorite))
+
+	return favorite, nil
+}
+
+// DeleteFavorite удаляет избранный платёж вместе с его повторяющимися платежами.
+func (s *Service) DeleteFavorite(favoriteID string) (err error) {
+	call := s.beginAudit("DeleteFavorite", 0, "", "favoriteID", favoriteID)
+	defer func() { err = call.end("", err) }()
+
+	for i, favorite := range s.favorites {
+		if favorite.ID != favoriteID {
+			continue
+		}
+
+		s.removeFavorite(i)
+
+		s.saveSchedules()
+		schedules := []*RecurringPayment{}
+		for _, recurring := range s.schedules {
+			if recurring.FavoriteID != favoriteID {
+				schedules = append(schedules, recurring)
+			}
+		}
+		s.schedules = schedules
+		s.publish(favoriteEvent(EventFavoriteDeleted, favorite))
+
+		return nil
+	}
+
+	return ErrFavoriteNotFound
+}
@@ -0,0 +1,276 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+func TestService_invalidText(t *testing.T) {
+	svc, account, _ := newTestService(t, 1000)
+	payment := mustPay(t, svc, account.ID, 100, "auto")
+
+	_, err := svc.FavoritePayment(payment.ID, "car\ncommit")
+	if err != ErrInvalidText {
+		t.Errorf("invalid error, got %v, want %v", err, ErrInvalidText)
+	}
+
+	favorite, err := svc.FavoritePayment(payment.ID, "car")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.UpdateFavorite(favorite.ID, "car;1", 100)
+	if err != ErrInvalidText {
+		t.Errorf("invalid error, got %v, want %v", err, ErrInvalidText)
+	}
+
+	_, err = svc.Pay(account.ID, 100, "auto;1")
+	if err != ErrInvalidText {
+		t.Errorf("invalid error, got %v, want %v", err, ErrInvalidText)
+	}
+
+	_, err = svc.Authorize(account.ID, 100, "auto\r")
+	if err != ErrInvalidText {
+		t.Errorf("invalid error, got %v, want %v", err, ErrInvalidText)
+	}
+
+	err = svc.SetAccountTier(account.ID, "gold\n")
+	if err != ErrInvalidText {
+		t.Errorf("invalid error, got %v, want %v", err, ErrInvalidText)
+	}
+
+	if favorite.Name != "car" || account.Balance != 900 || account.Held != 0 || account.Tier != "" {
+		t.Errorf("rejected calls changed state: %+v, %+v", favorite, account)
+	}
+}
+
+func TestService_FavoritePayment_uniqueName(t *testing.T) {
+	svc, account, _ := newTestService(t, 1000)
+	payment := mustPay(t, svc, account.ID, 100, "auto")
+
+	_, err := svc.FavoritePayment(payment.ID, "car")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.FavoritePayment(payment.ID, "car")
+	if err != ErrFavoriteNameTaken {
+		t.Errorf("invalid error, got %v, want %v", err, ErrFavoriteNameTaken)
+	}
+
+	other, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Deposit(other.ID, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherPayment, err := svc.Pay(other.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.FavoritePayment(otherPayment.ID, "car")
+	if err != nil {
+		t.Errorf("same name for other account must be allowed: %v", err)
+	}
+}
+
+func TestService_FavoritesForAccount(t *testing.T) {
+	svc, account, _ := newTestService(t, 1000)
+	payment := mustPay(t, svc, account.ID, 100, "auto")
+
+	favorites, err := svc.FavoritesForAccount(account.ID)
+	if err != nil || len(favorites) != 0 {
+		t.Fatalf("invalid result, got %v, %v", favorites, err)
+	}
+
+	for _, name := range []string{"car", "taxi"} {
+		_, err = svc.FavoritePayment(payment.ID, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	favorites, err = svc.FavoritesForAccount(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(favorites) != 2 || favorites[0].Name != "car" || favorites[1].Name != "taxi" {
+		t.Errorf("invalid favorites: %v", favorites)
+	}
+
+	_, err = svc.FavoritesForAccount(2)
+	if err != ErrAccountNotFound {
+		t.Errorf("invalid error, got %v, want %v", err, ErrAccountNotFound)
+	}
+}
+
+func TestService_UpdateFavorite(t *testing.T) {
+	svc, account, _ := newTestService(t, 1000)
+	payment := mustPay(t, svc, account.ID, 100, "auto")
+
+	car, err := svc.FavoritePayment(payment.ID, "car")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.FavoritePayment(payment.ID, "taxi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.UpdateFavorite(car.ID, "taxi", 200)
+	if err != ErrFavoriteNameTaken {
+		t.Errorf("invalid error, got %v, want %v", err, ErrFavoriteNameTaken)
+	}
+
+	_, err = svc.UpdateFavorite(car.ID, "car", 0)
+	if err != ErrAmountMustBePositive {
+		t.Errorf("invalid error, got %v, want %v", err, ErrAmountMustBePositive)
+	}
+
+	updated, err := svc.UpdateFavorite(car.ID, "auto", 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if updated.Name != "auto" || updated.Amount != 200 {
+		t.Errorf("invalid favorite: %v", updated)
+	}
+
+	newPayment, err := svc.PayFromFavorite(car.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if newPayment.Amount != 200 {
+		t.Errorf("invalid amount, got %v, want %v", newPayment.Amount, 200)
+	}
+
+	_, err = svc.UpdateFavorite("unknown", "car", 1)
+	if err != ErrFavoriteNotFound {
+		t.Errorf("invalid error, got %v, want %v", err, ErrFavoriteNotFound)
+	}
+}
+
+func TestService_DeleteFavorite(t *testing.T) {
+	svc, account, _ := newTestService(t, 1000)
+	payment := mustPay(t, svc, account.ID, 100, "auto")
+
+	favorite, err := svc.FavoritePayment(payment.ID, "car")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.SchedulePayment(favorite.ID, mustSchedule(Daily(12, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.DeleteFavorite(favorite.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(svc.schedules) != 0 {
+		t.Errorf("schedules of deleted favorite must be removed")
+	}
+
+	_, err = svc.FindFavoriteByID(favorite.ID)
+	if err != ErrFavoriteNotFound {
+		t.Errorf("invalid error, got %v, want %v", err, ErrFavoriteNotFound)
+	}
+
+	err = svc.DeleteFavorite(favorite.ID)
+	if err != ErrFavoriteNotFound {
+		t.Errorf("invalid error, got %v, want %v", err, ErrFavoriteNotFound)
+	}
+
+	_, err = svc.FavoritePayment(payment.ID, "car")
+	if err != nil {
+		t.Errorf("name of deleted favorite must be free: %v", err)
+	}
+}
+
+func TestService_Export_deletedFavorites(t *testing.T) {
+	dir := t.TempDir()
+	svc, account, _ := newTestService(t, 1000)
+	payment := mustPay(t, svc, account.ID, 100, "auto")
+
+	favorite, err := svc.FavoritePayment(payment.ID, "car")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.DeleteFavorite(favorite.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newSvc := &Service{}
+	err = newSvc.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(newSvc.favorites) != 0 {
+		t.Errorf("deleted favorite must not be imported: %v", newSvc.favorites)
+	}
+}
+
+func TestService_Import_favoriteNameTaken(t *testing.T) {
+	dir := t.TempDir()
+	svc, account, _ := newTestService(t, 1000)
+	payment := mustPay(t, svc, account.ID, 100, "auto")
+
+	favorite, err := svc.FavoritePayment(payment.ID, "car")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// в дампе у избранного с другим ID то же имя, что и у уже импортированного
+	dump := encodeFavorite(favorite) + "\n" + encodeFavorite(&types.Favorite{
+		ID:        "other",
+		AccountID: account.ID,
+		Name:      favorite.Name,
+		Amount:    50,
+		Category:  "auto",
+	})
+	err = ioutil.WriteFile(filepath.Join(dir, "favorites.dump"), []byte(dump), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newSvc := &Service{}
+	err = newSvc.Import(dir)
+	if err != ErrFavoriteNameTaken {
+		t.Errorf("invalid error, got %v, want %v", err, ErrFavoriteNameTaken)
+	}
+
+	if len(newSvc.favorites) != 1 || newSvc.favorites[0].ID != favorite.ID {
+		t.Errorf("invalid favorites: %v", newSvc.favorites)
+	}
+}
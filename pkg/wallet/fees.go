@@ -0,0 +1,174 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+var ErrInvalidFeeRule = errors.New("invalid fee rule")
+
+// FeeRule описывает комиссию за платёж: Flat + BasisPoints сотых долей процента от суммы,
+// ограниченную снизу Min и сверху Max (ноль - без ограничения).
+// Пустые Category и Tier подходят к любой категории и любому уровню аккаунта.
+type FeeRule struct {
+	Category    types.PaymentCategory
+	Tier        types.AccountTier
+	Flat        types.Money
+	BasisPoints int64
+	Min         types.Money
+	Max         types.Money
+}
+
+func (r FeeRule) matches(account *types.Account, category types.PaymentCategory) bool {
+	return (r.Category == "" || r.Category == category) && (r.Tier == "" || r.Tier == account.Tier)
+}
+
+// specificity - чем больше условий в правиле, тем выше его приоритет.
+func (r FeeRule) specificity() int {
+	result := 0
+	if r.Category != "" {
+		result += 2
+	}
+	if r.Tier != "" {
+		result++
+	}
+
+	return result
+}
+
+// validate проверяет, что составляющие комиссии не отрицательные и Min не больше Max.
+func (r FeeRule) validate() error {
+	if r.Flat < 0 || r.BasisPoints < 0 || r.Min < 0 || r.Max < 0 {
+		return fmt.Errorf("%w %+v: negative value", ErrInvalidFeeRule, r)
+	}
+
+	if r.Max > 0 && r.Min > r.Max {
+		return fmt.Errorf("%w %+v: min is greater than max", ErrInvalidFeeRule, r)
+	}
+
+	return nil
+}
+
+func (r FeeRule) fee(amount types.Money) types.Money {
+	fee := r.Flat + types.Money(int64(amount)*r.BasisPoints/10_000)
+	if fee < r.Min {
+		fee = r.Min
+	}
+	if r.Max > 0 && fee > r.Max {
+		fee = r.Max
+	}
+
+	return fee
+}
+
+// SetFeeRules задаёт правила комиссий и аккаунт, на который они зачисляются.
+// К платежу применяется самое точное подходящее правило (категория и уровень,
+// затем категория, затем уровень, затем общее), при равенстве - первое из переданных.
+// Правило с отрицательной составляющей или с Min больше Max отклоняется с ErrInvalidFeeRule.
+// Комиссии уже проведённых платежей при отказе возвращаются с того аккаунта, на который были зачислены.
+func (s *Service) SetFeeRules(houseAccountID int64, rules ...FeeRule) (err error) {
+	call := s.beginAudit("SetFeeRules", houseAccountID, "", "rules", rules)
+	defer func() { err = call.end("", err) }()
+
+	for _, rule := range rules {
+		err = rule.validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = s.FindAccountByID(houseAccountID)
+	if err != nil {
+		return err
+	}
+
+	s.houseAccountID = houseAccountID
+	s.feeRules = rules
+	return nil
+}
+
+// SetAccountTier задаёт тарифный уровень аккаунта.
+func (s *Service) SetAccountTier(accountID int64, tier types.AccountTier) (err error) {
+	call := s.beginAudit("SetAccountTier", accountID, "", "tier", tier)
+	defer func() { err = call.end("", err) }()
+
+	err = checkText(string(tier))
+	if err != nil {
+		return err
+	}
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+
+	s.touchAccount(account)
+	account.Tier = tier
+	return nil
+}
+
+func (s *Service) calculateFee(account *types.Account, amount types.Money, category types.PaymentCategory) types.Money {
+	if account.ID == s.houseAccountID {
+		return 0
+	}
+
+	var best *FeeRule
+	for i, rule := range s.feeRules {
+		if !rule.matches(account, category) {
+			continue
+		}
+		if best == nil || rule.specificity() > best.specificity() {
+			best = &s.feeRules[i]
+		}
+	}
+
+	if best == nil {
+		return 0
+	}
+
+	return best.fee(amount)
+}
+
+func (s *Service) creditFee(fee types.Money) error {
+	if fee == 0 {
+		return nil
+	}
+
+	house, err := s.FindAccountByID(s.houseAccountID)
+	if err != nil {
+		return err
+	}
+
+	s.touchAccount(house)
+	house.Balance += fee
+	return nil
+}
+
+// refundFee возвращает на account часть комиссии платежа, пропорциональную возвращаемой сумме amount,
+// с аккаунта, на который она была зачислена. У платежей из дампов прежних версий этот аккаунт
+// не записан - для них берётся текущий аккаунт комиссий.
+func (s *Service) refundFee(account *types.Account, payment *types.Payment, amount types.Money) error {
+	if payment.Fee == 0 || payment.Amount == 0 {
+		return nil
+	}
+
+	refund := types.Money(int64(payment.Fee) * int64(amount) / int64(payment.Amount))
+
+	houseAccountID := payment.FeeAccountID
+	if houseAccountID == 0 {
+		houseAccountID = s.houseAccountID
+	}
+
+	house, err := s.FindAccountByID(houseAccountID)
+	if err != nil {
+		return err
+	}
+
+	s.touchAccount(house)
+	s.touchAccount(account)
+	house.Balance -= refund
+	account.Balance += refund
+	return nil
+}
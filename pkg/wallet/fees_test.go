@@ -0,0 +1,220 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+// setHouseAccount регистрирует аккаунт для комиссий и задаёт правила rules.
+func setHouseAccount(t *testing.T, svc *Service, rules ...FeeRule) *types.Account {
+	t.Helper()
+
+	house, err := svc.RegisterAccount("+992000000099")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.SetFeeRules(house.ID, rules...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return house
+}
+
+func TestFeeRule_fee(t *testing.T) {
+	tests := []struct {
+		rule   FeeRule
+		amount types.Money
+		want   types.Money
+	}{
+		{FeeRule{Flat: 10}, 1000, 10},
+		{FeeRule{BasisPoints: 150}, 1000, 15},
+		{FeeRule{BasisPoints: 150, Min: 20}, 1000, 20},
+		{FeeRule{BasisPoints: 150, Max: 12}, 1000, 12},
+		{FeeRule{Flat: 5, BasisPoints: 100}, 1000, 15},
+	}
+
+	for _, test := range tests {
+		got := test.rule.fee(test.amount)
+		if got != test.want {
+			t.Errorf("%+v: invalid fee, got %v, want %v", test.rule, got, test.want)
+		}
+	}
+}
+
+func TestService_Pay_fee(t *testing.T) {
+	svc, account, _ := newTestService(t, 10_000)
+	house := setHouseAccount(t, svc,
+		FeeRule{Flat: 10},
+		FeeRule{Category: "auto", BasisPoints: 100},
+		FeeRule{Tier: "gold"},
+		FeeRule{Category: "auto", Tier: "gold", Flat: 1},
+	)
+
+	payment, err := svc.Pay(account.ID, 1000, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if payment.Fee != 10 || payment.Amount != 1000 {
+		t.Errorf("invalid payment: %+v", payment)
+	}
+
+	if account.Balance != 10_000-1010 || house.Balance != 10 {
+		t.Errorf("invalid balances, account %v, house %v", account.Balance, house.Balance)
+	}
+
+	err = svc.SetAccountTier(account.ID, "gold")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err = svc.Pay(account.ID, 1000, "food")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Fee != 0 {
+		t.Errorf("tier rule must win over default, got fee %v", payment.Fee)
+	}
+
+	payment, err = svc.Pay(account.ID, 1000, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Fee != 1 {
+		t.Errorf("category and tier rule must win, got fee %v", payment.Fee)
+	}
+}
+
+func TestService_Pay_feeNotEnoughBalance(t *testing.T) {
+	svc, account, _ := newTestService(t, 10_000)
+	setHouseAccount(t, svc, FeeRule{Flat: 1})
+
+	_, err := svc.Pay(account.ID, 10_000, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Errorf("invalid error, got %v, want %v", err, ErrNotEnoughBalance)
+	}
+}
+
+func TestService_Reject_refundsFee(t *testing.T) {
+	svc, account, _ := newTestService(t, 10_000)
+	house := setHouseAccount(t, svc, FeeRule{BasisPoints: 200})
+
+	payment, err := svc.Pay(account.ID, 5000, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if house.Balance != 100 {
+		t.Fatalf("invalid house balance, got %v, want %v", house.Balance, 100)
+	}
+
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if account.Balance != 10_000 || house.Balance != 0 {
+		t.Errorf("invalid balances after reject, account %v, house %v", account.Balance, house.Balance)
+	}
+}
+
+func TestService_Reject_twice(t *testing.T) {
+	svc, account, _ := newTestService(t, 10_000)
+	house := setHouseAccount(t, svc, FeeRule{Flat: 10})
+
+	payment, err := svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err = svc.Reject(payment.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if account.Balance != 10_000 || house.Balance != 0 {
+		t.Errorf("invalid balances after second reject, account %v, house %v", account.Balance, house.Balance)
+	}
+}
+
+func TestService_Reject_refundsFeeFromChargedHouse(t *testing.T) {
+	svc, account, _ := newTestService(t, 10_000)
+	house := setHouseAccount(t, svc, FeeRule{Flat: 10})
+
+	payment := mustPay(t, svc, account.ID, 100, "auto")
+	if payment.FeeAccountID != house.ID {
+		t.Errorf("invalid fee account, got %v, want %v", payment.FeeAccountID, house.ID)
+	}
+
+	// аккаунт комиссии сохраняется в дампе
+	dir := t.TempDir()
+	err := svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := &Service{}
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	importedPayment, err := imported.FindPaymentByID(payment.ID)
+	if err != nil || importedPayment.FeeAccountID != house.ID {
+		t.Errorf("invalid imported payment: %+v, %v", importedPayment, err)
+	}
+
+	newHouse, err := svc.RegisterAccount("+992000000098")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.SetFeeRules(newHouse.ID, FeeRule{Flat: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if account.Balance != 10_000 || house.Balance != 0 || newHouse.Balance != 0 {
+		t.Errorf("invalid balances after reject, account %v, house %v, new house %v", account.Balance, house.Balance, newHouse.Balance)
+	}
+}
+
+func TestService_SetFeeRules_invalid(t *testing.T) {
+	svc, account, _ := newTestService(t, 10_000)
+	house := setHouseAccount(t, svc, FeeRule{Flat: 10})
+
+	for _, rule := range []FeeRule{
+		{Flat: -1},
+		{BasisPoints: -100},
+		{Min: -5},
+		{Max: -5},
+		{Min: 20, Max: 10},
+	} {
+		err := svc.SetFeeRules(house.ID, FeeRule{Flat: 1}, rule)
+		if !errors.Is(err, ErrInvalidFeeRule) {
+			t.Errorf("%+v: invalid error, got %v, want %v", rule, err, ErrInvalidFeeRule)
+		}
+	}
+
+	payment := mustPay(t, svc, account.ID, 100, "auto")
+	if payment.Fee != 10 {
+		t.Errorf("rules changed by rejected call, got fee %v, want 10", payment.Fee)
+	}
+}
+
+func TestService_SetFeeRules_houseNotFound(t *testing.T) {
+	svc := &Service{}
+
+	err := svc.SetFeeRules(1, FeeRule{Flat: 1})
+	if err != ErrAccountNotFound {
+		t.Errorf("invalid error, got %v, want %v", err, ErrAccountNotFound)
+	}
+}
@@ -0,0 +1,350 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+var (
+	ErrPaymentBlocked     = errors.New("payment blocked by fraud rules")
+	ErrPaymentNotInReview = errors.New("payment is not in review")
+)
+
+// Имена встроенных правил антифрода, указываемые в types.RiskCheck.Rule.
+const (
+	RiskRuleUnusualAmount     = "unusual amount"
+	RiskRuleRapidRepeat       = "rapid repeat"
+	RiskRuleNewAccount        = "new account"
+	RiskRuleCategoryBlacklist = "category blacklist"
+)
+
+// RiskRequest - данные платежа, которые получает правило антифрода.
+type RiskRequest struct {
+	Account  types.Account
+	Amount   types.Money
+	Category types.PaymentCategory
+	// RepeatOf - ID исходного платежа, если платёж создаётся через Repeat
+	RepeatOf string
+	// History - прежние платежи аккаунта, включая отклонённые
+	History []types.Payment
+	Now     time.Time
+}
+
+// RiskRule - правило антифрода. Check возвращает решение и причину;
+// types.RiskAllow означает, что правило не сработало.
+type RiskRule struct {
+	Name  string
+	Check func(request RiskRequest) (types.RiskDecision, string)
+}
+
+// RiskError сообщает, какие правила заблокировали платёж.
+// errors.Is(err, ErrPaymentBlocked) для неё возвращает true.
+type RiskError struct {
+	Checks []types.RiskCheck
+}
+
+func (e *RiskError) Error() string {
+	rules := []string{}
+	for _, check := range e.Checks {
+		if check.Decision == types.RiskBlock {
+			rules = append(rules, check.Rule+" ("+check.Reason+")")
+		}
+	}
+
+	return fmt.Sprintf("%v: %v", ErrPaymentBlocked, strings.Join(rules, ", "))
+}
+
+func (e *RiskError) Unwrap() error {
+	return ErrPaymentBlocked
+}
+
+// SetRiskRules задаёт правила антифрода, проверяемые перед каждым платежом.
+// Если хоть одно правило блокирует платёж, Pay возвращает RiskError и ничего не списывает;
+// если хоть одно отправляет на проверку, платёж создаётся в статусе types.PaymentStatusReview.
+// Authorize и Capture проверяются так же: заблокированное резервирование не создаётся,
+// а при Capture правила проверяют списываемую сумму и решают судьбу создаваемого платежа.
+// Результаты всех правил сохраняются в Payment.Risk.
+func (s *Service) SetRiskRules(rules ...RiskRule) {
+	names := []string{}
+	for _, rule := range rules {
+		names = append(names, rule.Name)
+	}
+
+	call := s.beginAudit("SetRiskRules", 0, "", "rules", names)
+	defer call.endUnchecked()
+
+	s.riskRules = rules
+}
+
+// assessRisk проверяет платёж всеми правилами и возвращает их результаты и итоговое решение:
+// самое строгое из решений правил.
+func (s *Service) assessRisk(account *types.Account, amount types.Money, category types.PaymentCategory) ([]types.RiskCheck, types.RiskDecision) {
+	if len(s.riskRules) == 0 {
+		return nil, types.RiskAllow
+	}
+
+	request := RiskRequest{
+		Account:  *account,
+		Amount:   amount,
+		Category: category,
+		RepeatOf: s.repeatOf,
+		Now:      s.now(),
+	}
+	for _, payment := range s.payments {
+		if payment.AccountID == account.ID {
+			request.History = append(request.History, *payment)
+		}
+	}
+
+	checks := []types.RiskCheck{}
+	result := types.RiskAllow
+	for _, rule := range s.riskRules {
+		decision, reason := rule.Check(request)
+		if decision == "" {
+			decision = types.RiskAllow
+		}
+		checks = append(checks, types.RiskCheck{Rule: rule.Name, Decision: decision, Reason: reason})
+
+		if riskSeverity(decision) > riskSeverity(result) {
+			result = decision
+		}
+	}
+
+	return checks, result
+}
+
+// applyRisk сохраняет результаты правил в платеже и отправляет его на проверку, если этого требует decision.
+func (s *Service) applyRisk(payment *types.Payment, checks []types.RiskCheck, decision types.RiskDecision) {
+	payment.Risk = checks
+	if decision == types.RiskReview {
+		payment.Status = types.PaymentStatusReview
+		s.publish(paymentEvent(EventPaymentInReview, payment))
+	}
+}
+
+func riskSeverity(decision types.RiskDecision) int {
+	switch decision {
+	case types.RiskBlock:
+		return 2
+	case types.RiskReview:
+		return 1
+	}
+
+	return 0
+}
+
+// ApprovePayment выпускает платёж, задержанный антифродом. Отклонить его можно через Reject.
+func (s *Service) ApprovePayment(paymentID string) (err error) {
+	call := s.beginAudit("ApprovePayment", 0, paymentID)
+	defer func() { err = call.end("", err) }()
+
+	payment, err := s.FindPaymentByID(paymentID)
+	if err != nil {
+		return err
+	}
+
+	if payment.Status != types.PaymentStatusReview {
+		return ErrPaymentNotInReview
+	}
+
+	s.touchPayment(payment)
+	payment.Status = types.PaymentStatusInProgress
+	s.publish(paymentEvent(EventPaymentApproved, payment))
+	return nil
+}
+
+// PaymentsInReview возвращает платежи, ожидающие решения по антифроду.
+func (s *Service) PaymentsInReview() []types.Payment {
+	result := []types.Payment{}
+	for _, payment := range s.payments {
+		if payment.Status == types.PaymentStatusReview {
+			result = append(result, *payment)
+		}
+	}
+
+	return result
+}
+
+// UnusualAmountRule отправляет на проверку платёж, превышающий среднюю сумму
+// прежних неотклонённых платежей аккаунта в multiplier раз. Пока таких платежей меньше minHistory,
+// правило не срабатывает.
+func UnusualAmountRule(multiplier int64, minHistory int) RiskRule {
+	return RiskRule{
+		Name: RiskRuleUnusualAmount,
+		Check: func(request RiskRequest) (types.RiskDecision, string) {
+			count := int64(0)
+			total := types.Money(0)
+			for _, payment := range request.History {
+				if payment.Status == types.PaymentStatusFail {
+					continue
+				}
+				count++
+				total += payment.Amount
+			}
+
+			if count == 0 || count < int64(minHistory) {
+				return types.RiskAllow, ""
+			}
+
+			average := total / types.Money(count)
+			if int64(request.Amount) > int64(average)*multiplier {
+				return types.RiskReview, fmt.Sprintf("amount %v is over %v times the average %v", request.Amount, multiplier, average)
+			}
+
+			return types.RiskAllow, ""
+		},
+	}
+}
+
+// RapidRepeatRule блокирует повтор платежа (Repeat), если за последние window
+// у аккаунта уже есть maxPayments неотклонённых платежей с той же суммой и категорией.
+func RapidRepeatRule(window time.Duration, maxPayments int) RiskRule {
+	return RiskRule{
+		Name: RiskRuleRapidRepeat,
+		Check: func(request RiskRequest) (types.RiskDecision, string) {
+			if request.RepeatOf == "" {
+				return types.RiskAllow, ""
+			}
+
+			since := request.Now.Add(-window)
+			count := 0
+			for _, payment := range request.History {
+				if payment.Status == types.PaymentStatusFail || payment.CreatedAt.Before(since) {
+					continue
+				}
+				if payment.Amount == request.Amount && payment.Category == request.Category {
+					count++
+				}
+			}
+
+			if count >= maxPayments {
+				return types.RiskBlock, fmt.Sprintf("%v same payments in %v", count, window)
+			}
+
+			return types.RiskAllow, ""
+		},
+	}
+}
+
+// NewAccountRule отправляет на проверку платёж больше maxAmount с аккаунта,
+// зарегистрированного менее age назад. Аккаунты без времени регистрации новыми не считаются.
+func NewAccountRule(age time.Duration, maxAmount types.Money) RiskRule {
+	return RiskRule{
+		Name: RiskRuleNewAccount,
+		Check: func(request RiskRequest) (types.RiskDecision, string) {
+			createdAt := request.Account.CreatedAt
+			if createdAt.IsZero() || !request.Now.Before(createdAt.Add(age)) {
+				return types.RiskAllow, ""
+			}
+
+			if request.Amount > maxAmount {
+				return types.RiskReview, fmt.Sprintf("amount %v over %v for account younger than %v", request.Amount, maxAmount, age)
+			}
+
+			return types.RiskAllow, ""
+		},
+	}
+}
+
+// CategoryBlacklistRule блокирует платежи в категориях categories.
+func CategoryBlacklistRule(categories ...types.PaymentCategory) RiskRule {
+	blacklist := map[types.PaymentCategory]bool{}
+	for _, category := range categories {
+		blacklist[category] = true
+	}
+
+	return RiskRule{
+		Name: RiskRuleCategoryBlacklist,
+		Check: func(request RiskRequest) (types.RiskDecision, string) {
+			if blacklist[request.Category] {
+				return types.RiskBlock, fmt.Sprintf("category %v is blacklisted", request.Category)
+			}
+
+			return types.RiskAllow, ""
+		},
+	}
+}
+
+// riskSection выгружает результаты правил антифрода: строка на каждую проверку платежа.
+func (s *Service) riskSection() dumpSection {
+	section := dumpSection{name: "risk.dump"}
+	for _, payment := range s.payments {
+		for _, check := range payment.Risk {
+			section.lines = append(section.lines, encodeRiskCheck(payment.ID, check))
+		}
+	}
+
+	return section
+}
+
+// encodeRiskChecks записывает проверки антифрода одной строкой в формате запроса URL
+// (без разделителей дампа): для ошибки RiskError и записи платежа в LogStore.
+func encodeRiskChecks(checks []types.RiskCheck) string {
+	values := url.Values{}
+	for _, check := range checks {
+		values.Add("rule", check.Rule)
+		values.Add("decision", string(check.Decision))
+		values.Add("reason", check.Reason)
+	}
+
+	return values.Encode()
+}
+
+func decodeRiskChecks(line string) ([]types.RiskCheck, error) {
+	values, err := url.ParseQuery(line)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values["decision"]) != len(values["rule"]) || len(values["reason"]) != len(values["rule"]) {
+		return nil, ErrInvalidDump
+	}
+
+	var checks []types.RiskCheck
+	for i, rule := range values["rule"] {
+		checks = append(checks, types.RiskCheck{
+			Rule:     rule,
+			Decision: types.RiskDecision(values["decision"][i]),
+			Reason:   values["reason"][i],
+		})
+	}
+
+	return checks, nil
+}
+
+func encodeRiskCheck(paymentID string, check types.RiskCheck) string {
+	return paymentID + ";" +
+		auditText(check.Rule) + ";" +
+		string(check.Decision) + ";" +
+		auditText(check.Reason)
+}
+
+// importRiskCheck добавляет проверку к импортированному платежу, заменяя проверку того же правила.
+func (s *Service) importRiskCheck(line string) error {
+	data := strings.Split(line, ";")
+	if len(data) != 4 {
+		return ErrInvalidDump
+	}
+
+	payment, err := s.FindPaymentByID(data[0])
+	if err != nil {
+		return err
+	}
+
+	check := types.RiskCheck{Rule: data[1], Decision: types.RiskDecision(data[2]), Reason: data[3]}
+	s.touchPayment(payment)
+	for i := range payment.Risk {
+		if payment.Risk[i].Rule == check.Rule {
+			payment.Risk[i] = check
+			return nil
+		}
+	}
+
+	payment.Risk = append(payment.Risk, check)
+	return nil
+}
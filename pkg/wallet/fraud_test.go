@@ -0,0 +1,221 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+func TestService_Pay_categoryBlacklist(t *testing.T) {
+	svc, account, _ := newTestService(t, 1_000_000)
+	svc.SetRiskRules(CategoryBlacklistRule("casino"))
+
+	_, err := svc.Pay(account.ID, 100, "casino")
+	if !errors.Is(err, ErrPaymentBlocked) {
+		t.Fatalf("invalid error, got %v, want %v", err, ErrPaymentBlocked)
+	}
+
+	riskErr := &RiskError{}
+	if !errors.As(err, &riskErr) || riskErr.Checks[0].Rule != RiskRuleCategoryBlacklist {
+		t.Errorf("invalid risk error: %v", err)
+	}
+
+	if account.Balance != 1_000_000 || len(svc.payments) != 0 {
+		t.Errorf("blocked payment changed state: balance %v, payments %v", account.Balance, len(svc.payments))
+	}
+
+	payment, err := svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []types.RiskCheck{{Rule: RiskRuleCategoryBlacklist, Decision: types.RiskAllow}}
+	if len(payment.Risk) != 1 || payment.Risk[0] != want[0] {
+		t.Errorf("invalid risk checks, got %+v, want %+v", payment.Risk, want)
+	}
+}
+
+func TestService_Pay_unusualAmountReview(t *testing.T) {
+	svc, account, _ := newTestService(t, 1_000_000)
+	svc.SetRiskRules(UnusualAmountRule(5, 3))
+
+	for i := 0; i < 3; i++ {
+		_, err := svc.Pay(account.ID, 100, "auto")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	payment, err := svc.Pay(account.ID, 501, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if payment.Status != types.PaymentStatusReview || payment.Risk[0].Decision != types.RiskReview {
+		t.Fatalf("invalid payment: %+v", payment)
+	}
+
+	if len(svc.PaymentsInReview()) != 1 {
+		t.Errorf("invalid payments in review: %+v", svc.PaymentsInReview())
+	}
+
+	err = svc.ApprovePayment(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if payment.Status != types.PaymentStatusInProgress {
+		t.Errorf("invalid status after approve: %v", payment.Status)
+	}
+
+	err = svc.ApprovePayment(payment.ID)
+	if !errors.Is(err, ErrPaymentNotInReview) {
+		t.Errorf("invalid error, got %v, want %v", err, ErrPaymentNotInReview)
+	}
+}
+
+func TestService_Repeat_rapidRepeatBlocked(t *testing.T) {
+	svc, account, now := newTestService(t, 1_000_000)
+	svc.SetRiskRules(RapidRepeatRule(time.Minute, 2))
+
+	payment, err := svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// обычный платёж той же суммой повтором не считается
+	_, err = svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Repeat(payment.ID)
+	if !errors.Is(err, ErrPaymentBlocked) {
+		t.Fatalf("invalid error, got %v, want %v", err, ErrPaymentBlocked)
+	}
+
+	*now = now.Add(time.Minute + time.Second)
+	_, err = svc.Repeat(payment.ID)
+	if err != nil {
+		t.Errorf("repeat after window: %v", err)
+	}
+}
+
+func TestService_Pay_newAccountReview(t *testing.T) {
+	svc, account, now := newTestService(t, 1_000_000)
+	svc.SetRiskRules(NewAccountRule(24*time.Hour, 10_000))
+
+	payment, err := svc.Pay(account.ID, 10_001, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Status != types.PaymentStatusReview {
+		t.Errorf("invalid status for new account: %v", payment.Status)
+	}
+
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 1_000_000 {
+		t.Errorf("invalid balance after reject: %v", account.Balance)
+	}
+
+	*now = now.Add(24 * time.Hour)
+	payment, err = svc.Pay(account.ID, 10_001, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Status != types.PaymentStatusInProgress {
+		t.Errorf("invalid status for old account: %v", payment.Status)
+	}
+}
+
+func TestService_Authorize_riskRules(t *testing.T) {
+	svc, account, _ := newTestService(t, 1_000_000)
+	svc.SetRiskRules(CategoryBlacklistRule("casino"))
+
+	_, err := svc.Authorize(account.ID, 100, "casino")
+	if !errors.Is(err, ErrPaymentBlocked) {
+		t.Fatalf("invalid error, got %v, want %v", err, ErrPaymentBlocked)
+	}
+	if account.Held != 0 || len(svc.holds) != 0 {
+		t.Errorf("blocked hold changed state: held %v, holds %v", account.Held, len(svc.holds))
+	}
+
+	hold, err := svc.Authorize(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc.SetRiskRules(CategoryBlacklistRule("auto"))
+	_, err = svc.Capture(hold.ID, 100)
+	if !errors.Is(err, ErrPaymentBlocked) {
+		t.Fatalf("invalid error, got %v, want %v", err, ErrPaymentBlocked)
+	}
+	if account.Balance != 1_000_000 || account.Held != 100 || hold.Status != types.HoldStatusActive {
+		t.Errorf("blocked capture changed state: balance %v, held %v, status %v", account.Balance, account.Held, hold.Status)
+	}
+}
+
+func TestService_Capture_newAccountReview(t *testing.T) {
+	svc, account, _ := newTestService(t, 1_000_000)
+	svc.SetRiskRules(NewAccountRule(24*time.Hour, 10_000))
+
+	hold, err := svc.Authorize(account.ID, 20_000, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := svc.Capture(hold.ID, 10_001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Status != types.PaymentStatusReview || len(payment.Risk) != 1 {
+		t.Errorf("invalid captured payment: %+v", payment)
+	}
+	if len(svc.PaymentsInReview()) != 1 {
+		t.Errorf("captured payment not in review: %+v", svc.PaymentsInReview())
+	}
+}
+
+func TestService_Export_riskChecks(t *testing.T) {
+	svc, account, _ := newTestService(t, 1_000_000)
+	svc.SetRiskRules(NewAccountRule(time.Hour, 10), CategoryBlacklistRule("casino"))
+
+	payment, err := svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported := &Service{}
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := imported.FindPaymentByID(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Status != types.PaymentStatusReview || len(got.Risk) != 2 || got.Risk[0] != payment.Risk[0] {
+		t.Errorf("invalid imported payment: %+v, want %+v", got, payment)
+	}
+
+	importedAccount, err := imported.FindAccountByID(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !importedAccount.CreatedAt.Equal(account.CreatedAt) {
+		t.Errorf("invalid imported account creation time: %v, want %v", importedAccount.CreatedAt, account.CreatedAt)
+	}
+}
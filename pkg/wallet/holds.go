@@ -0,0 +1,342 @@
+package wallet
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+var (
+	ErrHoldNotFound       = errors.New("hold not found")
+	ErrHoldNotActive      = errors.New("hold is not active")
+	ErrCaptureExceedsHold = errors.New("capture amount exceeds hold")
+)
+
+// DefaultHoldTTL - через сколько неиспользованное резервирование снимается автоматически,
+// пока не вызван SetHoldTTL.
+const DefaultHoldTTL = 7 * 24 * time.Hour
+
+// SetHoldTTL задаёт срок жизни резервирований.
+func (s *Service) SetHoldTTL(ttl time.Duration) {
+	call := s.beginAudit("SetHoldTTL", 0, "", "ttl", ttl)
+	defer call.endUnchecked()
+
+	s.holdTTL = ttl
+}
+
+func (s *Service) currentHoldTTL() time.Duration {
+	if s.holdTTL <= 0 {
+		return DefaultHoldTTL
+	}
+
+	return s.holdTTL
+}
+
+// Authorize резервирует amount и комиссию за платёж на эту сумму: доступный остаток уменьшается,
+// а баланс остаётся прежним до Capture.
+func (s *Service) Authorize(accountID int64, amount types.Money, category types.PaymentCategory) (hold *types.Hold, err error) {
+	call := s.beginAudit("Authorize", accountID, "", "amount", amount, "category", category)
+	defer func() { err = call.endHold(hold, err) }()
+
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+
+	err = checkText(string(category))
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkAccountActive(account)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkLimits(accountID, amount, category)
+	if err != nil {
+		return nil, err
+	}
+
+	s.ExpireHolds()
+
+	checks, decision := s.assessRisk(account, amount, category)
+	if decision == types.RiskBlock {
+		return nil, &RiskError{Checks: checks}
+	}
+
+	fee := s.calculateFee(account, amount, category)
+	if account.Balance+account.Overdraft-account.Held < amount+fee {
+		return nil, ErrNotEnoughBalance
+	}
+
+	now := s.now()
+	hold = &types.Hold{
+		ID:        s.newID(),
+		AccountID: accountID,
+		Amount:    amount,
+		Category:  category,
+		Status:    types.HoldStatusActive,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.currentHoldTTL()),
+		Fee:       fee,
+	}
+
+	s.touchAccount(account)
+	account.Held += reservedAmount(hold)
+	s.addHold(hold)
+	s.publish(holdEvent(EventHoldAuthorized, hold))
+
+	return hold, nil
+}
+
+// reservedAmount - сколько резервирование удерживает на аккаунте: сумма и комиссия.
+func reservedAmount(hold *types.Hold) types.Money {
+	return hold.Amount + hold.Fee
+}
+
+func (s *Service) FindHoldByID(holdID string) (*types.Hold, error) {
+	for _, hold := range s.holds {
+		if hold.ID == holdID {
+			return hold, nil
+		}
+	}
+
+	return nil, ErrHoldNotFound
+}
+
+// findActiveHold ищет резервирование, которое ещё можно списать или отменить.
+func (s *Service) findActiveHold(holdID string) (*types.Hold, *types.Account, error) {
+	s.ExpireHolds()
+
+	hold, err := s.FindHoldByID(holdID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if hold.Status != types.HoldStatusActive {
+		return nil, nil, ErrHoldNotActive
+	}
+
+	account, err := s.FindAccountByID(hold.AccountID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return hold, account, nil
+}
+
+// Capture списывает amount (не больше зарезервированного) с комиссией по резервированию holdID
+// и создаёт платёж. Незахваченный остаток резерва освобождается. Лимиты проверяются заново,
+// потому что с момента Authorize могли пройти другие платежи.
+func (s *Service) Capture(holdID string, amount types.Money) (payment *types.Payment, err error) {
+	call := s.beginAudit("Capture", 0, "", "holdID", holdID, "amount", amount)
+	defer func() { err = call.endPayment(payment, err) }()
+
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+
+	hold, account, err := s.findActiveHold(holdID)
+	if err != nil {
+		return nil, err
+	}
+
+	if amount > hold.Amount {
+		return nil, ErrCaptureExceedsHold
+	}
+
+	err = checkAccountActive(account)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkLimits(account.ID, amount, hold.Category)
+	if err != nil {
+		return nil, err
+	}
+
+	checks, decision := s.assessRisk(account, amount, hold.Category)
+	if decision == types.RiskBlock {
+		return nil, &RiskError{Checks: checks}
+	}
+
+	s.touchAccount(account)
+	account.Held -= reservedAmount(hold)
+	payment, err = s.debit(account, amount, hold.Category)
+	if err != nil {
+		account.Held += reservedAmount(hold)
+		return nil, err
+	}
+	s.applyRisk(payment, checks, decision)
+
+	s.touchHold(hold)
+	hold.Status = types.HoldStatusCaptured
+	hold.PaymentID = payment.ID
+	s.publish(holdEvent(EventHoldCaptured, hold))
+
+	return payment, nil
+}
+
+// Release отменяет резервирование и возвращает средства в доступный остаток.
+func (s *Service) Release(holdID string) (err error) {
+	call := s.beginAudit("Release", 0, "", "holdID", holdID)
+	defer func() { err = call.end("", err) }()
+
+	hold, account, err := s.findActiveHold(holdID)
+	if err != nil {
+		return err
+	}
+
+	s.touchAccount(account)
+	s.touchHold(hold)
+	account.Held -= reservedAmount(hold)
+	hold.Status = types.HoldStatusReleased
+	s.publish(holdEvent(EventHoldReleased, hold))
+
+	return nil
+}
+
+// releaseAccountHolds отменяет все активные резервирования аккаунта (перед его закрытием).
+func (s *Service) releaseAccountHolds(account *types.Account) {
+	for _, hold := range s.holds {
+		if hold.AccountID != account.ID || hold.Status != types.HoldStatusActive {
+			continue
+		}
+
+		s.touchAccount(account)
+		s.touchHold(hold)
+		account.Held -= reservedAmount(hold)
+		hold.Status = types.HoldStatusReleased
+		s.publish(holdEvent(EventHoldReleased, hold))
+	}
+}
+
+// ExpireHolds снимает резервирования с истёкшим сроком и возвращает их.
+// Вызывается автоматически перед платежами и операциями с резервированиями.
+func (s *Service) ExpireHolds() []types.Hold {
+	call := s.beginAudit("ExpireHolds", 0, "")
+	defer call.endUnchecked()
+
+	now := s.now()
+	expired := []types.Hold{}
+
+	for _, hold := range s.holds {
+		if hold.Status != types.HoldStatusActive || now.Before(hold.ExpiresAt) {
+			continue
+		}
+
+		account, err := s.FindAccountByID(hold.AccountID)
+		if err == nil {
+			s.touchAccount(account)
+			account.Held -= reservedAmount(hold)
+		}
+		s.touchHold(hold)
+		hold.Status = types.HoldStatusExpired
+		expired = append(expired, *hold)
+		s.publish(holdEvent(EventHoldExpired, hold))
+	}
+
+	return expired
+}
+
+func holdFields(hold *types.Hold) []string {
+	return []string{
+		hold.ID,
+		strconv.FormatInt(hold.AccountID, 10),
+		strconv.FormatInt(int64(hold.Amount), 10),
+		string(hold.Category),
+		string(hold.Status),
+		hold.PaymentID,
+		encodeTime(hold.CreatedAt),
+		encodeTime(hold.ExpiresAt),
+		strconv.FormatInt(int64(hold.Fee), 10),
+	}
+}
+
+func encodeHold(hold *types.Hold) string {
+	return strings.Join(holdFields(hold), ";")
+}
+
+func decodeHold(line string) (*types.Hold, error) {
+	return decodeHoldFields(strings.Split(line, ";"))
+}
+
+func decodeHoldFields(data []string) (*types.Hold, error) {
+	if len(data) < 8 {
+		return nil, ErrInvalidDump
+	}
+
+	accountID, err := strconv.ParseInt(data[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	amount, err := strconv.ParseInt(data[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt, err := decodeTime(data[6])
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt, err := decodeTime(data[7])
+	if err != nil {
+		return nil, err
+	}
+
+	// в дампах, сохранённых до резервирования комиссии, её нет
+	var fee int64
+	if len(data) > 8 {
+		fee, err = strconv.ParseInt(data[8], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &types.Hold{
+		ID:        data[0],
+		AccountID: accountID,
+		Amount:    types.Money(amount),
+		Category:  types.PaymentCategory(data[3]),
+		Status:    types.HoldStatus(data[4]),
+		PaymentID: data[5],
+		CreatedAt: createdAt,
+		ExpiresAt: expiresAt,
+		Fee:       types.Money(fee),
+	}, nil
+}
+
+// importHold добавляет или обновляет резервирование. Account.Held импортируется вместе с аккаунтом.
+func (s *Service) importHold(line string) error {
+	hold, err := decodeHold(line)
+	if err != nil {
+		return err
+	}
+
+	s.upsertHold(hold)
+	return nil
+}
+
+func (s *Service) upsertHold(hold *types.Hold) {
+	s.observeID(hold.ID)
+
+	for _, holdCheck := range s.holds {
+		if holdCheck.ID == hold.ID {
+			s.touchHold(holdCheck)
+			*holdCheck = *hold
+			return
+		}
+	}
+
+	s.addHold(hold)
+}
@@ -0,0 +1,221 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+func TestService_Authorize(t *testing.T) {
+	svc, account, _ := newTestService(t, 1000)
+
+	_, err := svc.Authorize(account.ID, 1001, "shop")
+	if err != ErrNotEnoughBalance {
+		t.Errorf("invalid error, got %v, want %v", err, ErrNotEnoughBalance)
+	}
+
+	_, err = svc.Authorize(account.ID, 600, "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if account.Balance != 1000 || account.Available() != 400 {
+		t.Errorf("invalid balances, current %v, available %v", account.Balance, account.Available())
+	}
+
+	_, err = svc.Pay(account.ID, 500, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Errorf("held funds must not be spent, got %v", err)
+	}
+
+	_, err = svc.Pay(account.ID, 400, "auto")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestService_Capture(t *testing.T) {
+	svc, account, _ := newTestService(t, 1000)
+
+	hold, err := svc.Authorize(account.ID, 600, "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Capture(hold.ID, 601)
+	if err != ErrCaptureExceedsHold {
+		t.Errorf("invalid error, got %v, want %v", err, ErrCaptureExceedsHold)
+	}
+
+	payment, err := svc.Capture(hold.ID, 450)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if payment.Amount != 450 || payment.Category != "shop" || hold.PaymentID != payment.ID {
+		t.Errorf("invalid payment: %v", payment)
+	}
+
+	if account.Balance != 550 || account.Held != 0 || hold.Status != types.HoldStatusCaptured {
+		t.Errorf("invalid state, balance %v, held %v, status %v", account.Balance, account.Held, hold.Status)
+	}
+
+	_, err = svc.Capture(hold.ID, 100)
+	if err != ErrHoldNotActive {
+		t.Errorf("invalid error, got %v, want %v", err, ErrHoldNotActive)
+	}
+}
+
+func TestService_Capture_fee(t *testing.T) {
+	svc, account, _ := newTestService(t, 1000)
+	house := setHouseAccount(t, svc, FeeRule{BasisPoints: 1000})
+
+	// на сумму хватает, на сумму с комиссией - нет
+	_, err := svc.Authorize(account.ID, 1000, "shop")
+	if err != ErrNotEnoughBalance {
+		t.Errorf("invalid error, got %v, want %v", err, ErrNotEnoughBalance)
+	}
+
+	hold, err := svc.Authorize(account.ID, 900, "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hold.Fee != 90 || account.Held != 990 {
+		t.Errorf("invalid reservation, fee %v, held %v", hold.Fee, account.Held)
+	}
+
+	payment, err := svc.Capture(hold.ID, 900)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Fee != 90 || account.Balance != 10 || account.Held != 0 || house.Balance != 90 {
+		t.Errorf("invalid state, payment %+v, balance %v, held %v, house %v", payment, account.Balance, account.Held, house.Balance)
+	}
+}
+
+func TestService_Capture_limits(t *testing.T) {
+	svc, account, _ := newTestService(t, 1000)
+
+	hold, err := svc.Authorize(account.ID, 300, "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Pay(account.ID, 200, "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.SetAccountLimit(account.ID, Limit{DailyTotal: 400})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Capture(hold.ID, 300)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Rule != LimitRuleDailyTotal {
+		t.Fatalf("invalid error, got %v, want daily limit", err)
+	}
+	if account.Held != 300 || hold.Status != types.HoldStatusActive {
+		t.Errorf("hold changed after rejected capture: held %v, status %v", account.Held, hold.Status)
+	}
+}
+
+func TestService_Release(t *testing.T) {
+	svc, account, _ := newTestService(t, 1000)
+
+	hold, err := svc.Authorize(account.ID, 600, "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Release(hold.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if account.Balance != 1000 || account.Available() != 1000 || hold.Status != types.HoldStatusReleased {
+		t.Errorf("invalid state, balance %v, available %v, status %v", account.Balance, account.Available(), hold.Status)
+	}
+
+	err = svc.Release(hold.ID)
+	if err != ErrHoldNotActive {
+		t.Errorf("invalid error, got %v, want %v", err, ErrHoldNotActive)
+	}
+
+	err = svc.Release("unknown")
+	if err != ErrHoldNotFound {
+		t.Errorf("invalid error, got %v, want %v", err, ErrHoldNotFound)
+	}
+}
+
+func TestService_ExpireHolds(t *testing.T) {
+	svc, account, now := newTestService(t, 1000)
+	svc.SetHoldTTL(time.Hour)
+
+	hold, err := svc.Authorize(account.ID, 1000, "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*now = now.Add(time.Hour)
+
+	_, err = svc.Pay(account.ID, 1000, "auto")
+	if err != nil {
+		t.Fatalf("expired hold must not block payments: %v", err)
+	}
+
+	if hold.Status != types.HoldStatusExpired || account.Held != 0 {
+		t.Errorf("invalid state, status %v, held %v", hold.Status, account.Held)
+	}
+
+	_, err = svc.Capture(hold.ID, 100)
+	if err != ErrHoldNotActive {
+		t.Errorf("invalid error, got %v, want %v", err, ErrHoldNotActive)
+	}
+}
+
+func TestService_ExportImport_holds(t *testing.T) {
+	dir := t.TempDir()
+	svc, account, now := newTestService(t, 1000)
+
+	hold, err := svc.Authorize(account.ID, 600, "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newSvc := &Service{}
+	newSvc.SetClock(func() time.Time {
+		return *now
+	})
+
+	err = newSvc.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := newSvc.FindAccountByID(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if imported.Held != 600 {
+		t.Errorf("invalid held, got %v, want %v", imported.Held, 600)
+	}
+
+	_, err = newSvc.Capture(hold.ID, 600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if imported.Balance != 400 || imported.Held != 0 {
+		t.Errorf("invalid state, balance %v, held %v", imported.Balance, imported.Held)
+	}
+}
@@ -0,0 +1,350 @@
+package wallet
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+var (
+	ErrInvalidIdempotencyKey = errors.New("invalid idempotency key")
+	ErrIdempotencyKeyReused  = errors.New("idempotency key used for another request")
+)
+
+// DefaultIdempotencyTTL - сколько хранится результат вызова с ключом идемпотентности,
+// пока не вызван SetIdempotencyTTL.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// idempotencyRecord - сохранённый результат вызова с ключом идемпотентности.
+type idempotencyRecord struct {
+	key       string
+	request   string
+	paymentID string
+	err       error
+	createdAt time.Time
+}
+
+// IdempotencyRecord - результат вызова с ключом идемпотентности в хранилище (см. Store).
+type IdempotencyRecord struct {
+	Key string
+	// Request - параметры вызова, PaymentID - созданный платёж
+	Request   string
+	PaymentID string
+	// Error - ошибка вызова в формате дампа, пустая, если вызов успешен
+	Error     string
+	CreatedAt time.Time
+}
+
+func storedIdempotency(record *idempotencyRecord) IdempotencyRecord {
+	return IdempotencyRecord{
+		Key:       record.key,
+		Request:   record.request,
+		PaymentID: record.paymentID,
+		Error:     encodeError(record.err),
+		CreatedAt: record.createdAt,
+	}
+}
+
+func loadedIdempotency(record IdempotencyRecord) *idempotencyRecord {
+	return &idempotencyRecord{
+		key:       record.Key,
+		request:   record.Request,
+		paymentID: record.PaymentID,
+		err:       decodeError(record.Error),
+		createdAt: record.CreatedAt,
+	}
+}
+
+// SetIdempotencyTTL задаёт, сколько хранятся результаты вызовов с ключами идемпотентности.
+func (s *Service) SetIdempotencyTTL(ttl time.Duration) {
+	call := s.beginAudit("SetIdempotencyTTL", 0, "", "ttl", ttl)
+	defer call.endUnchecked()
+
+	s.idempotencyTTL = ttl
+}
+
+func (s *Service) currentIdempotencyTTL() time.Duration {
+	if s.idempotencyTTL <= 0 {
+		return DefaultIdempotencyTTL
+	}
+
+	return s.idempotencyTTL
+}
+
+// purgeIdempotency удаляет записи с истёкшим сроком хранения.
+func (s *Service) purgeIdempotency() {
+	expired := s.now().Add(-s.currentIdempotencyTTL())
+	for key, record := range s.idempotency {
+		if !record.createdAt.After(expired) {
+			s.setIdempotency(key, nil)
+		}
+	}
+}
+
+// setIdempotency сохраняет запись record для ключа key; nil удаляет запись.
+func (s *Service) setIdempotency(key string, record *idempotencyRecord) {
+	previous, ok := s.idempotency[key]
+	s.touchIdempotency(key)
+	s.onRollback(func() {
+		if ok {
+			s.idempotency[key] = previous
+		} else {
+			delete(s.idempotency, key)
+		}
+	})
+
+	if record == nil {
+		delete(s.idempotency, key)
+		return
+	}
+
+	if s.idempotency == nil {
+		s.idempotency = map[string]*idempotencyRecord{}
+	}
+	s.idempotency[key] = record
+}
+
+// idempotent выполняет action не более одного раза для ключа key.
+// Повторный вызов с тем же ключом и теми же параметрами request возвращает исходный
+// платёж или ошибку, с другими параметрами - ErrIdempotencyKeyReused.
+// Пустой ключ отключает идемпотентность.
+func (s *Service) idempotent(key string, request string, action func() (*types.Payment, error)) (*types.Payment, error) {
+	if key == "" {
+		return action()
+	}
+
+	if strings.ContainsAny(key, ";\n") {
+		return nil, ErrInvalidIdempotencyKey
+	}
+
+	s.purgeIdempotency()
+
+	record, ok := s.idempotency[key]
+	if ok {
+		if record.request != request {
+			return nil, ErrIdempotencyKeyReused
+		}
+
+		if record.paymentID == "" {
+			return nil, record.err
+		}
+
+		payment, err := s.FindPaymentByID(record.paymentID)
+		if err != nil {
+			return nil, err
+		}
+		return payment, record.err
+	}
+
+	payment, err := action()
+
+	record = &idempotencyRecord{
+		key:       key,
+		request:   request,
+		err:       err,
+		createdAt: s.now(),
+	}
+	if payment != nil {
+		record.paymentID = payment.ID
+	}
+	s.setIdempotency(key, record)
+
+	return payment, err
+}
+
+// DepositIdempotent - Deposit с ключом идемпотентности key.
+func (s *Service) DepositIdempotent(key string, accountID int64, amount types.Money) (err error) {
+	call := s.beginAudit("DepositIdempotent", accountID, "", "key", key, "amount", amount)
+	defer func() { err = call.end("", err) }()
+
+	request := "deposit;" + strconv.FormatInt(accountID, 10) + ";" + strconv.FormatInt(int64(amount), 10)
+	_, err = s.idempotent(key, request, func() (*types.Payment, error) {
+		return nil, s.Deposit(accountID, amount)
+	})
+
+	return err
+}
+
+// PayIdempotent - Pay с ключом идемпотентности key: повтор с тем же ключом
+// не списывает деньги снова, а возвращает исходный платёж.
+func (s *Service) PayIdempotent(key string, accountID int64, amount types.Money, category types.PaymentCategory) (payment *types.Payment, err error) {
+	call := s.beginAudit("PayIdempotent", accountID, "", "key", key, "amount", amount, "category", category)
+	defer func() { err = call.endPayment(payment, err) }()
+
+	request := "pay;" + strconv.FormatInt(accountID, 10) + ";" + strconv.FormatInt(int64(amount), 10) + ";" + string(category)
+	return s.idempotent(key, request, func() (*types.Payment, error) {
+		return s.Pay(accountID, amount, category)
+	})
+}
+
+// RepeatIdempotent - Repeat с ключом идемпотентности key.
+func (s *Service) RepeatIdempotent(key string, paymentID string) (payment *types.Payment, err error) {
+	call := s.beginAudit("RepeatIdempotent", 0, "", "key", key, "paymentID", paymentID)
+	defer func() { err = call.endPayment(payment, err) }()
+
+	return s.idempotent(key, "repeat;"+paymentID, func() (*types.Payment, error) {
+		return s.Repeat(paymentID)
+	})
+}
+
+// PayFromFavoriteIdempotent - PayFromFavorite с ключом идемпотентности key.
+func (s *Service) PayFromFavoriteIdempotent(key string, favoriteID string) (payment *types.Payment, err error) {
+	call := s.beginAudit("PayFromFavoriteIdempotent", 0, "", "key", key, "favoriteID", favoriteID)
+	defer func() { err = call.endPayment(payment, err) }()
+
+	return s.idempotent(key, "favorite;"+favoriteID, func() (*types.Payment, error) {
+		return s.PayFromFavorite(favoriteID)
+	})
+}
+
+// knownErrors позволяет восстановить из снимка исходные значения ошибок,
+// чтобы сравнение err == ErrNotEnoughBalance работало и после Import.
+var knownErrors = []error{
+	ErrAmountMustBePositive,
+	ErrAccountNotFound,
+	ErrNotEnoughBalance,
+	ErrPaymentNotFound,
+	ErrFavoriteNotFound,
+	ErrAccountFrozen,
+	ErrAccountClosed,
+	ErrLimitExceeded,
+	ErrPaymentBlocked,
+	ErrInvalidText,
+}
+
+// Префиксы ошибок с полями: поля записываются в формате запроса URL,
+// чтобы после Import работали errors.As(err, &LimitError{}) и errors.As(err, &RiskError{}).
+const (
+	limitErrorPrefix = "limit:"
+	riskErrorPrefix  = "risk:"
+)
+
+// encodeError записывает ошибку без разделителей формата дампа.
+func encodeError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	limitErr := &LimitError{}
+	if errors.As(err, &limitErr) {
+		return limitErrorPrefix + url.Values{
+			"rule":     {limitErr.Rule},
+			"account":  {strconv.FormatInt(limitErr.AccountID, 10)},
+			"category": {string(limitErr.Category)},
+			"limit":    {strconv.FormatInt(limitErr.Limit, 10)},
+		}.Encode()
+	}
+
+	riskErr := &RiskError{}
+	if errors.As(err, &riskErr) {
+		return riskErrorPrefix + encodeRiskChecks(riskErr.Checks)
+	}
+
+	return auditText(err.Error())
+}
+
+func decodeError(message string) error {
+	if message == "" {
+		return nil
+	}
+
+	if strings.HasPrefix(message, limitErrorPrefix) {
+		values, err := url.ParseQuery(strings.TrimPrefix(message, limitErrorPrefix))
+		if err == nil {
+			accountID, _ := strconv.ParseInt(values.Get("account"), 10, 64)
+			limit, _ := strconv.ParseInt(values.Get("limit"), 10, 64)
+			return &LimitError{
+				Rule:      values.Get("rule"),
+				AccountID: accountID,
+				Category:  types.PaymentCategory(values.Get("category")),
+				Limit:     limit,
+			}
+		}
+	}
+
+	if strings.HasPrefix(message, riskErrorPrefix) {
+		checks, err := decodeRiskChecks(strings.TrimPrefix(message, riskErrorPrefix))
+		if err == nil {
+			return &RiskError{Checks: checks}
+		}
+	}
+
+	for _, err := range knownErrors {
+		if err.Error() == message {
+			return err
+		}
+	}
+
+	return errors.New(message)
+}
+
+// idempotencyFields записывает ключ, время, платёж, ошибку и параметры запроса.
+// Параметры идут последними, так как сами содержат ";".
+func idempotencyFields(record *idempotencyRecord) []string {
+	return []string{
+		record.key,
+		encodeTime(record.createdAt),
+		record.paymentID,
+		encodeError(record.err),
+		record.request,
+	}
+}
+
+func encodeIdempotency(record *idempotencyRecord) string {
+	return strings.Join(idempotencyFields(record), ";")
+}
+
+func decodeIdempotency(line string) (*idempotencyRecord, error) {
+	return decodeIdempotencyFields(strings.SplitN(line, ";", 5))
+}
+
+func decodeIdempotencyFields(data []string) (*idempotencyRecord, error) {
+	if len(data) < 5 {
+		return nil, ErrInvalidDump
+	}
+
+	createdAt, err := decodeTime(data[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &idempotencyRecord{
+		key:       data[0],
+		createdAt: createdAt,
+		paymentID: data[2],
+		err:       decodeError(data[3]),
+		request:   data[4],
+	}, nil
+}
+
+func (s *Service) idempotencySection() dumpSection {
+	s.purgeIdempotency()
+
+	keys := []string{}
+	for key := range s.idempotency {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	section := dumpSection{name: "idempotency.dump"}
+	for _, key := range keys {
+		section.lines = append(section.lines, encodeIdempotency(s.idempotency[key]))
+	}
+
+	return section
+}
+
+func (s *Service) importIdempotency(line string) error {
+	record, err := decodeIdempotency(line)
+	if err != nil {
+		return err
+	}
+
+	s.setIdempotency(record.key, record)
+	return nil
+}
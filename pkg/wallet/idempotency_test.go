@@ -0,0 +1,215 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestService_PayIdempotent(t *testing.T) {
+	svc, account, _, now := newScheduledService(t)
+
+	err := svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := svc.PayIdempotent("key-1", account.ID, 300, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retry, err := svc.PayIdempotent("key-1", account.ID, 300, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if retry != first {
+		t.Errorf("retry must return original payment, got %v, want %v", retry, first)
+	}
+
+	if account.Balance != 700 {
+		t.Errorf("retry must not debit again, balance %v, want %v", account.Balance, 700)
+	}
+
+	_, err = svc.PayIdempotent("key-1", account.ID, 400, "auto")
+	if err != ErrIdempotencyKeyReused {
+		t.Errorf("invalid error, got %v, want %v", err, ErrIdempotencyKeyReused)
+	}
+
+	*now = now.Add(DefaultIdempotencyTTL)
+	expired, err := svc.PayIdempotent("key-1", account.ID, 300, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expired == first || account.Balance != 400 {
+		t.Errorf("expired key must execute again, balance %v", account.Balance)
+	}
+}
+
+func TestService_PayIdempotent_error(t *testing.T) {
+	svc, account, _, _ := newScheduledService(t)
+
+	_, err := svc.PayIdempotent("key-1", account.ID, 300, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Fatalf("invalid error, got %v, want %v", err, ErrNotEnoughBalance)
+	}
+
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.PayIdempotent("key-1", account.ID, 300, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Errorf("retry must return original error, got %v, want %v", err, ErrNotEnoughBalance)
+	}
+
+	_, err = svc.PayIdempotent("bad;key", account.ID, 300, "auto")
+	if err != ErrInvalidIdempotencyKey {
+		t.Errorf("invalid error, got %v, want %v", err, ErrInvalidIdempotencyKey)
+	}
+}
+
+func TestService_DepositIdempotent(t *testing.T) {
+	svc, account, favorite, _ := newScheduledService(t)
+
+	for i := 0; i < 3; i++ {
+		err := svc.DepositIdempotent("deposit-1", account.ID, 500)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if account.Balance != 500 {
+		t.Errorf("invalid balance, got %v, want %v", account.Balance, 500)
+	}
+
+	first, err := svc.PayFromFavoriteIdempotent("favorite-1", favorite.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retry, err := svc.PayFromFavoriteIdempotent("favorite-1", favorite.ID)
+	if err != nil || retry != first {
+		t.Errorf("retry must return original payment, got %v, %v", retry, err)
+	}
+
+	repeated, err := svc.RepeatIdempotent("repeat-1", first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retry, err = svc.RepeatIdempotent("repeat-1", first.ID)
+	if err != nil || retry != repeated {
+		t.Errorf("retry must return original payment, got %v, %v", retry, err)
+	}
+
+	if account.Balance != 300 {
+		t.Errorf("invalid balance, got %v, want %v", account.Balance, 300)
+	}
+}
+
+func TestService_ExportImport_idempotency(t *testing.T) {
+	dir := t.TempDir()
+	svc, account, _, now := newScheduledService(t)
+
+	err := svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := svc.PayIdempotent("key-1", account.ID, 300, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.PayIdempotent("key-2", account.ID, 5000, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Fatalf("invalid error, got %v, want %v", err, ErrNotEnoughBalance)
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newSvc := &Service{}
+	newSvc.SetClock(func() time.Time {
+		return *now
+	})
+
+	err = newSvc.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retry, err := newSvc.PayIdempotent("key-1", account.ID, 300, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if retry.ID != payment.ID {
+		t.Errorf("retry after import must return original payment, got %v, want %v", retry.ID, payment.ID)
+	}
+
+	_, err = newSvc.PayIdempotent("key-2", account.ID, 5000, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Errorf("invalid error, got %v, want %v", err, ErrNotEnoughBalance)
+	}
+
+	imported, err := newSvc.FindAccountByID(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if imported.Balance != account.Balance {
+		t.Errorf("invalid balance, got %v, want %v", imported.Balance, account.Balance)
+	}
+}
+
+func TestService_ExportImport_idempotencyErrorTypes(t *testing.T) {
+	dir := t.TempDir()
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Deposit(account.ID, 10_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.SetAccountLimit(account.ID, Limit{MaxPayment: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.SetRiskRules(CategoryBlacklistRule("casino"))
+
+	_, limitErr := svc.PayIdempotent("limit", account.ID, 1000, "auto")
+	_, riskErr := svc.PayIdempotent("risk", account.ID, 100, "casino")
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newSvc := &Service{}
+	err = newSvc.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = newSvc.PayIdempotent("limit", account.ID, 1000, "auto")
+	got := &LimitError{}
+	if !errors.As(err, &got) || !errors.Is(err, ErrLimitExceeded) || err.Error() != limitErr.Error() {
+		t.Errorf("invalid replayed limit error, got %v, want %v", err, limitErr)
+	}
+
+	_, err = newSvc.PayIdempotent("risk", account.ID, 100, "casino")
+	gotRisk := &RiskError{}
+	if !errors.As(err, &gotRisk) || !errors.Is(err, ErrPaymentBlocked) || err.Error() != riskErr.Error() {
+		t.Errorf("invalid replayed risk error, got %v, want %v", err, riskErr)
+	}
+}
@@ -0,0 +1,303 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	mathrand "math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrAccountIDTaken = errors.New("generated account id already taken")
+
+// maxAccountIDAttempts - сколько раз запрашивать у генератора ID аккаунта, совпадающий с занятым.
+const maxAccountIDAttempts = 100
+
+// IDGenerator выдаёт строковые ID платежей, избранного, резервов и расписаний
+// и числовые ID аккаунтов (types.Account.ID).
+type IDGenerator interface {
+	// NewID возвращает новый ID для объекта, созданного в момент now (по часам сервиса).
+	NewID(now time.Time) string
+	// Observe сообщает об ID, загруженном через Import, чтобы новые ID с ним не совпали
+	// и сохранили порядок. Генераторы случайных ID его игнорируют.
+	Observe(id string)
+	// NewAccountID возвращает положительный ID аккаунта, зарегистрированного в момент now.
+	NewAccountID(now time.Time) int64
+	// ObserveAccountID - Observe для ID аккаунта.
+	ObserveAccountID(id int64)
+}
+
+// SetIDGenerator задаёт генератор ID. nil возвращает UUIDv4 по умолчанию,
+// а ID аккаунтов по порядку: следующий больше наибольшего зарегистрированного или импортированного.
+// Генератор сначала узнаёт обо всех ID, уже занятых в сервисе, поэтому его можно подключить и после Import.
+func (s *Service) SetIDGenerator(generator IDGenerator) {
+	call := s.beginAudit("SetIDGenerator", 0, "", "generator", fmt.Sprintf("%T", generator))
+	defer call.endUnchecked()
+
+	if generator != nil {
+		for _, account := range s.accounts {
+			generator.ObserveAccountID(account.ID)
+		}
+		for _, payment := range s.payments {
+			generator.Observe(payment.ID)
+		}
+		for _, favorite := range s.favorites {
+			generator.Observe(favorite.ID)
+		}
+		for _, hold := range s.holds {
+			generator.Observe(hold.ID)
+		}
+		for _, recurring := range s.schedules {
+			generator.Observe(recurring.ID)
+		}
+	}
+
+	s.idGenerator = generator
+}
+
+func (s *Service) newID() string {
+	if s.idGenerator == nil {
+		return uuid.New().String()
+	}
+
+	return s.idGenerator.NewID(s.now())
+}
+
+func (s *Service) observeID(id string) {
+	if s.idGenerator != nil {
+		s.idGenerator.Observe(id)
+	}
+}
+
+// newAccountID выдаёт ID аккаунта. ID, уже занятый другим аккаунтом (например, случайный повтор),
+// запрашивается заново; после первого повтора генератор узнаёт наибольший занятый ID,
+// чтобы упорядоченный генератор, отставший от данных сервиса, сразу перешёл за него.
+func (s *Service) newAccountID() (int64, error) {
+	if s.idGenerator == nil {
+		return s.nextAccountID + 1, nil
+	}
+
+	for i := 0; i < maxAccountIDAttempts; i++ {
+		id := s.idGenerator.NewAccountID(s.now())
+		_, err := s.FindAccountByID(id)
+		if id > 0 && err != nil {
+			return id, nil
+		}
+
+		if i == 0 {
+			s.idGenerator.ObserveAccountID(s.nextAccountID)
+		}
+	}
+
+	return 0, ErrAccountIDTaken
+}
+
+// observeAccountID учитывает ID зарегистрированного или импортированного аккаунта.
+func (s *Service) observeAccountID(id int64) {
+	if id > s.nextAccountID {
+		nextAccountID := s.nextAccountID
+		s.onRollback(func() { s.nextAccountID = nextAccountID })
+		s.nextAccountID = id
+	}
+
+	if s.idGenerator != nil {
+		s.idGenerator.ObserveAccountID(id)
+	}
+}
+
+// randomAccountID - случайный положительный ID аккаунта из random.
+func randomAccountID(random func([]byte) (int, error)) int64 {
+	buf := make([]byte, 8)
+	_, _ = random(buf)
+
+	return int64(binary.BigEndian.Uint64(buf)>>1) | 1
+}
+
+// UUIDv4IDs выдаёт случайные UUIDv4 и случайные ID аккаунтов. Порядка по времени у ID нет.
+type UUIDv4IDs struct{}
+
+func (UUIDv4IDs) NewID(now time.Time) string {
+	return uuid.New().String()
+}
+
+func (UUIDv4IDs) Observe(id string) {}
+
+func (UUIDv4IDs) NewAccountID(now time.Time) int64 {
+	return randomAccountID(rand.Read)
+}
+
+func (UUIDv4IDs) ObserveAccountID(id int64) {}
+
+// SequentialIDs выдаёт ID-счётчик, дополненный нулями до 20 цифр, чтобы строки сортировались
+// в порядке выдачи. Observe продвигает счётчик за импортированные числовые ID.
+// ID аккаунтов считаются отдельным счётчиком.
+type SequentialIDs struct {
+	mu          sync.Mutex
+	last        uint64
+	lastAccount int64
+}
+
+func (g *SequentialIDs) NewID(now time.Time) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.last++
+	return fmt.Sprintf("%020d", g.last)
+}
+
+func (g *SequentialIDs) Observe(id string) {
+	value, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if value > g.last {
+		g.last = value
+	}
+}
+
+func (g *SequentialIDs) NewAccountID(now time.Time) int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.lastAccount++
+	return g.lastAccount
+}
+
+func (g *SequentialIDs) ObserveAccountID(id int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if id > g.lastAccount {
+		g.lastAccount = id
+	}
+}
+
+// UUIDv7IDs выдаёт упорядоченные по времени UUIDv7 (RFC 9562): 48 бит миллисекунд Unix,
+// затем 12-битный счётчик внутри миллисекунды и случайные биты. ID растут монотонно,
+// даже если часы сервиса отстают от уже выданных или импортированных ID.
+// ID аккаунта - те же миллисекунды и счётчик без случайных битов: ms<<12 | counter.
+type UUIDv7IDs struct {
+	mu      sync.Mutex
+	lastMs  int64
+	counter uint16
+}
+
+// next возвращает миллисекунду и счётчик следующего ID.
+func (g *UUIDv7IDs) next(now time.Time) (int64, uint16) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := now.UnixNano() / int64(time.Millisecond)
+	if ms > g.lastMs {
+		g.lastMs = ms
+		g.counter = 0
+	} else {
+		// та же или более ранняя миллисекунда - продолжаем счётчик последнего ID
+		g.counter++
+		if g.counter > 0x0fff {
+			// счётчик переполнен - занимаем следующую миллисекунду
+			g.lastMs++
+			g.counter = 0
+		}
+	}
+
+	return g.lastMs, g.counter
+}
+
+// observe продвигает генератор за миллисекунду ms и счётчик counter.
+func (g *UUIDv7IDs) observe(ms int64, counter uint16) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if ms > g.lastMs || ms == g.lastMs && counter > g.counter {
+		g.lastMs = ms
+		g.counter = counter
+	}
+}
+
+func (g *UUIDv7IDs) NewID(now time.Time) string {
+	ms, counter := g.next(now)
+
+	var id uuid.UUID
+	_, _ = rand.Read(id[8:])
+	binary.BigEndian.PutUint16(id[4:6], uint16(ms))
+	binary.BigEndian.PutUint32(id[0:4], uint32(ms>>16))
+	binary.BigEndian.PutUint16(id[6:8], 0x7000|counter)
+	id[8] = id[8]&0x3f | 0x80
+
+	return id.String()
+}
+
+func (g *UUIDv7IDs) Observe(id string) {
+	parsed, err := uuid.Parse(id)
+	if err != nil || parsed.Version() != 7 {
+		return
+	}
+
+	ms := int64(binary.BigEndian.Uint32(parsed[0:4]))<<16 | int64(binary.BigEndian.Uint16(parsed[4:6]))
+	counter := binary.BigEndian.Uint16(parsed[6:8]) & 0x0fff
+
+	g.observe(ms, counter)
+}
+
+func (g *UUIDv7IDs) NewAccountID(now time.Time) int64 {
+	ms, counter := g.next(now)
+
+	return ms<<12 | int64(counter)
+}
+
+func (g *UUIDv7IDs) ObserveAccountID(id int64) {
+	g.observe(id>>12, uint16(id&0x0fff))
+}
+
+// DeterministicIDs выдаёт UUIDv4 из псевдослучайной последовательности с фиксированным seed:
+// одинаковые сценарии дают одинаковые ID (для тестов). Для реальных данных не подходит.
+type DeterministicIDs struct {
+	mu     sync.Mutex
+	random *mathrand.Rand
+}
+
+// NewDeterministicIDs создаёт генератор с начальным значением seed.
+func NewDeterministicIDs(seed int64) *DeterministicIDs {
+	return &DeterministicIDs{random: mathrand.New(mathrand.NewSource(seed))}
+}
+
+func (g *DeterministicIDs) NewID(now time.Time) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var id uuid.UUID
+	_, _ = g.random.Read(id[:])
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+
+	return id.String()
+}
+
+func (g *DeterministicIDs) Observe(id string) {}
+
+func (g *DeterministicIDs) NewAccountID(now time.Time) int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return randomAccountID(g.random.Read)
+}
+
+func (g *DeterministicIDs) ObserveAccountID(id int64) {}
+
+// проверка реализаций интерфейса при компиляции
+var (
+	_ IDGenerator = UUIDv4IDs{}
+	_ IDGenerator = (*SequentialIDs)(nil)
+	_ IDGenerator = (*UUIDv7IDs)(nil)
+	_ IDGenerator = (*DeterministicIDs)(nil)
+)
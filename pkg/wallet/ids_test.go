@@ -0,0 +1,254 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+func payIDs(t *testing.T, svc *Service, count int) []string {
+	t.Helper()
+
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []string{}
+	for i := 0; i < count; i++ {
+		payment, err := svc.Pay(account.ID, 1, "auto")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, payment.ID)
+	}
+
+	return ids
+}
+
+func TestService_newID_defaultUUIDv4(t *testing.T) {
+	svc := &Service{}
+
+	for _, id := range payIDs(t, svc, 2) {
+		parsed, err := uuid.Parse(id)
+		if err != nil || parsed.Version() != 4 {
+			t.Errorf("invalid id %q: %v", id, err)
+		}
+	}
+}
+
+func TestSequentialIDs_import(t *testing.T) {
+	svc := &Service{}
+	svc.SetIDGenerator(&SequentialIDs{})
+
+	ids := payIDs(t, svc, 2)
+	if ids[0] != "00000000000000000001" || ids[1] != "00000000000000000002" {
+		t.Fatalf("invalid ids: %v", ids)
+	}
+
+	dir := t.TempDir()
+	err := svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported := &Service{}
+	imported.SetIDGenerator(&SequentialIDs{})
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := imported.Pay(1, 1, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.ID != "00000000000000000003" {
+		t.Errorf("invalid id after import, got %v, want 00000000000000000003", payment.ID)
+	}
+}
+
+func TestUUIDv7IDs_ordered(t *testing.T) {
+	now := time.Date(2021, 3, 15, 10, 0, 0, 0, time.UTC)
+	generator := &UUIDv7IDs{}
+
+	ids := []string{}
+	for i := 0; i < 5; i++ {
+		ids = append(ids, generator.NewID(now))
+	}
+	// часы ушли назад - порядок всё равно сохраняется
+	ids = append(ids, generator.NewID(now.Add(-time.Second)))
+	ids = append(ids, generator.NewID(now.Add(time.Second)))
+
+	if !sort.StringsAreSorted(ids) {
+		t.Errorf("ids are not ordered: %v", ids)
+	}
+
+	for _, id := range ids {
+		parsed, err := uuid.Parse(id)
+		if err != nil || parsed.Version() != 7 || parsed.Variant() != uuid.RFC4122 {
+			t.Errorf("invalid id %q: %v", id, err)
+		}
+	}
+
+	first, _ := uuid.Parse(ids[0])
+	ms := int64(first[0])<<40 | int64(first[1])<<32 | int64(first[2])<<24 | int64(first[3])<<16 | int64(first[4])<<8 | int64(first[5])
+	if ms != now.UnixNano()/int64(time.Millisecond) {
+		t.Errorf("invalid timestamp in %v, got %v ms", ids[0], ms)
+	}
+
+	other := &UUIDv7IDs{}
+	other.Observe(ids[len(ids)-1])
+	next := other.NewID(now)
+	if next <= ids[len(ids)-1] {
+		t.Errorf("id after observe %v is not greater than %v", next, ids[len(ids)-1])
+	}
+}
+
+func TestDeterministicIDs(t *testing.T) {
+	first := &Service{}
+	first.SetIDGenerator(NewDeterministicIDs(42))
+	second := &Service{}
+	second.SetIDGenerator(NewDeterministicIDs(42))
+
+	firstIDs := payIDs(t, first, 3)
+	secondIDs := payIDs(t, second, 3)
+	for i := range firstIDs {
+		if firstIDs[i] != secondIDs[i] {
+			t.Errorf("ids differ: %v and %v", firstIDs, secondIDs)
+		}
+	}
+
+	if firstIDs[0] == firstIDs[1] {
+		t.Errorf("ids repeat: %v", firstIDs)
+	}
+}
+
+// constantIDs всегда выдаёт один и тот же ID аккаунта.
+type constantIDs struct {
+	UUIDv4IDs
+}
+
+func (constantIDs) NewAccountID(now time.Time) int64 {
+	return 1
+}
+
+func TestService_RegisterAccount_generatedIDs(t *testing.T) {
+	svc := &Service{}
+	svc.SetIDGenerator(&SequentialIDs{})
+
+	first, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID != 1 {
+		t.Errorf("invalid sequential account id: %v", first.ID)
+	}
+
+	dir := t.TempDir()
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported := &Service{}
+	imported.SetIDGenerator(&UUIDv7IDs{})
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := imported.RegisterAccount("+992000000002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, err := imported.RegisterAccount("+992000000003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.ID <= first.ID || third.ID <= second.ID {
+		t.Errorf("account ids are not ordered: %v, %v, %v", first.ID, second.ID, third.ID)
+	}
+
+	random := &Service{}
+	random.SetIDGenerator(NewDeterministicIDs(42))
+	account, err := random.RegisterAccount("+992000000001")
+	if err != nil || account.ID <= 0 {
+		t.Errorf("invalid random account id: %v, %v", account, err)
+	}
+
+	taken := &Service{}
+	taken.SetIDGenerator(constantIDs{})
+	_, err = taken.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = taken.RegisterAccount("+992000000002")
+	if !errors.Is(err, ErrAccountIDTaken) {
+		t.Errorf("invalid error for taken id, got %v, want %v", err, ErrAccountIDTaken)
+	}
+}
+
+func TestService_SetIDGenerator_afterImport(t *testing.T) {
+	svc := &Service{}
+	svc.SetIDGenerator(&SequentialIDs{})
+	payIDs(t, svc, 2)
+
+	dir := t.TempDir()
+	err := svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported := &Service{}
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported.SetIDGenerator(&SequentialIDs{})
+
+	payment, err := imported.Pay(1, 1, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.ID != "00000000000000000003" {
+		t.Errorf("invalid id after import, got %v, want 00000000000000000003", payment.ID)
+	}
+
+	account, err := imported.RegisterAccount("+992000000002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.ID != 2 {
+		t.Errorf("invalid account id after import, got %v, want 2", account.ID)
+	}
+}
+
+func TestService_RegisterAccount_manyTakenIDs(t *testing.T) {
+	svc := &Service{}
+	for i := 0; i < maxAccountIDAttempts+10; i++ {
+		_, err := svc.RegisterAccount(types.Phone("+99290" + fmt.Sprintf("%07d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	svc.SetIDGenerator(&SequentialIDs{})
+	account, err := svc.RegisterAccount("+992800000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.ID != maxAccountIDAttempts+11 {
+		t.Errorf("invalid account id, got %v, want %v", account.ID, maxAccountIDAttempts+11)
+	}
+}
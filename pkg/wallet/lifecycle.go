@@ -0,0 +1,129 @@
+package wallet
+
+import (
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+// PayoutCategory - категория платежа, которым выплачивается остаток при закрытии аккаунта.
+const PayoutCategory types.PaymentCategory = "payout"
+
+// checkAccountActive возвращает ошибку, если с аккаунтом нельзя проводить операции с деньгами.
+func checkAccountActive(account *types.Account) error {
+	switch account.Status {
+	case types.AccountStatusFrozen:
+		return ErrAccountFrozen
+	case types.AccountStatusClosed:
+		return ErrAccountClosed
+	}
+
+	return nil
+}
+
+// FreezeAccount блокирует операции с деньгами по аккаунту (например, при утере телефона).
+func (s *Service) FreezeAccount(accountID int64) (err error) {
+	call := s.beginAudit("FreezeAccount", accountID, "")
+	defer func() { err = call.end("", err) }()
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+
+	if account.Status == types.AccountStatusClosed {
+		return ErrAccountClosed
+	}
+
+	s.touchAccount(account)
+	account.Status = types.AccountStatusFrozen
+	s.publish(Event{Type: EventAccountFrozen, AccountID: account.ID})
+	return nil
+}
+
+// UnfreezeAccount снимает блокировку с аккаунта.
+func (s *Service) UnfreezeAccount(accountID int64) (err error) {
+	call := s.beginAudit("UnfreezeAccount", accountID, "")
+	defer func() { err = call.end("", err) }()
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+
+	if account.Status == types.AccountStatusClosed {
+		return ErrAccountClosed
+	}
+
+	s.touchAccount(account)
+	account.Status = types.AccountStatusActive
+	s.publish(Event{Type: EventAccountUnfrozen, AccountID: account.ID})
+	return nil
+}
+
+// CloseAccount закрывает аккаунт с нулевым балансом. Закрытый аккаунт нельзя открыть снова.
+func (s *Service) CloseAccount(accountID int64) (err error) {
+	call := s.beginAudit("CloseAccount", accountID, "")
+	defer func() { err = call.end("", err) }()
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+
+	if account.Status == types.AccountStatusClosed {
+		return ErrAccountClosed
+	}
+
+	if account.Balance != 0 {
+		return ErrBalanceNotZero
+	}
+
+	s.releaseAccountHolds(account)
+	s.touchAccount(account)
+	account.Status = types.AccountStatusClosed
+	s.publish(Event{Type: EventAccountClosed, AccountID: account.ID})
+	return nil
+}
+
+// PayoutAndClose выплачивает положительный остаток аккаунта платежом категории PayoutCategory
+// и закрывает аккаунт. Возвращает платёж выплаты или nil, если баланс был нулевым.
+// Активные резервирования аккаунта перед выплатой отменяются.
+// Аккаунт с отрицательным балансом и замороженный аккаунт закрыть нельзя.
+func (s *Service) PayoutAndClose(accountID int64) (payout *types.Payment, err error) {
+	call := s.beginAudit("PayoutAndClose", accountID, "")
+	defer func() { err = call.endPayment(payout, err) }()
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkAccountActive(account)
+	if err != nil {
+		return nil, err
+	}
+
+	if account.Balance < 0 {
+		return nil, ErrBalanceNotZero
+	}
+
+	s.releaseAccountHolds(account)
+	s.touchAccount(account)
+
+	if account.Balance > 0 {
+		payout = &types.Payment{
+			ID:        s.newID(),
+			AccountID: account.ID,
+			Amount:    account.Balance,
+			Category:  PayoutCategory,
+			Status:    types.PaymentStatusOk,
+			CreatedAt: s.now(),
+		}
+		s.addPayment(payout)
+		account.Balance = 0
+		s.publish(paymentEvent(EventPaymentCreated, payout))
+	}
+
+	account.Status = types.AccountStatusClosed
+	s.publish(Event{Type: EventAccountClosed, AccountID: account.ID})
+	return payout, nil
+}
@@ -0,0 +1,204 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+func TestService_FreezeAccount(t *testing.T) {
+	svc, account, _ := newTestService(t, 1000)
+	payment := mustPay(t, svc, account.ID, 100, "auto")
+
+	favorite, err := svc.FavoritePayment(payment.ID, "car")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.FreezeAccount(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Deposit(account.ID, 100)
+	if err != ErrAccountFrozen {
+		t.Errorf("Deposit: invalid error, got %v, want %v", err, ErrAccountFrozen)
+	}
+
+	_, err = svc.Pay(account.ID, 100, "auto")
+	if err != ErrAccountFrozen {
+		t.Errorf("Pay: invalid error, got %v, want %v", err, ErrAccountFrozen)
+	}
+
+	_, err = svc.Repeat(payment.ID)
+	if err != ErrAccountFrozen {
+		t.Errorf("Repeat: invalid error, got %v, want %v", err, ErrAccountFrozen)
+	}
+
+	_, err = svc.PayFromFavorite(favorite.ID)
+	if err != ErrAccountFrozen {
+		t.Errorf("PayFromFavorite: invalid error, got %v, want %v", err, ErrAccountFrozen)
+	}
+
+	err = svc.Reject(payment.ID)
+	if err != ErrAccountFrozen {
+		t.Errorf("Reject: invalid error, got %v, want %v", err, ErrAccountFrozen)
+	}
+
+	err = svc.UnfreezeAccount(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Errorf("unfrozen account must pay: %v", err)
+	}
+}
+
+func TestService_CloseAccount(t *testing.T) {
+	svc, account, _ := newTestService(t, 1000)
+	mustPay(t, svc, account.ID, 100, "auto")
+
+	err := svc.CloseAccount(account.ID)
+	if err != ErrBalanceNotZero {
+		t.Fatalf("invalid error, got %v, want %v", err, ErrBalanceNotZero)
+	}
+
+	_, err = svc.Pay(account.ID, account.Balance, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.CloseAccount(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, err := range []error{
+		svc.Deposit(account.ID, 1),
+		svc.FreezeAccount(account.ID),
+		svc.UnfreezeAccount(account.ID),
+		svc.CloseAccount(account.ID),
+	} {
+		if err != ErrAccountClosed {
+			t.Errorf("invalid error, got %v, want %v", err, ErrAccountClosed)
+		}
+	}
+}
+
+func TestService_PayoutAndClose(t *testing.T) {
+	svc, account, _ := newTestService(t, 1000)
+	mustPay(t, svc, account.ID, 100, "auto")
+
+	payout, err := svc.PayoutAndClose(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if payout.Amount != 900 || payout.Category != PayoutCategory || payout.Status != types.PaymentStatusOk {
+		t.Errorf("invalid payout: %v", payout)
+	}
+
+	if account.Balance != 0 || account.Status != types.AccountStatusClosed {
+		t.Errorf("invalid account: %v", account)
+	}
+
+	_, err = svc.PayoutAndClose(account.ID)
+	if err != ErrAccountClosed {
+		t.Errorf("invalid error, got %v, want %v", err, ErrAccountClosed)
+	}
+}
+
+func TestService_PayoutAndClose_overdraft(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.SetOverdraft(account.ID, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Pay(account.ID, 50, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.PayoutAndClose(account.ID)
+	if err != ErrBalanceNotZero {
+		t.Errorf("invalid error, got %v, want %v", err, ErrBalanceNotZero)
+	}
+}
+
+func TestService_PayoutAndClose_frozen(t *testing.T) {
+	svc, account, _ := newTestService(t, 1000)
+	mustPay(t, svc, account.ID, 100, "auto")
+
+	err := svc.FreezeAccount(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.PayoutAndClose(account.ID)
+	if err != ErrAccountFrozen {
+		t.Errorf("invalid error, got %v, want %v", err, ErrAccountFrozen)
+	}
+
+	if account.Balance != 900 || account.Status != types.AccountStatusFrozen {
+		t.Errorf("frozen account changed: %v", account)
+	}
+}
+
+func TestService_PayoutAndClose_releasesHolds(t *testing.T) {
+	svc, account, _ := newTestService(t, 1000)
+	mustPay(t, svc, account.ID, 100, "auto")
+
+	hold, err := svc.Authorize(account.ID, 40, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payout, err := svc.PayoutAndClose(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if payout.Amount != 900 || account.Balance != 0 || account.Held != 0 {
+		t.Errorf("invalid payout %v of account %v", payout, account)
+	}
+
+	if hold.Status != types.HoldStatusReleased {
+		t.Errorf("invalid hold status, got %v, want %v", hold.Status, types.HoldStatusReleased)
+	}
+}
+
+func TestService_ExportImport_accountStatus(t *testing.T) {
+	dir := t.TempDir()
+	svc, account, _ := newTestService(t, 1000)
+	mustPay(t, svc, account.ID, 100, "auto")
+
+	err := svc.FreezeAccount(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newSvc := &Service{}
+	err = newSvc.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = newSvc.Deposit(account.ID, 1)
+	if err != ErrAccountFrozen {
+		t.Errorf("invalid error, got %v, want %v", err, ErrAccountFrozen)
+	}
+}
@@ -0,0 +1,148 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// Правила лимитов, указываемые в LimitError.Rule.
+const (
+	LimitRuleMaxPayment      = "max payment"
+	LimitRuleDailyTotal      = "daily total"
+	LimitRuleMonthlyTotal    = "monthly total"
+	LimitRulePaymentsPerHour = "payments per hour"
+)
+
+// Limit описывает ограничения на платежи. Нулевое значение поля означает, что ограничения нет.
+type Limit struct {
+	MaxPayment         types.Money
+	DailyTotal         types.Money
+	MonthlyTotal       types.Money
+	MaxPaymentsPerHour int
+}
+
+// LimitError сообщает, какое правило лимита сработало.
+// errors.Is(err, ErrLimitExceeded) для неё возвращает true.
+type LimitError struct {
+	Rule      string
+	AccountID int64
+	Category  types.PaymentCategory
+	Limit     int64
+}
+
+func (e *LimitError) Error() string {
+	if e.Category != "" {
+		return fmt.Sprintf("%v: %v for account %v in category %v (limit %v)", ErrLimitExceeded, e.Rule, e.AccountID, e.Category, e.Limit)
+	}
+
+	return fmt.Sprintf("%v: %v for account %v (limit %v)", ErrLimitExceeded, e.Rule, e.AccountID, e.Limit)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// SetAccountLimit задаёт лимиты на все платежи аккаунта.
+func (s *Service) SetAccountLimit(accountID int64, limit Limit) (err error) {
+	call := s.beginAudit("SetAccountLimit", accountID, "", "limit", limit)
+	defer func() { err = call.end("", err) }()
+
+	_, err = s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+
+	if s.accountLimits == nil {
+		s.accountLimits = map[int64]Limit{}
+	}
+	s.accountLimits[accountID] = limit
+
+	return nil
+}
+
+// SetCategoryLimit задаёт лимиты на платежи каждого аккаунта в категории category.
+func (s *Service) SetCategoryLimit(category types.PaymentCategory, limit Limit) {
+	call := s.beginAudit("SetCategoryLimit", 0, "", "category", category, "limit", limit)
+	defer call.endUnchecked()
+
+	if s.categoryLimits == nil {
+		s.categoryLimits = map[types.PaymentCategory]Limit{}
+	}
+	s.categoryLimits[category] = limit
+}
+
+// checkLimits проверяет, что новый платёж не нарушает лимиты аккаунта и категории.
+func (s *Service) checkLimits(accountID int64, amount types.Money, category types.PaymentCategory) error {
+	limit, ok := s.accountLimits[accountID]
+	if ok {
+		err := s.checkLimit(limit, accountID, "", amount)
+		if err != nil {
+			return err
+		}
+	}
+
+	limit, ok = s.categoryLimits[category]
+	if ok {
+		err := s.checkLimit(limit, accountID, category, amount)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkLimit сверяет limit с платежами аккаунта (только в category, если она не пустая).
+// Отклонённые платежи не учитываются.
+func (s *Service) checkLimit(limit Limit, accountID int64, category types.PaymentCategory, amount types.Money) error {
+	if limit.MaxPayment > 0 && amount > limit.MaxPayment {
+		return &LimitError{Rule: LimitRuleMaxPayment, AccountID: accountID, Category: category, Limit: int64(limit.MaxPayment)}
+	}
+
+	now := s.now()
+	year, month, day := now.Date()
+	hourAgo := now.Add(-time.Hour)
+
+	daily := amount
+	monthly := amount
+	hourly := 1
+	for _, payment := range s.payments {
+		if payment.AccountID != accountID || payment.Status == types.PaymentStatusFail {
+			continue
+		}
+		if category != "" && payment.Category != category {
+			continue
+		}
+
+		created := payment.CreatedAt.In(now.Location())
+		paymentYear, paymentMonth, paymentDay := created.Date()
+		if paymentYear == year && paymentMonth == month {
+			monthly += payment.Amount
+			if paymentDay == day {
+				daily += payment.Amount
+			}
+		}
+		if created.After(hourAgo) {
+			hourly++
+		}
+	}
+
+	if limit.DailyTotal > 0 && daily > limit.DailyTotal {
+		return &LimitError{Rule: LimitRuleDailyTotal, AccountID: accountID, Category: category, Limit: int64(limit.DailyTotal)}
+	}
+
+	if limit.MonthlyTotal > 0 && monthly > limit.MonthlyTotal {
+		return &LimitError{Rule: LimitRuleMonthlyTotal, AccountID: accountID, Category: category, Limit: int64(limit.MonthlyTotal)}
+	}
+
+	if limit.MaxPaymentsPerHour > 0 && hourly > limit.MaxPaymentsPerHour {
+		return &LimitError{Rule: LimitRulePaymentsPerHour, AccountID: accountID, Category: category, Limit: int64(limit.MaxPaymentsPerHour)}
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func assertLimitRule(t *testing.T, err error, rule string) {
+	t.Helper()
+
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("invalid error, got %v, want %v", err, ErrLimitExceeded)
+	}
+
+	limitErr := &LimitError{}
+	if !errors.As(err, &limitErr) || limitErr.Rule != rule {
+		t.Errorf("invalid rule, got %v, want %v", err, rule)
+	}
+}
+
+func TestService_Pay_maxPaymentLimit(t *testing.T) {
+	svc, account, _ := newTestService(t, 1_000_000)
+
+	err := svc.SetAccountLimit(account.ID, Limit{MaxPayment: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = svc.Pay(account.ID, 101, "auto")
+	assertLimitRule(t, err, LimitRuleMaxPayment)
+}
+
+func TestService_Pay_dailyAndMonthlyLimit(t *testing.T) {
+	svc, account, now := newTestService(t, 1_000_000)
+
+	err := svc.SetAccountLimit(account.ID, Limit{DailyTotal: 300, MonthlyTotal: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Pay(account.ID, 300, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Pay(account.ID, 1, "auto")
+	assertLimitRule(t, err, LimitRuleDailyTotal)
+
+	*now = now.Add(24 * time.Hour)
+	_, err = svc.Pay(account.ID, 200, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*now = now.Add(24 * time.Hour)
+	_, err = svc.Pay(account.ID, 1, "auto")
+	assertLimitRule(t, err, LimitRuleMonthlyTotal)
+
+	*now = time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)
+	_, err = svc.Pay(account.ID, 1, "auto")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestService_Pay_rejectedNotCounted(t *testing.T) {
+	svc, account, _ := newTestService(t, 1_000_000)
+
+	err := svc.SetAccountLimit(account.ID, Limit{DailyTotal: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestService_Repeat_paymentsPerHourLimit(t *testing.T) {
+	svc, account, now := newTestService(t, 1_000_000)
+
+	svc.SetCategoryLimit("food", Limit{MaxPaymentsPerHour: 2})
+
+	payment, err := svc.Pay(account.ID, 10, "food")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Repeat(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Repeat(payment.ID)
+	assertLimitRule(t, err, LimitRulePaymentsPerHour)
+
+	_, err = svc.Pay(account.ID, 10, "auto")
+	if err != nil {
+		t.Errorf("other category must not be limited: %v", err)
+	}
+
+	*now = now.Add(time.Hour)
+	_, err = svc.Repeat(payment.ID)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestService_PayFromFavorite_categoryLimit(t *testing.T) {
+	svc, account, _ := newTestService(t, 1_000_000)
+
+	payment, err := svc.Pay(account.ID, 500, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	favorite, err := svc.FavoritePayment(payment.ID, "car")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc.SetCategoryLimit("auto", Limit{MaxPayment: 400})
+
+	_, err = svc.PayFromFavorite(favorite.ID)
+	assertLimitRule(t, err, LimitRuleMaxPayment)
+}
+
+func TestService_SetAccountLimit_notFound(t *testing.T) {
+	svc := &Service{}
+
+	err := svc.SetAccountLimit(1, Limit{})
+	if err != ErrAccountNotFound {
+		t.Errorf("invalid error, got %v, want %v", err, ErrAccountNotFound)
+	}
+}
@@ -0,0 +1,811 @@
+package wallet
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+// Файлы LogStore в каталоге хранилища:
+//
+//	log-<N>            сегмент журнала операций N
+//	snapshot-<N>/      снимок состояния после сегментов 1..N в формате Export с полями,
+//	                   экранированными как в журнале (у платежей - с полем проверок антифрода)
+//	                   (accounts.dump, payments.dump, favorites.dump, holds.dump, schedules.dump,
+//	                   phones.dump, outbox.dump, idempotency.dump, audit.dump)
+//	snapshot-<N>.tmp/  недописанный снимок, удаляется при открытии
+const (
+	logSegmentPrefix = "log-"
+	snapshotPrefix   = "snapshot-"
+	snapshotTmp      = ".tmp"
+)
+
+// Строки записи журнала: "<вид>;<поля строки дампа или ID>", запись заканчивается строкой logCommit.
+// Каждое поле экранируется (logEscaper), поэтому имя или категория с ";" или переводом строки
+// не могут сдвинуть поля или добавить в журнал свою строку или запись.
+// Запись без logCommit в конце журнала - оборванная при сбое, она отбрасывается.
+const (
+	logAccount    = "account"
+	logPayment    = "payment"
+	logFavorite   = "favorite"
+	logUnfavorite = "unfavorite"
+	logHold       = "hold"
+	logSchedule   = "schedule"
+	logUnschedule = "unschedule"
+	logPhone      = "phone"
+	// события outbox по номеру, ключи идемпотентности, записи журнала аудита по номеру
+	logOutbox        = "outbox"
+	logUnoutbox      = "unoutbox"
+	logIdempotency   = "idempotency"
+	logUnidempotency = "unidempotency"
+	logAudit         = "audit"
+	logCommit        = "commit"
+)
+
+var (
+	logEscaper   = strings.NewReplacer("%", "%25", ";", "%3B", "\r", "%0D", "\n", "%0A")
+	logUnescaper = strings.NewReplacer("%25", "%", "%3B", ";", "%0D", "\r", "%0A", "\n")
+)
+
+// encodeLogFields экранирует поля и соединяет их в строку журнала.
+func encodeLogFields(fields []string) string {
+	escaped := make([]string, len(fields))
+	for i, field := range fields {
+		escaped[i] = logEscaper.Replace(field)
+	}
+
+	return strings.Join(escaped, ";")
+}
+
+// decodeLogFields разбирает строку журнала на поля.
+func decodeLogFields(line string) []string {
+	fields := strings.Split(line, ";")
+	for i, field := range fields {
+		fields[i] = logUnescaper.Replace(field)
+	}
+
+	return fields
+}
+
+// LogStore - хранилище в виде журнала операций: каждый Apply дописывает в журнал одну запись
+// и синхронизирует её на диск. Чтобы журнал не рос бесконечно, Compact записывает снимок
+// состояния в формате Export и удаляет вошедшие в него сегменты журнала.
+// Compact можно вызывать из другой горутины, пока сервис продолжает работать:
+// новые записи на время компакции идут в новый сегмент.
+// Open восстанавливает хранилище после сбоя на любом шаге записи или компакции.
+type LogStore struct {
+	dir string
+
+	// mu защищает текущий сегмент журнала
+	mu      sync.Mutex
+	file    *os.File
+	segment int64
+	size    int64
+	records int
+
+	// compactMu не даёт двум компакциям идти одновременно и защищает snapshot
+	compactMu sync.Mutex
+	snapshot  int64
+
+	// crash, если задан, вызывается на каждом шаге компакции; ошибка прерывает компакцию,
+	// оставляя файлы как при сбое процесса на этом шаге (используется в тестах)
+	crash func(step string) error
+}
+
+// OpenLogStore открывает (или создаёт) хранилище в каталоге dir. Недописанные снимки удаляются,
+// оборванная последняя запись журнала отбрасывается, а остатки прерванной компакции дочищаются.
+func OpenLogStore(dir string) (*LogStore, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := int64(0)
+	segments := []int64{}
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case strings.HasSuffix(name, snapshotTmp):
+			err = os.RemoveAll(filepath.Join(dir, name))
+			if err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(name, snapshotPrefix):
+			n, err := strconv.ParseInt(strings.TrimPrefix(name, snapshotPrefix), 10, 64)
+			if err == nil && n > snapshot {
+				snapshot = n
+			}
+		case strings.HasPrefix(name, logSegmentPrefix):
+			n, err := strconv.ParseInt(strings.TrimPrefix(name, logSegmentPrefix), 10, 64)
+			if err == nil {
+				segments = append(segments, n)
+			}
+		}
+	}
+
+	store := &LogStore{dir: dir, snapshot: snapshot, segment: snapshot + 1}
+	for _, n := range segments {
+		if n > store.segment {
+			store.segment = n
+		}
+	}
+
+	err = store.removeCompacted()
+	if err != nil {
+		return nil, err
+	}
+
+	// оборванная запись может быть только в конце последнего сегмента
+	records, complete, err := readLogSegment(store.segmentPath(store.segment), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(store.segmentPath(store.segment), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	err = file.Truncate(complete)
+	if err == nil {
+		err = syncDir(dir)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	store.file = file
+	store.size = complete
+	store.records = records
+	return store, nil
+}
+
+func (s *LogStore) segmentPath(n int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%v%020d", logSegmentPrefix, n))
+}
+
+func (s *LogStore) snapshotPath(n int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%v%020d", snapshotPrefix, n))
+}
+
+// removeCompacted удаляет сегменты журнала, вошедшие в снимок s.snapshot, и более старые снимки.
+func (s *LogStore) removeCompacted() error {
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		prefix := ""
+		switch {
+		case strings.HasSuffix(name, snapshotTmp):
+			continue
+		case strings.HasPrefix(name, logSegmentPrefix):
+			prefix = logSegmentPrefix
+		case strings.HasPrefix(name, snapshotPrefix):
+			prefix = snapshotPrefix
+		default:
+			continue
+		}
+
+		n, err := strconv.ParseInt(strings.TrimPrefix(name, prefix), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if (prefix == logSegmentPrefix && n <= s.snapshot) || (prefix == snapshotPrefix && n < s.snapshot) {
+			err = os.RemoveAll(filepath.Join(s.dir, name))
+			if err != nil {
+				return err
+			}
+
+			err = s.step("cleanup")
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Close закрывает текущий сегмент журнала.
+func (s *LogStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return os.ErrClosed
+	}
+
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// Records возвращает число записей в текущем сегменте журнала: по нему можно решать, пора ли вызвать Compact.
+func (s *LogStore) Records() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.records
+}
+
+// Load возвращает состояние из последнего снимка и записей журнала после него.
+func (s *LogStore) Load() (StoreChanges, error) {
+	s.compactMu.Lock()
+	defer s.compactMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := StoreChanges{}
+	state, err := s.readState(s.segment)
+	if err != nil {
+		return result, err
+	}
+
+	for _, line := range state.accounts.all() {
+		account, err := decodeAccountFields(decodeLogFields(line))
+		if err != nil {
+			return result, err
+		}
+		result.Accounts = append(result.Accounts, *account)
+	}
+	for _, line := range state.payments.all() {
+		payment, err := decodeLogPayment(decodeLogFields(line))
+		if err != nil {
+			return result, err
+		}
+		result.Payments = append(result.Payments, *payment)
+	}
+	for _, line := range state.favorites.all() {
+		favorite, err := decodeFavoriteFields(decodeLogFields(line))
+		if err != nil {
+			return result, err
+		}
+		result.Favorites = append(result.Favorites, *favorite)
+	}
+	for _, line := range state.holds.all() {
+		hold, err := decodeHoldFields(decodeLogFields(line))
+		if err != nil {
+			return result, err
+		}
+		result.Holds = append(result.Holds, *hold)
+	}
+	for _, line := range state.schedules.all() {
+		record, err := decodeScheduleFields(decodeLogFields(line))
+		if err != nil {
+			return result, err
+		}
+		result.Schedules = append(result.Schedules, record)
+	}
+	for _, line := range state.phones.all() {
+		change, err := decodePhoneChangeFields(decodeLogFields(line))
+		if err != nil {
+			return result, err
+		}
+		result.PhoneChanges = append(result.PhoneChanges, change)
+	}
+	for _, line := range state.outbox.all() {
+		entry, err := decodeOutboxFields(decodeLogFields(line))
+		if err != nil {
+			return result, err
+		}
+		result.Outbox = append(result.Outbox, *entry)
+	}
+	for _, line := range state.idempotency.all() {
+		record, err := decodeIdempotencyFields(decodeLogFields(line))
+		if err != nil {
+			return result, err
+		}
+		result.Idempotency = append(result.Idempotency, storedIdempotency(record))
+	}
+	for _, line := range state.audit.all() {
+		record, err := decodeAuditFields(decodeLogFields(line))
+		if err != nil {
+			return result, err
+		}
+		result.Audit = append(result.Audit, *record)
+	}
+
+	return result, nil
+}
+
+// Apply дописывает изменения в журнал одной записью и синхронизирует её на диск.
+// Если запись не удалась, журнал обрезается до прежнего размера.
+func (s *LogStore) Apply(changes StoreChanges) error {
+	record := encodeLogRecord(changes)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return os.ErrClosed
+	}
+
+	_, err := s.file.WriteString(record)
+	if err == nil {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		// оборванная запись в середине журнала склеилась бы со следующей
+		truncateErr := s.file.Truncate(s.size)
+		if truncateErr != nil {
+			s.file.Close()
+			s.file = nil
+		}
+		return err
+	}
+
+	s.size += int64(len(record))
+	s.records++
+	return nil
+}
+
+func encodeLogRecord(changes StoreChanges) string {
+	builder := strings.Builder{}
+	for i := range changes.Accounts {
+		builder.WriteString(logAccount + ";" + encodeLogFields(accountFields(&changes.Accounts[i])) + "\n")
+	}
+	for i := range changes.Payments {
+		builder.WriteString(logPayment + ";" + encodeLogFields(logPaymentFields(&changes.Payments[i])) + "\n")
+	}
+	for i := range changes.Favorites {
+		builder.WriteString(logFavorite + ";" + encodeLogFields(favoriteFields(&changes.Favorites[i])) + "\n")
+	}
+	for _, id := range changes.DeletedFavorites {
+		builder.WriteString(logUnfavorite + ";" + encodeLogFields([]string{id}) + "\n")
+	}
+	for i := range changes.Holds {
+		builder.WriteString(logHold + ";" + encodeLogFields(holdFields(&changes.Holds[i])) + "\n")
+	}
+	for _, record := range changes.Schedules {
+		builder.WriteString(logSchedule + ";" + encodeLogFields(scheduleFields(record)) + "\n")
+	}
+	for _, id := range changes.DeletedSchedules {
+		builder.WriteString(logUnschedule + ";" + encodeLogFields([]string{id}) + "\n")
+	}
+	for _, change := range changes.PhoneChanges {
+		builder.WriteString(logPhone + ";" + encodeLogFields(phoneChangeFields(change)) + "\n")
+	}
+	for i := range changes.Outbox {
+		builder.WriteString(logOutbox + ";" + encodeLogFields(outboxFields(&changes.Outbox[i])) + "\n")
+	}
+	for _, seq := range changes.DeletedOutbox {
+		builder.WriteString(logUnoutbox + ";" + encodeLogFields([]string{strconv.FormatUint(seq, 10)}) + "\n")
+	}
+	for _, record := range changes.Idempotency {
+		builder.WriteString(logIdempotency + ";" + encodeLogFields(idempotencyFields(loadedIdempotency(record))) + "\n")
+	}
+	for _, key := range changes.DeletedIdempotency {
+		builder.WriteString(logUnidempotency + ";" + encodeLogFields([]string{key}) + "\n")
+	}
+	for i := range changes.Audit {
+		builder.WriteString(logAudit + ";" + encodeLogFields(auditFields(&changes.Audit[i])) + "\n")
+	}
+	builder.WriteString(logCommit + "\n")
+
+	return builder.String()
+}
+
+// logPaymentFields - поля платежа из дампа, затем проверки антифрода
+// (в Export они идут отдельным разделом risk.dump) и аккаунт комиссии.
+func logPaymentFields(payment *types.Payment) []string {
+	return append(paymentFields(payment), encodeRiskChecks(payment.Risk), strconv.FormatInt(payment.FeeAccountID, 10))
+}
+
+func decodeLogPayment(data []string) (*types.Payment, error) {
+	payment, err := decodePaymentFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// в записях прежних версий проверок и аккаунта комиссии нет
+	if len(data) > 7 {
+		payment.Risk, err = decodeRiskChecks(data[7])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(data) > 8 {
+		payment.FeeAccountID, err = strconv.ParseInt(data[8], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return payment, nil
+}
+
+// Compact записывает снимок состояния и удаляет вошедшие в него сегменты журнала.
+// Шаги: переключение на новый сегмент; запись снимка во временный каталог и его синхронизация;
+// переименование каталога в snapshot-<N> (с этого момента снимок действителен);
+// удаление сегментов до N и прежнего снимка. Сбой на любом шаге оставляет либо прежний снимок
+// с полным журналом, либо новый снимок; остатки дочищает OpenLogStore.
+// Apply блокируется только на время переключения сегмента.
+func (s *LogStore) Compact() error {
+	s.compactMu.Lock()
+	defer s.compactMu.Unlock()
+
+	s.mu.Lock()
+	if s.file == nil {
+		s.mu.Unlock()
+		return os.ErrClosed
+	}
+	last := s.segment
+	err := s.rotate()
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	err = s.step("rotate")
+	if err != nil {
+		return err
+	}
+
+	// закрытые сегменты больше не меняются, поэтому снимок собирается без блокировки журнала
+	state, err := s.readState(last)
+	if err != nil {
+		return err
+	}
+
+	tmp := s.snapshotPath(last) + snapshotTmp
+	err = os.RemoveAll(tmp)
+	if err == nil {
+		err = os.Mkdir(tmp, 0755)
+	}
+	if err != nil {
+		return err
+	}
+
+	sections := []dumpSection{
+		{name: "accounts.dump", lines: state.accounts.all()},
+		{name: "payments.dump", lines: state.payments.all()},
+		{name: "favorites.dump", lines: state.favorites.all()},
+		{name: "holds.dump", lines: state.holds.all()},
+		{name: "schedules.dump", lines: state.schedules.all()},
+		{name: "phones.dump", lines: state.phones.all()},
+		{name: "outbox.dump", lines: state.outbox.all()},
+		{name: "idempotency.dump", lines: state.idempotency.all()},
+		{name: "audit.dump", lines: state.audit.all()},
+	}
+	for _, section := range sections {
+		err = writeSynced(filepath.Join(tmp, section.name), strings.Join(section.lines, "\n"))
+		if err != nil {
+			return err
+		}
+
+		err = s.step("write")
+		if err != nil {
+			return err
+		}
+	}
+
+	err = syncDir(tmp)
+	if err != nil {
+		return err
+	}
+
+	err = s.step("rename")
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp, s.snapshotPath(last))
+	if err == nil {
+		err = syncDir(s.dir)
+	}
+	if err != nil {
+		return err
+	}
+	s.snapshot = last
+
+	err = s.step("truncate")
+	if err != nil {
+		return err
+	}
+
+	err = s.removeCompacted()
+	if err != nil {
+		return err
+	}
+
+	return syncDir(s.dir)
+}
+
+// rotate закрывает текущий сегмент журнала и начинает следующий. Вызывается под s.mu.
+func (s *LogStore) rotate() error {
+	file, err := os.OpenFile(s.segmentPath(s.segment+1), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	err = syncDir(s.dir)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	err = s.file.Close()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	s.file = file
+	s.segment++
+	s.size = 0
+	s.records = 0
+	return nil
+}
+
+func (s *LogStore) step(name string) error {
+	if s.crash == nil {
+		return nil
+	}
+
+	return s.crash(name)
+}
+
+// logState - строки аккаунтов, платежей, избранного, резервирований, повторяющихся платежей, смен номеров, outbox,
+// ключей идемпотентности и журнала аудита в формате журнала (с экранированными полями),
+// собранные из снимка и журнала.
+type logState struct {
+	accounts    dumpLines
+	payments    dumpLines
+	favorites   dumpLines
+	holds       dumpLines
+	schedules   dumpLines
+	phones      dumpLines
+	outbox      dumpLines
+	idempotency dumpLines
+	audit       dumpLines
+}
+
+// readState собирает состояние из снимка s.snapshot и сегментов журнала после него до last включительно.
+func (s *LogStore) readState(last int64) (*logState, error) {
+	state := &logState{}
+
+	if s.snapshot != 0 {
+		dir := s.snapshotPath(s.snapshot)
+		for _, section := range []struct {
+			name string
+			set  func(line string)
+		}{
+			{"accounts.dump", state.accounts.set},
+			{"payments.dump", state.payments.set},
+			{"favorites.dump", state.favorites.set},
+			{"holds.dump", state.holds.set},
+			{"schedules.dump", state.schedules.set},
+			{"phones.dump", state.phones.add},
+			{"outbox.dump", state.outbox.set},
+			{"idempotency.dump", state.idempotency.set},
+			{"audit.dump", state.audit.set},
+		} {
+			lines, err := readDump(filepath.Join(dir, section.name))
+			if os.IsNotExist(err) {
+				// в снимках прежних версий нет разделов резервирований, повторяющихся платежей,
+				// смен номеров, outbox, идемпотентности и аудита
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			for _, line := range lines {
+				section.set(line)
+			}
+		}
+	}
+
+	for n := s.snapshot + 1; n <= last; n++ {
+		_, _, err := readLogSegment(s.segmentPath(n), state)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return state, nil
+}
+
+// readLogSegment применяет к state (если он задан) полные записи сегмента path и возвращает их число
+// и размер части файла, которую они занимают. Отсутствующий сегмент считается пустым.
+func readLogSegment(path string, state *logState) (int, int64, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	records := 0
+	complete := int64(0)
+	offset := int64(0)
+	pending := []string{}
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// строка без перевода строки - оборванная запись
+			break
+		}
+		offset += int64(len(line))
+		line = strings.TrimSuffix(line, "\n")
+
+		if line != logCommit {
+			pending = append(pending, line)
+			continue
+		}
+
+		if state != nil {
+			err = state.apply(pending)
+			if err != nil {
+				return 0, 0, fmt.Errorf("%v: %w", path, err)
+			}
+		}
+		pending = pending[:0]
+		records++
+		complete = offset
+	}
+
+	return records, complete, nil
+}
+
+// apply применяет строки одной записи журнала.
+func (s *logState) apply(lines []string) error {
+	for _, line := range lines {
+		index := strings.IndexByte(line, ';')
+		if index < 0 {
+			return ErrInvalidDump
+		}
+
+		kind, data := line[:index], line[index+1:]
+		switch kind {
+		case logAccount:
+			s.accounts.set(data)
+		case logPayment:
+			s.payments.set(data)
+		case logFavorite:
+			s.favorites.set(data)
+		case logUnfavorite:
+			s.favorites.remove(data)
+		case logHold:
+			s.holds.set(data)
+		case logSchedule:
+			s.schedules.set(data)
+		case logUnschedule:
+			s.schedules.remove(data)
+		case logPhone:
+			s.phones.add(data)
+		case logOutbox:
+			s.outbox.set(data)
+		case logUnoutbox:
+			s.outbox.remove(data)
+		case logIdempotency:
+			s.idempotency.set(data)
+		case logUnidempotency:
+			s.idempotency.remove(data)
+		case logAudit:
+			s.audit.set(data)
+		default:
+			return ErrInvalidDump
+		}
+	}
+
+	return nil
+}
+
+// dumpLines - строки раздела дампа по ID (первому полю строки) в порядке добавления.
+type dumpLines struct {
+	index map[string]int
+	lines []string
+}
+
+func (d *dumpLines) set(line string) {
+	id := line
+	if index := strings.IndexByte(line, ';'); index >= 0 {
+		id = line[:index]
+	}
+
+	if d.index == nil {
+		d.index = map[string]int{}
+	}
+
+	if i, ok := d.index[id]; ok {
+		d.lines[i] = line
+		return
+	}
+
+	d.index[id] = len(d.lines)
+	d.lines = append(d.lines, line)
+}
+
+// add добавляет строку раздела без ID (историю смен номеров): ключом служит вся строка.
+func (d *dumpLines) add(line string) {
+	if d.index == nil {
+		d.index = map[string]int{}
+	}
+
+	if _, ok := d.index[line]; ok {
+		return
+	}
+
+	d.index[line] = len(d.lines)
+	d.lines = append(d.lines, line)
+}
+
+func (d *dumpLines) remove(id string) {
+	i, ok := d.index[id]
+	if !ok {
+		return
+	}
+
+	d.lines[i] = ""
+	delete(d.index, id)
+}
+
+func (d *dumpLines) all() []string {
+	result := []string{}
+	for _, line := range d.lines {
+		if line != "" {
+			result = append(result, line)
+		}
+	}
+
+	return result
+}
+
+// writeSynced записывает файл и синхронизирует его на диск.
+func writeSynced(path string, data string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.WriteString(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// syncDir синхронизирует каталог, чтобы созданные, переименованные и удалённые в нём файлы пережили сбой.
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	err = file.Sync()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+var _ Store = (*LogStore)(nil)
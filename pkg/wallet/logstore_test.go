@@ -0,0 +1,344 @@
+package wallet
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+var errCrash = errors.New("crash")
+
+func openLogService(t *testing.T, dir string) (*Service, *LogStore) {
+	t.Helper()
+
+	store, err := OpenLogStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	svc := &Service{}
+	err = svc.SetStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return svc, store
+}
+
+// serveLog выполняет платежи, отказ и операции с избранным, меняющие все виды записей журнала.
+func serveLog(t *testing.T, svc *Service, phone string) {
+	t.Helper()
+
+	account, err := svc.RegisterAccount(types.Phone(phone))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Deposit(account.ID, 10_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := svc.FavoritePayment(payment.ID, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.PayFromFavorite(kept.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rejected, err := svc.Pay(account.ID, 200, "food")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := svc.FavoritePayment(rejected.ID, "food")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Reject(rejected.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.DeleteFavorite(deleted.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// storedState возвращает закодированные аккаунты, платежи и избранное сервиса - то, что попадает в хранилище.
+func storedState(svc *Service) map[string]string {
+	state := map[string]string{}
+	for _, account := range svc.accounts {
+		state["account;"+strconv.FormatInt(account.ID, 10)] = encodeAccount(account)
+	}
+	for _, payment := range svc.payments {
+		state["payment;"+payment.ID] = encodePayment(payment)
+	}
+	for _, favorite := range svc.favorites {
+		state["favorite;"+favorite.ID] = encodeFavorite(favorite)
+	}
+
+	return state
+}
+
+// assertRecovered открывает хранилище в dir заново и сравнивает загруженное состояние с want.
+func assertRecovered(t *testing.T, dir string, want *Service) {
+	t.Helper()
+
+	store, err := OpenLogStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	svc := &Service{}
+	err = svc.SetStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, expected := storedState(svc), storedState(want)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("invalid recovered state\ngot:  %v\nwant: %v", got, expected)
+	}
+}
+
+// logFiles возвращает имена файлов каталога хранилища.
+func logFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	return names
+}
+
+func TestLogStore_Compact(t *testing.T) {
+	dir := t.TempDir()
+	svc, store := openLogService(t, dir)
+
+	serveLog(t, svc, "+992000000001")
+	if store.Records() == 0 {
+		t.Fatal("log is empty")
+	}
+
+	err := store.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.Records() != 0 {
+		t.Errorf("invalid records after compaction, got %v, want 0", store.Records())
+	}
+
+	serveLog(t, svc, "+992000000002")
+	err = store.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// остаются только последний снимок и новый пустой сегмент
+	want := []string{"log-00000000000000000003", "snapshot-00000000000000000002"}
+	if got := logFiles(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid files after compaction, got %v, want %v", got, want)
+	}
+
+	assertRecovered(t, dir, svc)
+
+	// снимок - обычный экспорт, его можно импортировать в сервис
+	imported := &Service{}
+	err = imported.Import(filepath.Join(dir, "snapshot-00000000000000000002"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := storedState(imported), storedState(svc); !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid imported snapshot\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestLogStore_Compact_crash(t *testing.T) {
+	for _, step := range []string{"rotate", "write", "rename", "truncate", "cleanup"} {
+		t.Run(step, func(t *testing.T) {
+			dir := t.TempDir()
+			svc, store := openLogService(t, dir)
+
+			serveLog(t, svc, "+992000000001")
+			err := store.Compact()
+			if err != nil {
+				t.Fatal(err)
+			}
+			serveLog(t, svc, "+992000000002")
+
+			store.crash = func(current string) error {
+				if current != step {
+					return nil
+				}
+
+				// сервис продолжает работать во время компакции, до самого сбоя
+				serveLog(t, svc, "+992000000003")
+				return errCrash
+			}
+			err = store.Compact()
+			if !errors.Is(err, errCrash) {
+				t.Fatalf("invalid error, got %v, want %v", err, errCrash)
+			}
+			store.Close()
+
+			assertRecovered(t, dir, svc)
+			for _, name := range logFiles(t, dir) {
+				if strings.HasSuffix(name, snapshotTmp) {
+					t.Errorf("unfinished snapshot %v left after recovery", name)
+				}
+			}
+
+			// после восстановления хранилище снова пишет и сжимается
+			svc, store = openLogService(t, dir)
+			serveLog(t, svc, "+992000000004")
+			err = store.Compact()
+			if err != nil {
+				t.Fatal(err)
+			}
+			store.Close()
+
+			assertRecovered(t, dir, svc)
+			if got := logFiles(t, dir); len(got) != 2 {
+				t.Errorf("invalid files after compaction: %v", got)
+			}
+		})
+	}
+}
+
+func TestLogStore_tornRecord(t *testing.T) {
+	dir := t.TempDir()
+	svc, store := openLogService(t, dir)
+
+	serveLog(t, svc, "+992000000001")
+	store.Close()
+
+	// сбой посреди дописывания записи: строки без завершающего commit
+	file, err := os.OpenFile(store.segmentPath(store.segment), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.WriteString("account;1;+992000000001;0;0;;ACTIVE;0;0\npayment;broken")
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertRecovered(t, dir, svc)
+
+	// оборванная запись отрезана и не склеивается со следующей
+	svc, store = openLogService(t, dir)
+	serveLog(t, svc, "+992000000002")
+	store.Close()
+
+	assertRecovered(t, dir, svc)
+}
+
+func TestLogStore_injectedRecord(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenLogStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { store.Close() }()
+
+	// имя и категория пытаются закончить запись и дописать поддельный аккаунт
+	name := "car\ncommit\naccount;1;+992000000001;1000000;0;;ACTIVE;0;0\ncommit\n"
+	favorite := types.Favorite{ID: "f1", AccountID: 1, Name: name, Amount: 100, Category: "auto;50%"}
+	account := types.Account{ID: 1, Phone: "+992000000001", Balance: 100, Status: types.AccountStatusActive}
+	err = store.Apply(StoreChanges{Accounts: []types.Account{account}, Favorites: []types.Favorite{favorite}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func() {
+		t.Helper()
+
+		loaded, err := store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(loaded.Accounts) != 1 || loaded.Accounts[0] != account {
+			t.Errorf("invalid accounts: %+v", loaded.Accounts)
+		}
+		if len(loaded.Favorites) != 1 || loaded.Favorites[0] != favorite {
+			t.Errorf("invalid favorites: %+v", loaded.Favorites)
+		}
+	}
+
+	check()
+	store.Close()
+	store, err = OpenLogStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.Records() != 1 {
+		t.Errorf("invalid records, got %v, want 1", store.Records())
+	}
+	check()
+
+	err = store.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	check()
+}
+
+func TestLogStore_Compact_whileServing(t *testing.T) {
+	dir := t.TempDir()
+	svc, store := openLogService(t, dir)
+
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Deposit(account.ID, 1_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			_, err := svc.Pay(account.ID, 1, "auto")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 10; i++ {
+		err = store.Compact()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	store.Close()
+
+	assertRecovered(t, dir, svc)
+}
@@ -0,0 +1,251 @@
+package wallet
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+// OutboxSink доставляет событие внешнему получателю (очередь, вебхук, ...).
+// Ошибка означает, что событие не доставлено и будет отправлено повторно.
+type OutboxSink func(event Event) error
+
+// OutboxEntry - событие в исходящей очереди сервиса.
+type OutboxEntry struct {
+	Event     Event
+	Delivered bool
+	// Attempts - сколько раз событие передавалось получателю
+	Attempts int
+}
+
+// Outbox возвращает ещё не доставленные события в порядке публикации.
+func (s *Service) Outbox() []OutboxEntry {
+	entries := []OutboxEntry{}
+	for _, entry := range s.outbox {
+		if !entry.Delivered {
+			entries = append(entries, *entry)
+		}
+	}
+
+	return entries
+}
+
+// DispatchOutbox передаёт sink недоставленные события в порядке публикации и отмечает их доставленными.
+// На первой ошибке sink отправка останавливается, чтобы не нарушить порядок; событие остаётся в очереди.
+// Событие считается доставленным только после успешного вызова sink, поэтому при сбое
+// между вызовом sink и экспортом оно будет доставлено повторно (at-least-once):
+// получатель должен уметь пропускать повторы по Event.Seq.
+// Вызывается периодически внешним планировщиком.
+func (s *Service) DispatchOutbox(ctx context.Context, sink OutboxSink) (delivered int, err error) {
+	call := s.beginAudit("DispatchOutbox", 0, "")
+	defer func() { err = call.end(strconv.Itoa(delivered), err) }()
+
+	for _, entry := range s.outbox {
+		if entry.Delivered {
+			continue
+		}
+
+		err = ctx.Err()
+		if err != nil {
+			return delivered, err
+		}
+
+		s.saveOutbox()
+		s.touchOutbox(entry)
+		entry.Attempts++
+		err = sink(entry.Event)
+		if err != nil {
+			return delivered, err
+		}
+
+		entry.Delivered = true
+		delivered++
+	}
+
+	return delivered, nil
+}
+
+// CompactOutbox удаляет доставленные события из очереди (и из хранилища) и возвращает их количество.
+// Если хранилище не приняло удаление, очередь не меняется, а ошибка попадает в журнал аудита и лог.
+func (s *Service) CompactOutbox() (removed int) {
+	call := s.beginAudit("CompactOutbox", 0, "")
+	defer func() {
+		err := call.end(strconv.Itoa(removed), nil)
+		if err != nil {
+			log.Print(err)
+			removed = 0
+		}
+	}()
+
+	s.saveOutbox()
+	pending := []*OutboxEntry{}
+	for _, entry := range s.outbox {
+		if entry.Delivered {
+			s.removeOutbox(entry)
+			continue
+		}
+		pending = append(pending, entry)
+	}
+
+	removed = len(s.outbox) - len(pending)
+	s.outbox = pending
+
+	return removed
+}
+
+func outboxFields(entry *OutboxEntry) []string {
+	event := entry.Event
+	return []string{
+		strconv.FormatUint(event.Seq, 10),
+		string(event.Type),
+		encodeTime(event.Time),
+		strconv.FormatInt(event.AccountID, 10),
+		strconv.FormatInt(int64(event.Amount), 10),
+		string(event.Category),
+		string(event.Phone),
+		event.PaymentID,
+		event.FavoriteID,
+		event.HoldID,
+		strconv.FormatBool(entry.Delivered),
+		strconv.Itoa(entry.Attempts),
+	}
+}
+
+func encodeOutboxEntry(entry *OutboxEntry) string {
+	return strings.Join(outboxFields(entry), ";")
+}
+
+func decodeOutboxEntry(line string) (*OutboxEntry, error) {
+	return decodeOutboxFields(strings.Split(line, ";"))
+}
+
+func decodeOutboxFields(data []string) (*OutboxEntry, error) {
+	if len(data) < 12 {
+		return nil, ErrInvalidDump
+	}
+
+	seq, err := strconv.ParseUint(data[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	eventTime, err := decodeTime(data[2])
+	if err != nil {
+		return nil, err
+	}
+
+	accountID, err := strconv.ParseInt(data[3], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	amount, err := strconv.ParseInt(data[4], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	delivered, err := strconv.ParseBool(data[10])
+	if err != nil {
+		return nil, err
+	}
+
+	attempts, err := strconv.Atoi(data[11])
+	if err != nil {
+		return nil, err
+	}
+
+	return &OutboxEntry{
+		Event: Event{
+			Seq:        seq,
+			Type:       EventType(data[1]),
+			Time:       eventTime,
+			AccountID:  accountID,
+			Amount:     types.Money(amount),
+			Category:   types.PaymentCategory(data[5]),
+			Phone:      types.Phone(data[6]),
+			PaymentID:  data[7],
+			FavoriteID: data[8],
+			HoldID:     data[9],
+		},
+		Delivered: delivered,
+		Attempts:  attempts,
+	}, nil
+}
+
+// sameOutboxEvent сравнивает события без состояния доставки, время - с точностью дампа.
+func sameOutboxEvent(a, b *OutboxEntry) bool {
+	return encodeOutboxEntry(&OutboxEntry{Event: a.Event}) == encodeOutboxEntry(&OutboxEntry{Event: b.Event})
+}
+
+// sameOutboxContent - sameOutboxEvent без учёта номера события.
+func sameOutboxContent(a, b *OutboxEntry) bool {
+	event := b.Event
+	event.Seq = a.Event.Seq
+	return sameOutboxEvent(a, &OutboxEntry{Event: event})
+}
+
+func (s *Service) outboxSection() dumpSection {
+	section := dumpSection{name: "outbox.dump"}
+	for _, entry := range s.outbox {
+		section.lines = append(section.lines, encodeOutboxEntry(entry))
+	}
+
+	return section
+}
+
+// importOutbox добавляет событие в очередь или обновляет состояние доставки того же события,
+// импортированного ранее. Если под номером события в очереди уже другое (локальное) событие,
+// импортированное получает следующий свободный номер, а локальное не меняется;
+// событие, уже перенумерованное прошлым импортом, находится по содержимому и не дублируется.
+// Нумерация новых событий продолжается после последнего импортированного.
+func (s *Service) importOutbox(line string) error {
+	entry, err := decodeOutboxEntry(line)
+	if err != nil {
+		return err
+	}
+
+	s.importOutboxEntry(entry)
+	return nil
+}
+
+// importOutboxEntry - importOutbox для разобранного события (из дампа или хранилища).
+func (s *Service) importOutboxEntry(entry *OutboxEntry) {
+	s.saveOutbox()
+	s.touchOutbox(entry)
+
+	for i, entryCheck := range s.outbox {
+		if entryCheck.Event.Seq != entry.Event.Seq {
+			continue
+		}
+
+		if sameOutboxEvent(entryCheck, entry) {
+			s.outbox[i] = entry
+			return
+		}
+
+		for j, renumbered := range s.outbox {
+			if sameOutboxContent(renumbered, entry) {
+				entry.Event.Seq = renumbered.Event.Seq
+				s.outbox[j] = entry
+				return
+			}
+		}
+
+		s.eventSeq++
+		entry.Event.Seq = s.eventSeq
+		break
+	}
+
+	if entry.Event.Seq > s.eventSeq {
+		s.eventSeq = entry.Event.Seq
+	}
+
+	s.outbox = append(s.outbox, entry)
+	sort.SliceStable(s.outbox, func(i, j int) bool {
+		return s.outbox[i].Event.Seq < s.outbox[j].Event.Seq
+	})
+}
@@ -0,0 +1,204 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestService_DispatchOutbox(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(svc.Outbox()) != 3 {
+		t.Fatalf("invalid outbox size, got %v, want 3", len(svc.Outbox()))
+	}
+
+	errSink := errors.New("sink unavailable")
+	received := []Event{}
+	sink := func(event Event) error {
+		if event.Type == EventPaymentCreated && len(received) < 3 {
+			received = append(received, event)
+			return errSink
+		}
+		received = append(received, event)
+		return nil
+	}
+
+	delivered, err := svc.DispatchOutbox(context.Background(), sink)
+	if err != errSink || delivered != 2 {
+		t.Fatalf("invalid dispatch result, got %v, %v", delivered, err)
+	}
+
+	pending := svc.Outbox()
+	if len(pending) != 1 || pending[0].Event.Type != EventPaymentCreated || pending[0].Attempts != 1 {
+		t.Fatalf("invalid pending events: %+v", pending)
+	}
+
+	delivered, err = svc.DispatchOutbox(context.Background(), sink)
+	if err != nil || delivered != 1 {
+		t.Fatalf("invalid dispatch result, got %v, %v", delivered, err)
+	}
+
+	// событие, не принятое получателем, доставлено повторно
+	if len(received) != 4 || received[2].Seq != received[3].Seq {
+		t.Errorf("invalid received events: %+v", received)
+	}
+
+	if len(svc.Outbox()) != 0 {
+		t.Errorf("outbox must be empty, got %+v", svc.Outbox())
+	}
+
+	if svc.CompactOutbox() != 3 || len(svc.outbox) != 0 {
+		t.Errorf("delivered events not compacted: %v", len(svc.outbox))
+	}
+}
+
+func TestService_DispatchOutbox_canceled(t *testing.T) {
+	svc := &Service{}
+
+	_, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	delivered, err := svc.DispatchOutbox(ctx, func(event Event) error { return nil })
+	if err != context.Canceled || delivered != 0 || len(svc.Outbox()) != 1 {
+		t.Errorf("invalid dispatch result, got %v, %v", delivered, err)
+	}
+}
+
+func TestService_ExportImport_outbox(t *testing.T) {
+	dir := t.TempDir()
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.DispatchOutbox(context.Background(), func(event Event) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Deposit(account.ID, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newSvc := &Service{}
+	err = newSvc.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending := newSvc.Outbox()
+	if len(pending) != 2 || pending[0].Event.Type != EventDeposited || pending[0].Event.Amount != 500 || pending[0].Event.AccountID != account.ID {
+		t.Fatalf("invalid imported outbox: %+v", pending)
+	}
+
+	err = newSvc.Deposit(account.ID, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := []Event{}
+	_, err = newSvc.DispatchOutbox(context.Background(), func(event Event) error {
+		received = append(received, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(received) != 3 || received[0].Seq != 2 || received[2].Seq != 4 || received[2].Type != EventDeposited {
+		t.Errorf("invalid delivered events: %+v", received)
+	}
+}
+
+func TestService_Import_outboxRenumbered(t *testing.T) {
+	dir := t.TempDir()
+
+	svc := &Service{}
+	_, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// повторный импорт тех же событий не создаёт дублей
+	err = svc.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(svc.Outbox()) != 1 {
+		t.Errorf("invalid outbox after reimport: %+v", svc.Outbox())
+	}
+
+	// у другого сервиса своё событие с тем же номером
+	other := &Service{}
+	_, err = other.RegisterAccount("+992000000002")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = other.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending := other.Outbox()
+	if len(pending) != 2 || pending[0].Event.Seq != 1 || pending[0].Event.Phone != "+992000000002" ||
+		pending[1].Event.Seq != 2 || pending[1].Event.Phone != "+992000000001" {
+		t.Errorf("invalid outbox after import: %+v", pending)
+	}
+
+	// перенумерованное событие при повторном импорте тоже не дублируется
+	err = other.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(other.Outbox()) != 2 {
+		t.Errorf("invalid outbox after second import: %+v", other.Outbox())
+	}
+
+	err = other.Deposit(1, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending = other.Outbox()
+	if last := pending[len(pending)-1]; last.Event.Seq != 3 {
+		t.Errorf("invalid seq of new event, got %v, want 3", last.Event.Seq)
+	}
+}
@@ -0,0 +1,117 @@
+package wallet
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+// OverdraftCategory - категория платежей, которыми списываются проценты и комиссии за овердрафт.
+const OverdraftCategory types.PaymentCategory = "overdraft"
+
+// OverdraftAccrualFunc вычисляет сумму, которую нужно списать с аккаунта в овердрафте.
+// Ноль или отрицательное значение - ничего не списывать.
+type OverdraftAccrualFunc func(account types.Account, now time.Time) types.Money
+
+// OverdraftInterest начисляет basisPoints сотых долей процента от суммы долга.
+func OverdraftInterest(basisPoints int64) OverdraftAccrualFunc {
+	return func(account types.Account, now time.Time) types.Money {
+		return types.Money(-int64(account.Balance) * basisPoints / 10_000)
+	}
+}
+
+// OverdraftFee списывает фиксированную комиссию с каждого аккаунта в овердрафте.
+func OverdraftFee(fee types.Money) OverdraftAccrualFunc {
+	return func(account types.Account, now time.Time) types.Money {
+		return fee
+	}
+}
+
+// SetOverdraft разрешает балансу аккаунта уходить в минус не более чем на limit.
+// Ноль отключает овердрафт; уже возникший долг при этом сохраняется.
+func (s *Service) SetOverdraft(accountID int64, limit types.Money) (err error) {
+	call := s.beginAudit("SetOverdraft", accountID, "", "limit", limit)
+	defer func() { err = call.end("", err) }()
+
+	if limit < 0 {
+		return ErrAmountMustBePositive
+	}
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+
+	s.touchAccount(account)
+	account.Overdraft = limit
+	return nil
+}
+
+// AddOverdraftAccrual регистрирует правило начисления процентов или комиссий на отрицательный баланс.
+func (s *Service) AddOverdraftAccrual(fn OverdraftAccrualFunc) {
+	s.overdraftAccruals = append(s.overdraftAccruals, fn)
+}
+
+// AccrueOverdraft применяет правила начисления ко всем активным аккаунтам с отрицательным балансом
+// и возвращает платежи, которыми были списаны начисления. Замороженные и закрытые аккаунты пропускаются.
+// Начисление урезается так, чтобы баланс не ушёл ниже -Overdraft; аккаунт, уже стоящий на лимите
+// (или с долгом после отключения овердрафта), больше не начисляется.
+// Вызывается периодически (например, раз в день) внешним планировщиком.
+func (s *Service) AccrueOverdraft() []types.Payment {
+	call := s.beginAudit("AccrueOverdraft", 0, "")
+	defer call.endUnchecked()
+
+	now := s.now()
+	charges := []types.Payment{}
+
+	for _, account := range s.accounts {
+		if account.Balance >= 0 || checkAccountActive(account) != nil {
+			continue
+		}
+
+		for _, accrual := range s.overdraftAccruals {
+			amount := accrual(*account, now)
+			room := account.Balance + account.Overdraft
+			if amount > room {
+				amount = room
+			}
+			if amount <= 0 {
+				continue
+			}
+
+			s.touchAccount(account)
+			account.Balance -= amount
+
+			payment := &types.Payment{
+				ID:        s.newID(),
+				AccountID: account.ID,
+				Amount:    amount,
+				Category:  OverdraftCategory,
+				Status:    types.PaymentStatusOk,
+				CreatedAt: now,
+			}
+			s.addPayment(payment)
+			charges = append(charges, *payment)
+			s.publish(paymentEvent(EventPaymentCreated, payment))
+		}
+	}
+
+	return charges
+}
+
+// AccountsInOverdraft возвращает аккаунты с отрицательным балансом, начиная с самого большого долга.
+func (s *Service) AccountsInOverdraft() []types.Account {
+	accounts := []types.Account{}
+	for _, account := range s.accounts {
+		if account.Balance < 0 {
+			accounts = append(accounts, *account)
+		}
+	}
+
+	sort.SliceStable(accounts, func(i, j int) bool {
+		return accounts[i].Balance < accounts[j].Balance
+	})
+
+	return accounts
+}
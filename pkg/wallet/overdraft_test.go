@@ -0,0 +1,218 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+func TestService_Pay_overdraft(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Deposit(account.ID, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.SetOverdraft(account.ID, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Pay(account.ID, 600, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if account.Balance != -500 {
+		t.Errorf("invalid balance, got %v, want %v", account.Balance, -500)
+	}
+
+	_, err = svc.Pay(account.ID, 1, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Errorf("invalid error, got %v, want %v", err, ErrNotEnoughBalance)
+	}
+}
+
+func TestService_SetOverdraft_invalid(t *testing.T) {
+	svc := &Service{}
+
+	err := svc.SetOverdraft(1, 100)
+	if err != ErrAccountNotFound {
+		t.Errorf("invalid error, got %v, want %v", err, ErrAccountNotFound)
+	}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.SetOverdraft(account.ID, -1)
+	if err != ErrAmountMustBePositive {
+		t.Errorf("invalid error, got %v, want %v", err, ErrAmountMustBePositive)
+	}
+}
+
+func TestService_AccrueOverdraft(t *testing.T) {
+	svc := &Service{}
+	svc.AddOverdraftAccrual(OverdraftInterest(100))
+	svc.AddOverdraftAccrual(OverdraftFee(5))
+
+	debtor, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payer, err := svc.RegisterAccount("+992000000002")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Deposit(payer.ID, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.SetOverdraft(debtor.ID, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Pay(debtor.ID, 900, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	charges := svc.AccrueOverdraft()
+	if len(charges) != 2 {
+		t.Fatalf("invalid charges count, got %v, want %v", len(charges), 2)
+	}
+
+	if charges[0].Amount != 9 || charges[1].Amount != 5 || charges[0].Category != OverdraftCategory {
+		t.Errorf("invalid charges: %v", charges)
+	}
+
+	if debtor.Balance != -914 {
+		t.Errorf("invalid balance, got %v, want %v", debtor.Balance, -914)
+	}
+
+	if payer.Balance != 100 {
+		t.Errorf("positive balance must not be charged, got %v", payer.Balance)
+	}
+}
+
+func TestService_AccrueOverdraft_limit(t *testing.T) {
+	svc, account, _ := newTestService(t, 0)
+	svc.AddOverdraftAccrual(OverdraftInterest(100))
+	svc.AddOverdraftAccrual(OverdraftFee(5))
+
+	err := svc.SetOverdraft(account.ID, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustPay(t, svc, account.ID, 990, "auto")
+
+	// проценты 9 помещаются в лимит, от комиссии 5 остаётся 1
+	charges := svc.AccrueOverdraft()
+	if len(charges) != 2 || charges[0].Amount != 9 || charges[1].Amount != 1 || account.Balance != -1000 {
+		t.Errorf("invalid charges %v, balance %v", charges, account.Balance)
+	}
+
+	charges = svc.AccrueOverdraft()
+	if len(charges) != 0 || account.Balance != -1000 {
+		t.Errorf("account on the limit charged: %v, balance %v", charges, account.Balance)
+	}
+}
+
+func TestService_AccrueOverdraft_inactive(t *testing.T) {
+	svc, account, _ := newTestService(t, 0)
+	svc.AddOverdraftAccrual(OverdraftFee(5))
+
+	err := svc.SetOverdraft(account.ID, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustPay(t, svc, account.ID, 500, "auto")
+
+	err = svc.FreezeAccount(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	charges := svc.AccrueOverdraft()
+	if len(charges) != 0 || account.Balance != -500 {
+		t.Errorf("frozen account charged: %v, balance %v", charges, account.Balance)
+	}
+}
+
+func TestService_AccountsInOverdraft(t *testing.T) {
+	svc := &Service{}
+
+	for i, balance := range []types.Money{-10, 5, -30, 0} {
+		account, err := svc.RegisterAccount(types.Phone("+99200000000" + string(rune('0'+i))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		account.Balance = balance
+	}
+
+	accounts := svc.AccountsInOverdraft()
+	if len(accounts) != 2 || accounts[0].Balance != -30 || accounts[1].Balance != -10 {
+		t.Errorf("invalid overdraft report: %v", accounts)
+	}
+}
+
+func TestService_ExportImport_overdraft(t *testing.T) {
+	dir := t.TempDir()
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.SetOverdraft(account.ID, 700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := svc.Pay(account.ID, 200, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newSvc := &Service{}
+	err = newSvc.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := newSvc.FindAccountByID(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if imported.Overdraft != 700 || imported.Balance != -200 {
+		t.Errorf("invalid imported account: %v", imported)
+	}
+
+	importedPayment, err := newSvc.FindPaymentByID(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !importedPayment.CreatedAt.Equal(payment.CreatedAt.Truncate(time.Second)) {
+		t.Errorf("invalid imported payment time, got %v, want %v", importedPayment.CreatedAt, payment.CreatedAt)
+	}
+}
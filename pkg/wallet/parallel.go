@@ -0,0 +1,179 @@
+package wallet
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+// MapFunc обрабатывает одну часть платежей и возвращает частичный результат.
+// part - номер части (начиная с 0), payments - платежи этой части.
+type MapFunc func(ctx context.Context, part int, payments []*types.Payment) (interface{}, error)
+
+// ReduceFunc объединяет частичный результат с накопленным значением.
+// Вызывается последовательно, в порядке номеров частей.
+type ReduceFunc func(acc interface{}, part int, result interface{}) interface{}
+
+// splitParts делит n элементов на parts почти равных частей
+// и возвращает границы [from, to) каждой части.
+func splitParts(n, parts int) [][2]int {
+	if parts > n {
+		parts = n
+	}
+	if parts <= 0 {
+		parts = 1
+	}
+
+	bounds := make([][2]int, 0, parts)
+	count := n / parts
+	rest := n % parts
+	from := 0
+	for i := 0; i < parts; i++ {
+		to := from + count
+		if i < rest {
+			to++
+		}
+		bounds = append(bounds, [2]int{from, to})
+		from = to
+	}
+
+	return bounds
+}
+
+// mapReduce делит payments на parts частей, обрабатывает каждую часть mapFn
+// в отдельной горутине и объединяет результаты reduceFn в порядке частей,
+// поэтому результат совпадает с последовательным вычислением.
+// При отмене ctx или первой ошибке mapFn остальные части не запускаются
+// и возвращается эта ошибка.
+func mapReduce(ctx context.Context, payments []*types.Payment, parts int, initial interface{}, mapFn MapFunc, reduceFn ReduceFunc) (interface{}, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	bounds := splitParts(len(payments), parts)
+	results := make([]interface{}, len(bounds))
+
+	wg := sync.WaitGroup{}
+	once := sync.Once{}
+	var firstErr error
+
+	for i, bound := range bounds {
+		wg.Add(1)
+		go func(part int, payments []*types.Payment) {
+			defer wg.Done()
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			result, err := mapFn(ctx, part, payments)
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			results[part] = result
+		}(i, payments[bound[0]:bound[1]])
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	acc := initial
+	for part, result := range results {
+		acc = reduceFn(acc, part, result)
+	}
+
+	return acc, nil
+}
+
+// MapReducePayments параллельно обрабатывает все платежи сервиса
+// в goroutines частях и объединяет частичные результаты.
+func (s *Service) MapReducePayments(ctx context.Context, goroutines int, initial interface{}, mapFn MapFunc, reduceFn ReduceFunc) (interface{}, error) {
+	return mapReduce(ctx, s.payments, goroutines, initial, mapFn, reduceFn)
+}
+
+// PaymentsSnapshot - копия платежей сервиса, снятая SnapshotPayments.
+// Её можно обрабатывать без блокировки сервиса: последующие изменения сервиса на неё не влияют.
+type PaymentsSnapshot struct {
+	payments []*types.Payment
+}
+
+// SnapshotPayments копирует все платежи сервиса.
+func (s *Service) SnapshotPayments() *PaymentsSnapshot {
+	payments := make([]*types.Payment, 0, len(s.payments))
+	for _, payment := range s.payments {
+		copied := *payment
+		payments = append(payments, &copied)
+	}
+
+	return &PaymentsSnapshot{payments: payments}
+}
+
+// SumWithProgressFunc суммирует платежи снимка в goroutines горутинах, сообщая fn о каждой посчитанной части.
+func (p *PaymentsSnapshot) SumWithProgressFunc(ctx context.Context, goroutines int, fn ProgressFunc) (types.Money, error) {
+	return sumPaymentsWithProgress(ctx, p.payments, goroutines, fn)
+}
+
+func sumPaymentsWithProgress(ctx context.Context, payments []*types.Payment, goroutines int, fn ProgressFunc) (types.Money, error) {
+	reporter := newProgressReporter(len(payments), fn)
+	summ, err := mapReduce(ctx, payments, goroutines, types.Money(0), withProgress(sumMapper, reporter), sumReducer)
+	if err != nil {
+		return 0, err
+	}
+
+	return summ.(types.Money), nil
+}
+
+// checkEvery - как часто (в платежах) обработчики частей проверяют отмену контекста.
+const checkEvery = 1024
+
+func sumMapper(ctx context.Context, part int, payments []*types.Payment) (interface{}, error) {
+	sum := types.Money(0)
+	for i, payment := range payments {
+		if i%checkEvery == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		sum += payment.Amount
+	}
+
+	return sum, nil
+}
+
+func sumReducer(acc interface{}, part int, result interface{}) interface{} {
+	return acc.(types.Money) + result.(types.Money)
+}
+
+func filterMapper(filter func(payment types.Payment) bool) MapFunc {
+	return func(ctx context.Context, part int, payments []*types.Payment) (interface{}, error) {
+		found := []types.Payment{}
+		for i, payment := range payments {
+			if i%checkEvery == 0 && ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if filter(*payment) {
+				found = append(found, *payment)
+			}
+		}
+
+		return found, nil
+	}
+}
+
+func filterReducer(acc interface{}, part int, result interface{}) interface{} {
+	return append(acc.([]types.Payment), result.([]types.Payment)...)
+}
@@ -0,0 +1,198 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+func newServiceWithPayments(amounts []int16) *Service {
+	svc := &Service{}
+	for i, amount := range amounts {
+		svc.payments = append(svc.payments, &types.Payment{
+			ID:        "p" + string(rune('a'+i%26)),
+			AccountID: int64(i%3 + 1),
+			Amount:    types.Money(amount),
+		})
+	}
+
+	return svc
+}
+
+func serialSum(svc *Service) types.Money {
+	sum := types.Money(0)
+	for _, payment := range svc.payments {
+		sum += payment.Amount
+	}
+
+	return sum
+}
+
+func serialFilter(svc *Service, filter func(payment types.Payment) bool) []types.Payment {
+	var found []types.Payment
+	for _, payment := range svc.payments {
+		if filter(*payment) {
+			found = append(found, *payment)
+		}
+	}
+
+	return found
+}
+
+func TestSplitParts(t *testing.T) {
+	property := func(n uint16, parts int8) bool {
+		bounds := splitParts(int(n), int(parts))
+		if len(bounds) == 0 {
+			return false
+		}
+
+		from := 0
+		for _, bound := range bounds {
+			if bound[0] != from || bound[1] < bound[0] {
+				return false
+			}
+			from = bound[1]
+		}
+
+		return from == int(n)
+	}
+
+	err := quick.Check(property, nil)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestService_SumPayments_matchesSerial(t *testing.T) {
+	property := func(amounts []int16, goroutines int8) bool {
+		svc := newServiceWithPayments(amounts)
+		return svc.SumPayments(int(goroutines)) == serialSum(svc)
+	}
+
+	err := quick.Check(property, nil)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestService_FilterPayments_matchesSerial(t *testing.T) {
+	property := func(amounts []int16, goroutines int8) bool {
+		svc := newServiceWithPayments(amounts)
+		want := serialFilter(svc, func(payment types.Payment) bool {
+			return payment.AccountID == 2
+		})
+
+		got, err := svc.FilterPayments(2, int(goroutines))
+		if len(want) == 0 {
+			return err == ErrAccountNotFound
+		}
+
+		return err == nil && reflect.DeepEqual(got, want)
+	}
+
+	err := quick.Check(property, nil)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestService_FilterPaymentsByFn_matchesSerial(t *testing.T) {
+	filter := func(payment types.Payment) bool {
+		return payment.Amount%2 == 0
+	}
+
+	property := func(amounts []int16, goroutines int8) bool {
+		svc := newServiceWithPayments(amounts)
+		want := serialFilter(svc, filter)
+
+		got, err := svc.FilterPaymentsByFn(filter, int(goroutines))
+		if len(want) == 0 {
+			return err == ErrAccountNotFound
+		}
+
+		return err == nil && reflect.DeepEqual(got, want)
+	}
+
+	err := quick.Check(property, nil)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestService_SumPaymentsWithProgress_matchesSerial(t *testing.T) {
+	svc := &Service{}
+	for i := 0; i < 2_500_000; i++ {
+		svc.payments = append(svc.payments, &types.Payment{Amount: types.Money(i % 7)})
+	}
+
+	parts := map[int]bool{}
+	sum := types.Money(0)
+	for progress := range svc.SumPaymentsWithProgress() {
+		if parts[progress.Part] {
+			t.Errorf("part %v reported twice", progress.Part)
+		}
+		parts[progress.Part] = true
+		sum += progress.Result
+	}
+
+	if len(parts) != 3 {
+		t.Errorf("invalid parts count, got %v, want %v", len(parts), 3)
+	}
+
+	if sum != serialSum(svc) {
+		t.Errorf("invalid result, got %v, want %v", sum, serialSum(svc))
+	}
+}
+
+func TestService_SumPaymentsContext_canceled(t *testing.T) {
+	svc := newServiceWithPayments(make([]int16, 100))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := svc.SumPaymentsContext(ctx, 4)
+	if err != context.Canceled {
+		t.Errorf("invalid error, got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestService_MapReducePayments_mapError(t *testing.T) {
+	svc := newServiceWithPayments(make([]int16, 100))
+	errPart := errors.New("part failed")
+
+	mapper := func(ctx context.Context, part int, payments []*types.Payment) (interface{}, error) {
+		if part == 2 {
+			return nil, errPart
+		}
+		return sumMapper(ctx, part, payments)
+	}
+
+	_, err := svc.MapReducePayments(context.Background(), 5, types.Money(0), mapper, sumReducer)
+	if err != errPart {
+		t.Errorf("invalid error, got %v, want %v", err, errPart)
+	}
+}
+
+func TestService_SnapshotPayments(t *testing.T) {
+	svc := newServiceWithPayments([]int16{1, 2, 3, 4})
+	snapshot := svc.SnapshotPayments()
+
+	// изменения после снимка на него не влияют
+	svc.payments[0].Amount = 100
+	svc.payments = append(svc.payments, &types.Payment{Amount: 1000})
+
+	parts := 0
+	sum, err := snapshot.SumWithProgressFunc(context.Background(), 2, func(progress types.Progress) {
+		parts++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != 10 || parts != 2 {
+		t.Errorf("invalid snapshot sum %v in %v parts, want 10 in 2 parts", sum, parts)
+	}
+}
@@ -0,0 +1,300 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// Границы длины номера в формате E.164 (цифры вместе с кодом страны).
+const (
+	minPhoneDigits = 8
+	maxPhoneDigits = 15
+	// номера без "+" длиннее этого уже содержат код страны
+	maxNationalDigits = 10
+)
+
+// NormalizePhone приводит номер к формату E.164 ("+992000000001").
+// Пробелы, дефисы, точки и скобки отбрасываются, префикс "00" заменяется на "+".
+// Номер без "+" длиной до 10 цифр считается национальным: ведущий 0 отбрасывается
+// и добавляется defaultCountryCode (без "+"); если он пуст, такой номер ошибочен.
+func NormalizePhone(phone string, defaultCountryCode string) (types.Phone, error) {
+	raw := phone
+	phone = strings.TrimSpace(phone)
+
+	international := false
+	if strings.HasPrefix(phone, "+") {
+		international = true
+		phone = phone[1:]
+	}
+
+	digits := strings.Builder{}
+	for _, r := range phone {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", fmt.Errorf("%w %q: unexpected character %q", ErrInvalidPhone, raw, r)
+		}
+	}
+
+	number := digits.String()
+	if !international && strings.HasPrefix(number, "00") {
+		international = true
+		number = number[2:]
+	}
+
+	if !international && len(number) <= maxNationalDigits {
+		if defaultCountryCode == "" {
+			return "", fmt.Errorf("%w %q: country code required", ErrInvalidPhone, raw)
+		}
+		number = defaultCountryCode + strings.TrimPrefix(number, "0")
+	}
+
+	if len(number) < minPhoneDigits || len(number) > maxPhoneDigits {
+		return "", fmt.Errorf("%w %q: must have %v to %v digits", ErrInvalidPhone, raw, minPhoneDigits, maxPhoneDigits)
+	}
+
+	if number[0] == '0' {
+		return "", fmt.Errorf("%w %q: country code can't start with 0", ErrInvalidPhone, raw)
+	}
+
+	return types.Phone("+" + number), nil
+}
+
+// SetDefaultCountryCode задаёт код страны (например "992"), добавляемый к национальным номерам.
+func (s *Service) SetDefaultCountryCode(code string) (err error) {
+	call := s.beginAudit("SetDefaultCountryCode", 0, "", "code", code)
+	defer func() { err = call.end("", err) }()
+
+	code = strings.TrimPrefix(code, "+")
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("%w: invalid country code %q", ErrInvalidPhone, code)
+		}
+	}
+
+	if len(code) > 3 || strings.HasPrefix(code, "0") {
+		return fmt.Errorf("%w: invalid country code %q", ErrInvalidPhone, code)
+	}
+
+	s.defaultCountryCode = code
+	// номера из старых файлов с новым кодом страны могут нормализоваться иначе
+	s.reindexPhones()
+	return nil
+}
+
+func (s *Service) normalizePhone(phone types.Phone) (types.Phone, error) {
+	return NormalizePhone(string(phone), s.defaultCountryCode)
+}
+
+// phoneKey - ключ номера в индексе: нормализованный номер. Сохранённые номера тоже приводятся
+// к нормальному виду (они могли быть импортированы из старых файлов), а ошибочные берутся как есть.
+func (s *Service) phoneKey(phone types.Phone) types.Phone {
+	normalized, err := s.normalizePhone(phone)
+	if err != nil {
+		return phone
+	}
+
+	return normalized
+}
+
+// indexPhone добавляет аккаунт в индекс по номеру телефона.
+func (s *Service) indexPhone(account *types.Account) {
+	if s.phoneIndex == nil {
+		s.phoneIndex = map[types.Phone]*types.Account{}
+	}
+
+	s.phoneIndex[s.phoneKey(account.Phone)] = account
+}
+
+// unindexPhone убирает из индекса номер phone, если он указывает на account.
+func (s *Service) unindexPhone(account *types.Account, phone types.Phone) {
+	key := s.phoneKey(phone)
+	if s.phoneIndex[key] == account {
+		delete(s.phoneIndex, key)
+	}
+}
+
+// reindexPhones заново строит индекс по номерам всех аккаунтов.
+func (s *Service) reindexPhones() {
+	s.phoneIndex = nil
+	for _, account := range s.accounts {
+		s.indexPhone(account)
+	}
+}
+
+// setPhone меняет номер аккаунта вместе с индексом; при откате индекс возвращается к прежнему номеру
+// (сам номер восстанавливает touchAccount).
+func (s *Service) setPhone(account *types.Account, phone types.Phone) {
+	old := account.Phone
+	s.unindexPhone(account, old)
+	account.Phone = phone
+	s.indexPhone(account)
+
+	s.onRollback(func() {
+		s.unindexPhone(account, phone)
+		s.phoneIndex[s.phoneKey(old)] = account
+	})
+}
+
+// findAccountByNormalizedPhone ищет аккаунт с нормализованным номером phone по индексу.
+func (s *Service) findAccountByNormalizedPhone(phone types.Phone) *types.Account {
+	return s.phoneIndex[phone]
+}
+
+// PhoneChange - запись о смене номера телефона аккаунта.
+type PhoneChange struct {
+	AccountID int64
+	OldPhone  types.Phone
+	NewPhone  types.Phone
+	Time      time.Time
+}
+
+// FindAccountByPhone ищет аккаунт по номеру телефона в любом допустимом написании.
+func (s *Service) FindAccountByPhone(phone types.Phone) (*types.Account, error) {
+	phone, err := s.normalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
+
+	account := s.findAccountByNormalizedPhone(phone)
+	if account == nil {
+		return nil, ErrAccountNotFound
+	}
+
+	return account, nil
+}
+
+// ChangePhone меняет номер телефона аккаунта (например, при замене SIM-карты)
+// и записывает старый и новый номер в историю смен.
+func (s *Service) ChangePhone(accountID int64, newPhone types.Phone) (err error) {
+	call := s.beginAudit("ChangePhone", accountID, "", "phone", newPhone)
+	defer func() { err = call.end("", err) }()
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+
+	if account.Status == types.AccountStatusClosed {
+		return ErrAccountClosed
+	}
+
+	newPhone, err = s.normalizePhone(newPhone)
+	if err != nil {
+		return err
+	}
+
+	existing := s.findAccountByNormalizedPhone(newPhone)
+	if existing != nil {
+		if existing.ID == account.ID {
+			return nil
+		}
+		return ErrPhoneNumberRegistred
+	}
+
+	s.addPhoneChange(PhoneChange{
+		AccountID: account.ID,
+		OldPhone:  account.Phone,
+		NewPhone:  newPhone,
+		Time:      s.now(),
+	})
+	s.touchAccount(account)
+	s.setPhone(account, newPhone)
+	s.publish(Event{Type: EventPhoneChanged, AccountID: account.ID, Phone: newPhone})
+
+	return nil
+}
+
+// PhoneHistory возвращает смены номера телефона аккаунта в порядке их выполнения.
+func (s *Service) PhoneHistory(accountID int64) []PhoneChange {
+	changes := []PhoneChange{}
+	for _, change := range s.phoneChanges {
+		if change.AccountID == accountID {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes
+}
+
+// phonesSection выгружает историю смен номеров телефона.
+func (s *Service) phonesSection() dumpSection {
+	section := dumpSection{name: "phones.dump"}
+	for _, change := range s.phoneChanges {
+		section.lines = append(section.lines, encodePhoneChange(change))
+	}
+
+	return section
+}
+
+func phoneChangeFields(change PhoneChange) []string {
+	return []string{
+		strconv.FormatInt(change.AccountID, 10),
+		string(change.OldPhone),
+		string(change.NewPhone),
+		encodeTime(change.Time),
+	}
+}
+
+func encodePhoneChange(change PhoneChange) string {
+	return strings.Join(phoneChangeFields(change), ";")
+}
+
+func decodePhoneChange(line string) (PhoneChange, error) {
+	return decodePhoneChangeFields(strings.Split(line, ";"))
+}
+
+func decodePhoneChangeFields(data []string) (PhoneChange, error) {
+	if len(data) != 4 {
+		return PhoneChange{}, ErrInvalidDump
+	}
+
+	accountID, err := strconv.ParseInt(data[0], 10, 64)
+	if err != nil {
+		return PhoneChange{}, err
+	}
+
+	changedAt, err := decodeTime(data[3])
+	if err != nil {
+		return PhoneChange{}, err
+	}
+
+	return PhoneChange{
+		AccountID: accountID,
+		OldPhone:  types.Phone(data[1]),
+		NewPhone:  types.Phone(data[2]),
+		Time:      changedAt,
+	}, nil
+}
+
+func (s *Service) importPhoneChange(line string) error {
+	change, err := decodePhoneChange(line)
+	if err != nil {
+		return err
+	}
+
+	s.upsertPhoneChange(change)
+	return nil
+}
+
+// upsertPhoneChange добавляет смену номера, если её ещё нет в истории
+// (повторный импорт того же файла не дублирует историю).
+func (s *Service) upsertPhoneChange(change PhoneChange) {
+	line := encodePhoneChange(change)
+	for _, existing := range s.phoneChanges {
+		if encodePhoneChange(existing) == line {
+			return
+		}
+	}
+
+	s.addPhoneChange(change)
+}
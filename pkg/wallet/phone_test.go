@@ -0,0 +1,259 @@
+package wallet
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		phone string
+		code  string
+		want  types.Phone
+	}{
+		{"+992000000001", "", "+992000000001"},
+		{"992 000 000 001", "", "+992000000001"},
+		{"00992-00-000-0001", "", "+992000000001"},
+		{"+992 (00) 000.00.01", "", "+992000000001"},
+		{"900 000 001", "992", "+992900000001"},
+		{"0 900 000 001", "992", "+992900000001"},
+	}
+
+	for _, test := range tests {
+		got, err := NormalizePhone(test.phone, test.code)
+		if err != nil {
+			t.Errorf("%q: %v", test.phone, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q: invalid phone, got %v, want %v", test.phone, got, test.want)
+		}
+	}
+}
+
+func TestNormalizePhone_invalid(t *testing.T) {
+	for _, phone := range []string{"", "+992abc", "12345", "+1234567890123456", "+0992000000001", "000 000 001"} {
+		_, err := NormalizePhone(phone, "")
+		if !errors.Is(err, ErrInvalidPhone) {
+			t.Errorf("%q: invalid error, got %v, want %v", phone, err, ErrInvalidPhone)
+		}
+	}
+}
+
+func TestService_RegisterAccount_normalizedDuplicate(t *testing.T) {
+	svc := &Service{}
+
+	err := svc.SetDefaultCountryCode("+992")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account, err := svc.RegisterAccount("+992900000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, phone := range []types.Phone{"992 900 000 001", "900-000-001", "0900000001", "00992900000001"} {
+		_, err = svc.RegisterAccount(phone)
+		if err != ErrPhoneNumberRegistred {
+			t.Errorf("%q: invalid error, got %v, want %v", phone, err, ErrPhoneNumberRegistred)
+		}
+	}
+
+	_, err = svc.RegisterAccount("not a phone")
+	if !errors.Is(err, ErrInvalidPhone) {
+		t.Errorf("invalid error, got %v, want %v", err, ErrInvalidPhone)
+	}
+
+	if account.Phone != "+992900000001" {
+		t.Errorf("invalid phone, got %v", account.Phone)
+	}
+}
+
+func TestService_SetDefaultCountryCode_invalid(t *testing.T) {
+	svc := &Service{}
+
+	for _, code := range []string{"99a", "0992", "09"} {
+		err := svc.SetDefaultCountryCode(code)
+		if !errors.Is(err, ErrInvalidPhone) {
+			t.Errorf("%q: invalid error, got %v, want %v", code, err, ErrInvalidPhone)
+		}
+	}
+}
+
+func TestService_Import_normalizedDuplicate(t *testing.T) {
+	dir := t.TempDir()
+
+	err := ioutil.WriteFile(filepath.Join(dir, "accounts.dump"), []byte("1;992 000 000 001;10\n2;+992000000001;20"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "payments.dump"), []byte(""), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := &Service{}
+	err = svc.Import(dir)
+	if err != ErrPhoneNumberRegistred {
+		t.Errorf("invalid error, got %v, want %v", err, ErrPhoneNumberRegistred)
+	}
+
+	if len(svc.accounts) != 1 || svc.accounts[0].Phone != "+992000000001" {
+		t.Errorf("invalid accounts: %v", svc.accounts)
+	}
+}
+
+func TestService_FindAccountByPhone(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992900000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := svc.FindAccountByPhone("992 900 000 001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found != account {
+		t.Errorf("invalid account, got %v, want %v", found, account)
+	}
+
+	_, err = svc.FindAccountByPhone("+992900000002")
+	if err != ErrAccountNotFound {
+		t.Errorf("invalid error, got %v, want %v", err, ErrAccountNotFound)
+	}
+}
+
+func TestService_ChangePhone(t *testing.T) {
+	now := time.Date(2021, 3, 15, 10, 0, 0, 0, time.UTC)
+	svc := &Service{}
+	svc.SetClock(func() time.Time {
+		return now
+	})
+
+	account, err := svc.RegisterAccount("+992900000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := svc.RegisterAccount("+992900000002")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.ChangePhone(account.ID, "992 900 000 002")
+	if err != ErrPhoneNumberRegistred {
+		t.Errorf("invalid error, got %v, want %v", err, ErrPhoneNumberRegistred)
+	}
+
+	err = svc.ChangePhone(account.ID, "+992900000003")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := svc.FindAccountByPhone("+992900000003")
+	if err != nil || found != account {
+		t.Errorf("account must be found by new phone, got %v, %v", found, err)
+	}
+
+	_, err = svc.RegisterAccount("+992900000001")
+	if err != nil {
+		t.Errorf("old phone must be free: %v", err)
+	}
+
+	history := svc.PhoneHistory(account.ID)
+	want := []PhoneChange{{AccountID: account.ID, OldPhone: "+992900000001", NewPhone: "+992900000003", Time: now}}
+	if !reflect.DeepEqual(history, want) {
+		t.Errorf("invalid history, got %v, want %v", history, want)
+	}
+
+	if len(svc.PhoneHistory(other.ID)) != 0 {
+		t.Errorf("other account history must be empty")
+	}
+
+	err = svc.ChangePhone(99, "+992900000004")
+	if err != ErrAccountNotFound {
+		t.Errorf("invalid error, got %v, want %v", err, ErrAccountNotFound)
+	}
+}
+
+func TestService_ChangePhone_rollback(t *testing.T) {
+	svc := &Service{}
+	store := &failingStore{}
+	err := svc.SetStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := registerStored(t, svc, "+992900000001")
+
+	store.fail = true
+	err = svc.ChangePhone(account.ID, "+992900000003")
+	if err != errStoreDown {
+		t.Fatalf("invalid error, got %v, want %v", err, errStoreDown)
+	}
+
+	_, err = svc.RegisterAccount("+992900000002")
+	if err != errStoreDown {
+		t.Fatalf("invalid error, got %v, want %v", err, errStoreDown)
+	}
+
+	found, err := svc.FindAccountByPhone("+992900000001")
+	if err != nil || found != account {
+		t.Errorf("account must be found by old phone, got %v, %v", found, err)
+	}
+
+	for _, phone := range []types.Phone{"+992900000002", "+992900000003"} {
+		_, err = svc.FindAccountByPhone(phone)
+		if err != ErrAccountNotFound {
+			t.Errorf("%v: invalid error, got %v, want %v", phone, err, ErrAccountNotFound)
+		}
+	}
+}
+
+func TestService_ExportImport_phoneHistory(t *testing.T) {
+	now := time.Date(2021, 3, 15, 10, 0, 0, 0, time.UTC)
+	svc := &Service{}
+	svc.SetClock(func() time.Time {
+		return now
+	})
+
+	account, err := svc.RegisterAccount("+992900000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.ChangePhone(account.ID, "+992900000002")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported := &Service{}
+	for i := 0; i < 2; i++ {
+		err = imported.Import(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history := imported.PhoneHistory(account.ID)
+	if len(history) != 1 || history[0].OldPhone != "+992900000001" || history[0].NewPhone != "+992900000002" || !history[0].Time.Equal(now) {
+		t.Errorf("invalid history after import: %v", history)
+	}
+}
@@ -0,0 +1,99 @@
+package wallet
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+// ProgressFunc получает отчёт после завершения каждой части долгой операции
+// (суммирования, фильтрации, экспорта или импорта).
+// Вызовы сериализованы, поэтому функции не нужна собственная синхронизация.
+type ProgressFunc func(progress types.Progress)
+
+// ProgressToChannel возвращает ProgressFunc, отправляющую отчёты в ch.
+// Если потребитель перестал читать, отправка прерывается отменой ctx,
+// поэтому операция не зависает и горутины не утекают.
+func ProgressToChannel(ctx context.Context, ch chan<- types.Progress) ProgressFunc {
+	return func(progress types.Progress) {
+		select {
+		case ch <- progress:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// progressReporter считает обработанные элементы и оценивает оставшееся время.
+type progressReporter struct {
+	mu        sync.Mutex
+	fn        ProgressFunc
+	total     int
+	processed int
+	started   time.Time
+}
+
+func newProgressReporter(total int, fn ProgressFunc) *progressReporter {
+	return &progressReporter{
+		fn:      fn,
+		total:   total,
+		started: time.Now(),
+	}
+}
+
+// done сообщает о завершении части part, обработавшей count элементов.
+func (r *progressReporter) done(part int, count int, result types.Money) {
+	if r == nil || r.fn == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.processed += count
+
+	eta := time.Duration(0)
+	if r.processed > 0 && r.processed < r.total {
+		elapsed := time.Since(r.started)
+		eta = elapsed / time.Duration(r.processed) * time.Duration(r.total-r.processed)
+	}
+
+	r.fn(types.Progress{
+		Part:      part,
+		Result:    result,
+		Processed: r.processed,
+		Total:     r.total,
+		ETA:       eta,
+	})
+}
+
+// withProgress оборачивает mapFn так, что после каждой части вызывается reporter.
+// В Progress.Result попадает частичная сумма (см. partialSum).
+func withProgress(mapFn MapFunc, reporter *progressReporter) MapFunc {
+	return func(ctx context.Context, part int, payments []*types.Payment) (interface{}, error) {
+		result, err := mapFn(ctx, part, payments)
+		if err != nil {
+			return nil, err
+		}
+
+		reporter.done(part, len(payments), partialSum(result))
+		return result, nil
+	}
+}
+
+// partialSum возвращает частичный результат, если это сумма, или сумму отобранных платежей.
+func partialSum(result interface{}) types.Money {
+	switch result := result.(type) {
+	case types.Money:
+		return result
+	case []types.Payment:
+		sum := types.Money(0)
+		for _, payment := range result {
+			sum += payment.Amount
+		}
+		return sum
+	}
+
+	return 0
+}
@@ -0,0 +1,190 @@
+package wallet
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+func TestService_SumPaymentsWithProgressFunc(t *testing.T) {
+	svc := newServiceWithPayments(make([]int16, 1000))
+	for _, payment := range svc.payments {
+		payment.Amount = 2
+	}
+
+	reports := []types.Progress{}
+	sum, err := svc.SumPaymentsWithProgressFunc(context.Background(), 4, func(progress types.Progress) {
+		reports = append(reports, progress)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sum != 2000 {
+		t.Errorf("invalid result, got %v, want %v", sum, 2000)
+	}
+
+	if len(reports) != 4 {
+		t.Fatalf("invalid reports count, got %v, want %v", len(reports), 4)
+	}
+
+	resultSum := types.Money(0)
+	for i, report := range reports {
+		if report.Total != 1000 {
+			t.Errorf("invalid total, got %v, want %v", report.Total, 1000)
+		}
+		if report.Processed != (i+1)*250 {
+			t.Errorf("invalid processed, got %v, want %v", report.Processed, (i+1)*250)
+		}
+		resultSum += report.Result
+	}
+
+	if resultSum != sum {
+		t.Errorf("invalid parts sum, got %v, want %v", resultSum, sum)
+	}
+
+	if reports[3].ETA != 0 {
+		t.Errorf("invalid last ETA, got %v, want 0", reports[3].ETA)
+	}
+}
+
+func TestService_FilterPaymentsByFnWithProgress_result(t *testing.T) {
+	svc := newServiceWithPayments([]int16{1, 2, 3, 4, 5, 6})
+
+	resultSum := types.Money(0)
+	found, err := svc.FilterPaymentsByFnWithProgress(context.Background(), func(payment types.Payment) bool {
+		return payment.Amount%2 == 0
+	}, 3, func(progress types.Progress) {
+		resultSum += progress.Result
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(found) != 3 || resultSum != 12 {
+		t.Errorf("invalid progress result, got %v for %v payments, want 12", resultSum, len(found))
+	}
+}
+
+func TestService_SumPaymentsWithProgress_snapshot(t *testing.T) {
+	svc := newServiceWithPayments([]int16{1, 2, 3})
+
+	ch := svc.SumPaymentsWithProgress()
+	// платежи меняются, пока горутины ещё могут работать
+	svc.payments[0].Amount = 100
+	svc.payments = append(svc.payments, &types.Payment{Amount: 1000})
+
+	sum := types.Money(0)
+	for progress := range ch {
+		sum += progress.Result
+	}
+	if sum != 6 {
+		t.Errorf("invalid sum, got %v, want 6", sum)
+	}
+}
+
+func TestProgressToChannel_consumerStopped(t *testing.T) {
+	svc := newServiceWithPayments(make([]int16, 1000))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan types.Progress)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		svc.SumPaymentsWithProgressFunc(ctx, 4, ProgressToChannel(ctx, ch))
+	}()
+
+	<-ch
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("operation blocked after consumer stopped reading")
+	}
+}
+
+func TestService_ExportImportWithProgress(t *testing.T) {
+	dir := t.TempDir()
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account.Balance = 300
+	for i := 0; i < 3; i++ {
+		_, err = svc.Pay(account.ID, 100, "auto")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records := 0
+	for _, section := range svc.exportSections() {
+		records += len(section.lines)
+	}
+
+	exported := []types.Progress{}
+	err = svc.ExportWithProgress(context.Background(), dir, func(progress types.Progress) {
+		exported = append(exported, progress)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sections := len(svc.exportSections())
+	if len(exported) != sections || exported[sections-1].Processed != records || exported[sections-1].Total != records {
+		t.Errorf("invalid export progress: %v", exported)
+	}
+
+	imported := []types.Progress{}
+	newSvc := &Service{}
+	err = newSvc.ImportWithProgress(context.Background(), dir, func(progress types.Progress) {
+		imported = append(imported, progress)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(imported) != sections || imported[1].Processed != 4 || imported[1].Total != records {
+		t.Errorf("invalid import progress: %v", imported)
+	}
+
+	if len(newSvc.accounts) != 1 || len(newSvc.payments) != 3 {
+		t.Errorf("invalid import result, accounts %v, payments %v", len(newSvc.accounts), len(newSvc.payments))
+	}
+}
+
+func TestService_ImportWithProgress_canceled(t *testing.T) {
+	dir := t.TempDir()
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account.Balance = 100
+	_, err = svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = (&Service{}).ImportWithProgress(ctx, dir, nil)
+	if err != context.Canceled {
+		t.Errorf("invalid error, got %v, want %v", err, context.Canceled)
+	}
+}
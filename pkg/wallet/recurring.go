@@ -0,0 +1,297 @@
+package wallet
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// RecurringPayment - повторяющийся платёж по избранному.
+type RecurringPayment struct {
+	ID         string
+	FavoriteID string
+	Schedule   Schedule
+	NextRun    time.Time
+	// Retries - сколько повторных попыток уже сделано для текущего запуска
+	Retries int
+}
+
+// ScheduleRecord - повторяющийся платёж в хранилище (см. Store): расписание записано выражением cron.
+type ScheduleRecord struct {
+	ID         string
+	FavoriteID string
+	Cron       string
+	NextRun    time.Time
+	Retries    int
+}
+
+func storedSchedule(recurring *RecurringPayment) ScheduleRecord {
+	return ScheduleRecord{
+		ID:         recurring.ID,
+		FavoriteID: recurring.FavoriteID,
+		Cron:       scheduleExpr(recurring.Schedule),
+		NextRun:    recurring.NextRun,
+		Retries:    recurring.Retries,
+	}
+}
+
+func loadedSchedule(record ScheduleRecord) (*RecurringPayment, error) {
+	schedule, err := ParseCron(record.Cron)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecurringPayment{
+		ID:         record.ID,
+		FavoriteID: record.FavoriteID,
+		Schedule:   schedule,
+		NextRun:    record.NextRun,
+		Retries:    record.Retries,
+	}, nil
+}
+
+// scheduleExpr возвращает выражение cron расписания или пустую строку,
+// если расписание задано не через ParseCron и его нельзя сохранить.
+func scheduleExpr(schedule Schedule) string {
+	cron, ok := schedule.(*CronSchedule)
+	if !ok || cron == nil {
+		return ""
+	}
+
+	return cron.String()
+}
+
+// ScheduleRun - результат одной попытки выполнить повторяющийся платёж.
+type ScheduleRun struct {
+	ScheduleID string
+	FavoriteID string
+	Time       time.Time
+	Attempt    int
+	PaymentID  string
+	Err        error
+}
+
+// RetryPolicy задаёт повторы при нехватке средств: до MaxRetries попыток,
+// задержка перед каждой следующей вдвое больше предыдущей, начиная с Delay.
+type RetryPolicy struct {
+	MaxRetries int
+	Delay      time.Duration
+}
+
+// DefaultRetryPolicy используется, пока не вызван SetRetryPolicy.
+var DefaultRetryPolicy = RetryPolicy{MaxRetries: 3, Delay: 15 * time.Minute}
+
+// SetRetryPolicy задаёт политику повторов для повторяющихся платежей.
+func (s *Service) SetRetryPolicy(policy RetryPolicy) {
+	call := s.beginAudit("SetRetryPolicy", 0, "", "policy", policy)
+	defer call.endUnchecked()
+
+	s.retryPolicy = &policy
+}
+
+func (s *Service) currentRetryPolicy() RetryPolicy {
+	if s.retryPolicy == nil {
+		return DefaultRetryPolicy
+	}
+
+	return *s.retryPolicy
+}
+
+// SchedulePayment создаёт повторяющийся платёж по избранному favoriteID.
+// Расписание сохраняется в дампе и хранилище выражением cron, поэтому оно должно быть
+// получено из Daily, Weekly, Monthly или ParseCron, иначе возвращается ErrInvalidSchedule.
+func (s *Service) SchedulePayment(favoriteID string, schedule Schedule) (recurring *RecurringPayment, err error) {
+	call := s.beginAudit("SchedulePayment", 0, "", "favoriteID", favoriteID)
+	defer func() { err = call.endSchedule(recurring, err) }()
+
+	_, err = s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return nil, err
+	}
+
+	if scheduleExpr(schedule) == "" {
+		return nil, ErrInvalidSchedule
+	}
+
+	nextRun := schedule.Next(s.now())
+	if nextRun.IsZero() {
+		return nil, ErrInvalidSchedule
+	}
+
+	recurring = &RecurringPayment{
+		ID:         s.newID(),
+		FavoriteID: favoriteID,
+		Schedule:   schedule,
+		NextRun:    nextRun,
+	}
+	s.saveSchedules()
+	s.schedules = append(s.schedules, recurring)
+
+	return recurring, nil
+}
+
+// CancelSchedule отменяет повторяющийся платёж. История его запусков сохраняется.
+func (s *Service) CancelSchedule(scheduleID string) (err error) {
+	call := s.beginAudit("CancelSchedule", 0, "", "scheduleID", scheduleID)
+	defer func() { err = call.end("", err) }()
+
+	for i, recurring := range s.schedules {
+		if recurring.ID == scheduleID {
+			s.saveSchedules()
+			s.schedules = append(s.schedules[:i], s.schedules[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrScheduleNotFound
+}
+
+// RunDuePayments выполняет все повторяющиеся платежи, время которых наступило по часам сервиса,
+// и возвращает результаты попыток. Вызывается периодически внешним планировщиком.
+// При нехватке средств попытка повторяется согласно RetryPolicy, после исчерпания повторов
+// платёж переносится на следующий запуск по расписанию. Если избранное удалено, расписание снимается.
+func (s *Service) RunDuePayments() []ScheduleRun {
+	call := s.beginAudit("RunDuePayments", 0, "")
+	defer call.endUnchecked()
+
+	s.saveSchedules()
+	now := s.now()
+	policy := s.currentRetryPolicy()
+	runs := []ScheduleRun{}
+	active := []*RecurringPayment{}
+
+	for _, recurring := range s.schedules {
+		if recurring.NextRun.After(now) {
+			active = append(active, recurring)
+			continue
+		}
+
+		payment, err := s.PayFromFavorite(recurring.FavoriteID)
+		run := ScheduleRun{
+			ScheduleID: recurring.ID,
+			FavoriteID: recurring.FavoriteID,
+			Time:       now,
+			Attempt:    recurring.Retries + 1,
+			Err:        err,
+		}
+		if payment != nil {
+			run.PaymentID = payment.ID
+		}
+		runs = append(runs, run)
+
+		if errors.Is(err, ErrFavoriteNotFound) {
+			continue
+		}
+
+		if errors.Is(err, ErrNotEnoughBalance) && recurring.Retries < policy.MaxRetries {
+			recurring.NextRun = now.Add(policy.Delay << uint(recurring.Retries))
+			recurring.Retries++
+		} else {
+			recurring.Retries = 0
+			recurring.NextRun = recurring.Schedule.Next(now)
+			if recurring.NextRun.IsZero() {
+				continue
+			}
+		}
+
+		active = append(active, recurring)
+	}
+
+	s.schedules = active
+	s.scheduleRuns = append(s.scheduleRuns, runs...)
+
+	return runs
+}
+
+// ScheduleHistory возвращает результаты всех попыток выполнения повторяющегося платежа.
+func (s *Service) ScheduleHistory(scheduleID string) []ScheduleRun {
+	runs := []ScheduleRun{}
+	for _, run := range s.scheduleRuns {
+		if run.ScheduleID == scheduleID {
+			runs = append(runs, run)
+		}
+	}
+
+	return runs
+}
+
+// scheduleFields записывает ID, избранное, время следующего запуска, число повторов и выражение cron.
+func scheduleFields(record ScheduleRecord) []string {
+	return []string{
+		record.ID,
+		record.FavoriteID,
+		encodeTime(record.NextRun),
+		strconv.Itoa(record.Retries),
+		record.Cron,
+	}
+}
+
+func encodeSchedule(recurring *RecurringPayment) string {
+	return strings.Join(scheduleFields(storedSchedule(recurring)), ";")
+}
+
+func decodeScheduleFields(data []string) (ScheduleRecord, error) {
+	if len(data) < 5 {
+		return ScheduleRecord{}, ErrInvalidDump
+	}
+
+	nextRun, err := decodeTime(data[2])
+	if err != nil {
+		return ScheduleRecord{}, err
+	}
+
+	retries, err := strconv.Atoi(data[3])
+	if err != nil {
+		return ScheduleRecord{}, err
+	}
+
+	return ScheduleRecord{
+		ID:         data[0],
+		FavoriteID: data[1],
+		NextRun:    nextRun,
+		Retries:    retries,
+		Cron:       data[4],
+	}, nil
+}
+
+func (s *Service) schedulesSection() dumpSection {
+	section := dumpSection{name: "schedules.dump"}
+	for _, recurring := range s.schedules {
+		section.lines = append(section.lines, encodeSchedule(recurring))
+	}
+
+	return section
+}
+
+// importSchedule добавляет или обновляет повторяющийся платёж. История запусков не импортируется.
+func (s *Service) importSchedule(line string) error {
+	record, err := decodeScheduleFields(strings.Split(line, ";"))
+	if err != nil {
+		return err
+	}
+
+	recurring, err := loadedSchedule(record)
+	if err != nil {
+		return err
+	}
+
+	s.upsertSchedule(recurring)
+	return nil
+}
+
+func (s *Service) upsertSchedule(recurring *RecurringPayment) {
+	s.observeID(recurring.ID)
+	s.saveSchedules()
+
+	for _, scheduleCheck := range s.schedules {
+		if scheduleCheck.ID == recurring.ID {
+			*scheduleCheck = *recurring
+			return
+		}
+	}
+
+	s.schedules = append(s.schedules, recurring)
+}
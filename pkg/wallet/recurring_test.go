@@ -0,0 +1,281 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+func TestParseCron_invalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := ParseCron(expr)
+		if err != ErrInvalidSchedule {
+			t.Errorf("%q: invalid error, got %v, want %v", expr, err, ErrInvalidSchedule)
+		}
+	}
+}
+
+func mustSchedule(schedule Schedule, err error) Schedule {
+	if err != nil {
+		panic(err)
+	}
+
+	return schedule
+}
+
+func TestSchedule_invalid(t *testing.T) {
+	for name, build := range map[string]func() (Schedule, error){
+		"daily hour":     func() (Schedule, error) { return Daily(25, 0) },
+		"daily minute":   func() (Schedule, error) { return Daily(9, -1) },
+		"weekly weekday": func() (Schedule, error) { return Weekly(time.Weekday(9), 9, 0) },
+		"monthly day":    func() (Schedule, error) { return Monthly(32, 9, 0) },
+	} {
+		schedule, err := build()
+		if err != ErrInvalidSchedule || schedule != nil {
+			t.Errorf("%v: invalid result, got %v, %v, want nil, %v", name, schedule, err, ErrInvalidSchedule)
+		}
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	// 2021-03-15 - понедельник
+	from := time.Date(2021, 3, 15, 10, 30, 0, 0, time.UTC)
+
+	workdays, err := ParseCron("0 9 * * 1-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	everyQuarter, err := ParseCron("*/15 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fromFive, err := ParseCron("5/15 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		want     time.Time
+	}{
+		{"daily later today", mustSchedule(Daily(12, 0)), time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)},
+		{"daily tomorrow", mustSchedule(Daily(9, 0)), time.Date(2021, 3, 16, 9, 0, 0, 0, time.UTC)},
+		{"weekly", mustSchedule(Weekly(time.Sunday, 8, 0)), time.Date(2021, 3, 21, 8, 0, 0, 0, time.UTC)},
+		{"monthly", mustSchedule(Monthly(1, 0, 0)), time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"monthly skips short months", mustSchedule(Monthly(31, 0, 0)), time.Date(2021, 3, 31, 0, 0, 0, 0, time.UTC)},
+		{"cron workdays", workdays, time.Date(2021, 3, 16, 9, 0, 0, 0, time.UTC)},
+		{"cron step", everyQuarter, time.Date(2021, 3, 15, 10, 45, 0, 0, time.UTC)},
+		{"cron step from value", fromFive, time.Date(2021, 3, 15, 10, 35, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		got := test.schedule.Next(from)
+		if !got.Equal(test.want) {
+			t.Errorf("%v: invalid next, got %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	got := mustSchedule(Monthly(31, 0, 0)).Next(time.Date(2021, 3, 31, 0, 0, 0, 0, time.UTC))
+	want := time.Date(2021, 5, 31, 0, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("invalid next after 31st, got %v, want %v", got, want)
+	}
+}
+
+func newScheduledService(t *testing.T) (*Service, *types.Account, *types.Favorite, *time.Time) {
+	svc, account, now := newTestService(t, 100)
+	payment := mustPay(t, svc, account.ID, 100, "internet")
+
+	favorite, err := svc.FavoritePayment(payment.ID, "internet")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return svc, account, favorite, now
+}
+
+func TestService_RunDuePayments(t *testing.T) {
+	svc, account, favorite, now := newScheduledService(t)
+
+	recurring, err := svc.SchedulePayment(favorite.ID, mustSchedule(Daily(12, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runs := svc.RunDuePayments()
+	if len(runs) != 0 {
+		t.Fatalf("payment is not due yet, got runs %v", runs)
+	}
+
+	err = svc.Deposit(account.ID, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*now = time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)
+	runs = svc.RunDuePayments()
+	if len(runs) != 1 || runs[0].Err != nil || runs[0].PaymentID == "" {
+		t.Fatalf("invalid runs: %v", runs)
+	}
+
+	if account.Balance != 100 {
+		t.Errorf("invalid balance, got %v, want %v", account.Balance, 100)
+	}
+
+	want := time.Date(2021, 3, 16, 12, 0, 0, 0, time.UTC)
+	if !recurring.NextRun.Equal(want) {
+		t.Errorf("invalid next run, got %v, want %v", recurring.NextRun, want)
+	}
+}
+
+func TestService_RunDuePayments_retryWithBackoff(t *testing.T) {
+	svc, account, favorite, now := newScheduledService(t)
+	svc.SetRetryPolicy(RetryPolicy{MaxRetries: 2, Delay: 10 * time.Minute})
+
+	recurring, err := svc.SchedulePayment(favorite.ID, mustSchedule(Daily(12, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*now = time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)
+	svc.RunDuePayments()
+	if !recurring.NextRun.Equal(now.Add(10 * time.Minute)) {
+		t.Fatalf("invalid first retry, got %v", recurring.NextRun)
+	}
+
+	*now = recurring.NextRun
+	svc.RunDuePayments()
+	if !recurring.NextRun.Equal(now.Add(20 * time.Minute)) {
+		t.Fatalf("invalid second retry, got %v", recurring.NextRun)
+	}
+
+	*now = recurring.NextRun
+	svc.RunDuePayments()
+	want := time.Date(2021, 3, 16, 12, 0, 0, 0, time.UTC)
+	if !recurring.NextRun.Equal(want) {
+		t.Fatalf("retries exhausted, next run must be next occurrence, got %v", recurring.NextRun)
+	}
+
+	err = svc.Deposit(account.ID, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*now = want
+	svc.RunDuePayments()
+
+	history := svc.ScheduleHistory(recurring.ID)
+	if len(history) != 4 {
+		t.Fatalf("invalid history length, got %v, want %v", len(history), 4)
+	}
+
+	for i, attempt := range []int{1, 2, 3, 1} {
+		if history[i].Attempt != attempt {
+			t.Errorf("run %v: invalid attempt, got %v, want %v", i, history[i].Attempt, attempt)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		if history[i].Err != ErrNotEnoughBalance {
+			t.Errorf("run %v: invalid error, got %v, want %v", i, history[i].Err, ErrNotEnoughBalance)
+		}
+	}
+
+	if history[3].Err != nil || history[3].PaymentID == "" {
+		t.Errorf("last run must succeed: %v", history[3])
+	}
+}
+
+func TestService_CancelSchedule(t *testing.T) {
+	svc, _, favorite, now := newScheduledService(t)
+
+	recurring, err := svc.SchedulePayment(favorite.ID, mustSchedule(Daily(12, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.CancelSchedule(recurring.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.CancelSchedule(recurring.ID)
+	if err != ErrScheduleNotFound {
+		t.Errorf("invalid error, got %v, want %v", err, ErrScheduleNotFound)
+	}
+
+	*now = now.Add(48 * time.Hour)
+	runs := svc.RunDuePayments()
+	if len(runs) != 0 {
+		t.Errorf("canceled schedule must not run, got %v", runs)
+	}
+}
+
+func TestService_SchedulePayment_favoriteNotFound(t *testing.T) {
+	svc := &Service{}
+
+	_, err := svc.SchedulePayment("1", mustSchedule(Daily(12, 0)))
+	if err != ErrFavoriteNotFound {
+		t.Errorf("invalid error, got %v, want %v", err, ErrFavoriteNotFound)
+	}
+}
+
+// hourlySchedule - расписание, заданное не через ParseCron.
+type hourlySchedule struct{}
+
+func (hourlySchedule) Next(after time.Time) time.Time {
+	return after.Truncate(time.Hour).Add(time.Hour)
+}
+
+func TestService_SchedulePayment_notCron(t *testing.T) {
+	svc, _, favorite, _ := newScheduledService(t)
+
+	_, err := svc.SchedulePayment(favorite.ID, hourlySchedule{})
+	if err != ErrInvalidSchedule {
+		t.Errorf("invalid error, got %v, want %v", err, ErrInvalidSchedule)
+	}
+}
+
+func TestService_Export_schedules(t *testing.T) {
+	svc, _, favorite, now := newScheduledService(t)
+
+	recurring, err := svc.SchedulePayment(favorite.ID, mustSchedule(Daily(12, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*now = time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)
+	svc.RunDuePayments()
+	if recurring.Retries != 1 {
+		t.Fatalf("invalid retries, got %v, want %v", recurring.Retries, 1)
+	}
+
+	dir := t.TempDir()
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported := &Service{}
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(imported.schedules) != 1 {
+		t.Fatalf("invalid schedules count, got %v, want %v", len(imported.schedules), 1)
+	}
+
+	got := imported.schedules[0]
+	if got.ID != recurring.ID || got.FavoriteID != favorite.ID || !got.NextRun.Equal(recurring.NextRun) || got.Retries != 1 {
+		t.Errorf("invalid schedule, got %+v, want %+v", got, recurring)
+	}
+
+	if next := got.Schedule.Next(*now); !next.Equal(time.Date(2021, 3, 16, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("invalid imported schedule, next run %v", next)
+	}
+}
@@ -0,0 +1,181 @@
+package wallet
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// Schedule определяет, когда должен выполняться повторяющийся платёж.
+type Schedule interface {
+	// Next возвращает ближайший момент выполнения строго после after
+	// или нулевое время, если такого момента нет.
+	Next(after time.Time) time.Time
+}
+
+// CronSchedule - расписание в формате cron: минута, час, день месяца, месяц, день недели.
+type CronSchedule struct {
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	// если ограничены и день месяца, и день недели, достаточно совпадения одного из них (как в cron)
+	anyDay bool
+	// expr - исходное выражение, по нему расписание сохраняется в дампе и хранилище
+	expr string
+}
+
+// Daily выполняется каждый день в hour:minute. Для времени вне суток возвращает ErrInvalidSchedule.
+func Daily(hour, minute int) (Schedule, error) {
+	return parseSchedule(strconv.Itoa(minute) + " " + strconv.Itoa(hour) + " * * *")
+}
+
+// Weekly выполняется каждую неделю в день weekday в hour:minute.
+func Weekly(weekday time.Weekday, hour, minute int) (Schedule, error) {
+	return parseSchedule(strconv.Itoa(minute) + " " + strconv.Itoa(hour) + " * * " + strconv.Itoa(int(weekday)))
+}
+
+// Monthly выполняется каждый месяц в день day в hour:minute.
+// В месяцах, где нет такого дня, платёж пропускается, поэтому лучше выбирать day не больше 28.
+func Monthly(day, hour, minute int) (Schedule, error) {
+	return parseSchedule(strconv.Itoa(minute) + " " + strconv.Itoa(hour) + " " + strconv.Itoa(day) + " * *")
+}
+
+// parseSchedule - ParseCron, возвращающий nil-интерфейс при ошибке.
+func parseSchedule(expr string) (Schedule, error) {
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// ParseCron разбирает выражение из пяти полей cron ("30 9 * * 1-5").
+// Поля поддерживают *, числа, диапазоны a-b, списки через запятую и шаг /n
+// (*/n, a-b/n и a/n - с a до конца диапазона поля).
+func ParseCron(expr string) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, ErrInvalidSchedule
+	}
+
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	masks := [5]uint64{}
+	for i, field := range fields {
+		mask, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, err
+		}
+		masks[i] = mask
+	}
+
+	// 7 - тоже воскресенье
+	if masks[4]&(1<<7) != 0 {
+		masks[4] |= 1
+	}
+
+	return &CronSchedule{
+		minutes:  masks[0],
+		hours:    masks[1],
+		days:     masks[2],
+		months:   masks[3],
+		weekdays: masks[4],
+		anyDay:   fields[2] != "*" && fields[4] != "*",
+		expr:     strings.Join(fields, " "),
+	}, nil
+}
+
+// String возвращает выражение cron, из которого разобрано расписание.
+func (c *CronSchedule) String() string {
+	return c.expr
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	mask := uint64(0)
+	for _, item := range strings.Split(field, ",") {
+		step := 1
+		stepped := false
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step <= 0 {
+				return 0, ErrInvalidSchedule
+			}
+			item = item[:i]
+			stepped = true
+		}
+
+		from, to := min, max
+		if item != "*" {
+			var err error
+			if i := strings.Index(item, "-"); i >= 0 {
+				from, err = strconv.Atoi(item[:i])
+				if err != nil {
+					return 0, ErrInvalidSchedule
+				}
+				to, err = strconv.Atoi(item[i+1:])
+			} else {
+				from, err = strconv.Atoi(item)
+				// "a/n" - с a до конца диапазона поля, просто "a" - одно значение
+				if !stepped {
+					to = from
+				}
+			}
+			if err != nil || from < min || to > max || from > to {
+				return 0, ErrInvalidSchedule
+			}
+		}
+
+		for value := from; value <= to; value += step {
+			mask |= 1 << uint(value)
+		}
+	}
+
+	return mask, nil
+}
+
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+	day := c.days&(1<<uint(t.Day())) != 0
+	weekday := c.weekdays&(1<<uint(t.Weekday())) != 0
+	if c.anyDay {
+		return day || weekday
+	}
+
+	return day && weekday
+}
+
+// Next возвращает ближайшую минуту после after, подходящую под расписание.
+// Поиск ограничен пятью годами.
+func (c *CronSchedule) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := time.Date(after.Year(), after.Month(), after.Day(), after.Hour(), after.Minute(), 0, 0, loc).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if c.hours&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if c.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
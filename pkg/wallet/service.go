@@ -1,16 +1,14 @@
 package wallet
 
 import (
+	"context"
 	"errors"
 	"io/ioutil"
 	"log"
 	"os"
 	"strconv"
 	"strings"
-	"sync"
-	"io"
-	"math"
-	"github.com/google/uuid"
+	"time"
 
 	"github.com/Ulugbek999/wallet/pkg/types"
 )
@@ -28,37 +26,117 @@ var (
 	ErrNotEnoughBalance     = errors.New("not enough balance")
 	ErrPaymentNotFound      = errors.New("payment not found")
 	ErrFavoriteNotFound     = errors.New("favorite not found")
+	ErrFavoriteNameTaken    = errors.New("favorite name already used")
 	ErrFileNotFound         = errors.New("file not found")
+	ErrAccountFrozen        = errors.New("account frozen")
+	ErrAccountClosed        = errors.New("account closed")
+	ErrBalanceNotZero       = errors.New("balance is not zero")
+	ErrInvalidDump          = errors.New("invalid dump line")
+	ErrInvalidText          = errors.New("text must not contain ';' or line breaks")
 )
 
+// checkText проверяет имена и категории, приходящие от клиента: в них не должно быть
+// разделителей формата дампа, как и в ключах идемпотентности.
+func checkText(values ...string) error {
+	for _, value := range values {
+		if strings.ContainsAny(value, ";\r\n") {
+			return ErrInvalidText
+		}
+	}
+
+	return nil
+}
+
 type Service struct {
 	nextAccountID int64
 	accounts      []*types.Account
 	payments      []*types.Payment
 	favorites     []*types.Favorite
+
+	clock          func() time.Time
+	accountLimits  map[int64]Limit
+	categoryLimits map[types.PaymentCategory]Limit
+
+	overdraftAccruals []OverdraftAccrualFunc
+
+	defaultCountryCode string
+	phoneChanges       []PhoneChange
+	// phoneIndex - аккаунты по нормализованному номеру телефона
+	phoneIndex map[types.Phone]*types.Account
+
+	idempotency    map[string]*idempotencyRecord
+	idempotencyTTL time.Duration
+
+	holds   []*types.Hold
+	holdTTL time.Duration
+
+	houseAccountID int64
+	feeRules       []FeeRule
+
+	idGenerator IDGenerator
+
+	store   Store
+	changes *changeLog
+
+	riskRules []RiskRule
+	// repeatOf - ID платежа, который сейчас повторяется через Repeat (для правил антифрода)
+	repeatOf string
+
+	subscribers      []*subscriber
+	nextSubscriberID int
+	eventSeq         uint64
+	outbox           []*OutboxEntry
+	// pendingEvents - события текущего вызова, доставляемые подписчикам после его завершения
+	pendingEvents []Event
+
+	actor      string
+	auditDepth int
+	auditLog   []*AuditRecord
+	// auditStored - сколько первых записей журнала аудита уже в хранилище
+	auditStored int
+
+	schedules    []*RecurringPayment
+	scheduleRuns []ScheduleRun
+	retryPolicy  *RetryPolicy
 }
 
-func (s *Service) RegisterAccount(phone types.Phone) (*types.Account, error) {
-	for _, account := range s.accounts {
-		if account.Phone == phone {
-			return nil, ErrPhoneNumberRegistred
-		}
+func (s *Service) RegisterAccount(phone types.Phone) (account *types.Account, err error) {
+	call := s.beginAudit("RegisterAccount", 0, "", "phone", phone)
+	defer func() { err = call.endAccount(account, err) }()
+
+	phone, err = s.normalizePhone(phone)
+	if err != nil {
+		return nil, err
 	}
 
-	s.nextAccountID++
+	if s.findAccountByNormalizedPhone(phone) != nil {
+		return nil, ErrPhoneNumberRegistred
+	}
 
-	account := &types.Account{
-		ID:      s.nextAccountID,
-		Phone:   phone,
-		Balance: 0,
+	accountID, err := s.newAccountID()
+	if err != nil {
+		return nil, err
+	}
+	s.observeAccountID(accountID)
+
+	account = &types.Account{
+		ID:        accountID,
+		Phone:     phone,
+		Balance:   0,
+		Status:    types.AccountStatusActive,
+		CreatedAt: s.now(),
 	}
 
-	s.accounts = append(s.accounts, account)
+	s.addAccount(account)
+	s.publish(Event{Type: EventAccountRegistered, AccountID: account.ID, Phone: account.Phone})
 
 	return account, nil
 }
 
-func (s *Service) Deposit(accountID int64, amount types.Money) error {
+func (s *Service) Deposit(accountID int64, amount types.Money) (err error) {
+	call := s.beginAudit("Deposit", accountID, "", "amount", amount)
+	defer func() { err = call.end("", err) }()
+
 	if amount <= 0 {
 		return ErrAmountMustBePositive
 	}
@@ -68,36 +146,95 @@ func (s *Service) Deposit(accountID int64, amount types.Money) error {
 		return err
 	}
 
+	err = checkAccountActive(account)
+	if err != nil {
+		return err
+	}
+
+	s.touchAccount(account)
 	account.Balance += amount
+	s.publish(Event{Type: EventDeposited, AccountID: account.ID, Amount: amount})
 	return nil
 }
 
-func (s *Service) Pay(accountID int64, amount types.Money, category types.PaymentCategory) (*types.Payment, error) {
+func (s *Service) Pay(accountID int64, amount types.Money, category types.PaymentCategory) (payment *types.Payment, err error) {
+	call := s.beginAudit("Pay", accountID, "", "amount", amount, "category", category)
+	defer func() { err = call.endPayment(payment, err) }()
+
 	if amount <= 0 {
 		return nil, ErrAmountMustBePositive
 	}
 
+	err = checkText(string(category))
+	if err != nil {
+		return nil, err
+	}
+
 	account, err := s.FindAccountByID(accountID)
 	if err != nil {
 		return nil, err
 	}
 
-	if account.Balance < amount {
+	err = checkAccountActive(account)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkLimits(accountID, amount, category)
+	if err != nil {
+		return nil, err
+	}
+
+	s.ExpireHolds()
+
+	checks, decision := s.assessRisk(account, amount, category)
+	if decision == types.RiskBlock {
+		return nil, &RiskError{Checks: checks}
+	}
+
+	payment, err = s.debit(account, amount, category)
+	if err != nil {
+		return nil, err
+	}
+
+	s.applyRisk(payment, checks, decision)
+
+	return payment, nil
+}
+
+// debit списывает amount с комиссией с доступных средств аккаунта и создаёт платёж.
+func (s *Service) debit(account *types.Account, amount types.Money, category types.PaymentCategory) (*types.Payment, error) {
+	fee := s.calculateFee(account, amount, category)
+
+	if account.Balance+account.Overdraft-account.Held < amount+fee {
 		return nil, ErrNotEnoughBalance
 
 	}
-	account.Balance -= amount
+	s.touchAccount(account)
+	account.Balance -= amount + fee
 
-	paymentID := uuid.New().String()
+	err := s.creditFee(fee)
+	if err != nil {
+		account.Balance += amount + fee
+		return nil, err
+	}
+
+	paymentID := s.newID()
 	payment := &types.Payment{
 		ID:        paymentID,
-		AccountID: accountID,
+		AccountID: account.ID,
 		Amount:    amount,
 		Category:  category,
 		Status:    types.PaymentStatusInProgress,
+		CreatedAt: s.now(),
+		Fee:       fee,
+	}
+	if fee > 0 {
+		payment.FeeAccountID = s.houseAccountID
 	}
 
-	s.payments = append(s.payments, payment)
+	s.addPayment(payment)
+	s.publish(paymentEvent(EventPaymentCreated, payment))
 	return payment, nil
 
 }
@@ -122,14 +259,35 @@ func (s *Service) FindPaymentByID(paymentID string) (*types.Payment, error) {
 	return nil, ErrPaymentNotFound
 }
 
-func (s *Service) Reject(paymentID string) error {
+func (s *Service) Reject(paymentID string) (err error) {
+	call := s.beginAudit("Reject", 0, paymentID)
+	defer func() { err = call.end("", err) }()
+
 	targetPayment, targetAccount, err := s.findPaymentAndAccountByPaymentID(paymentID)
 	if err != nil {
 		return err
 	}
 
+	// платёж уже отклонён: сумма и комиссия возвращены, повторный отказ ничего не делает
+	if targetPayment.Status == types.PaymentStatusFail {
+		return nil
+	}
+
+	err = checkAccountActive(targetAccount)
+	if err != nil {
+		return err
+	}
+
+	err = s.refundFee(targetAccount, targetPayment, targetPayment.Amount)
+	if err != nil {
+		return err
+	}
+
+	s.touchPayment(targetPayment)
+	s.touchAccount(targetAccount)
 	targetPayment.Status = types.PaymentStatusFail
 	targetAccount.Balance += targetPayment.Amount
+	s.publish(paymentEvent(EventPaymentRejected, targetPayment))
 
 	return nil
 }
@@ -148,41 +306,64 @@ func (s *Service) findPaymentAndAccountByPaymentID(paymentID string) (*types.Pay
 	return payment, account, nil
 }
 
-func (s *Service) Repeat(paymentID string) (*types.Payment, error) {
+func (s *Service) Repeat(paymentID string) (payment *types.Payment, err error) {
+	call := s.beginAudit("Repeat", 0, "", "paymentID", paymentID)
+	defer func() { err = call.endPayment(payment, err) }()
+
 	targetPayment, targetAccount, err := s.findPaymentAndAccountByPaymentID(paymentID)
 	if err != nil {
 		return nil, err
 	}
 
+	s.repeatOf = paymentID
+	defer func() { s.repeatOf = "" }()
+
 	return s.Pay(targetAccount.ID, targetPayment.Amount, targetPayment.Category)
 }
 
-func (s *Service) FavoritePayment(paymentID string, name string) (*types.Favorite, error) {
+func (s *Service) FavoritePayment(paymentID string, name string) (favorite *types.Favorite, err error) {
+	call := s.beginAudit("FavoritePayment", 0, paymentID, "name", name)
+	defer func() { err = call.endFavorite(favorite, err) }()
+
+	err = checkText(name)
+	if err != nil {
+		return nil, err
+	}
+
 	targetPayment, targetAccount, err := s.findPaymentAndAccountByPaymentID(paymentID)
 	if err != nil {
 		return nil, err
 	}
 
-	favorite := &types.Favorite{
-		ID:        uuid.New().String(),
+	err = s.checkFavoriteName(targetAccount.ID, name, "")
+	if err != nil {
+		return nil, err
+	}
+
+	favorite = &types.Favorite{
+		ID:        s.newID(),
 		AccountID: targetAccount.ID,
 		Name:      name,
 		Amount:    targetPayment.Amount,
 		Category:  targetPayment.Category,
 	}
 
-	s.favorites = append(s.favorites, favorite)
+	s.addFavorite(favorite)
+	s.publish(favoriteEvent(EventFavoriteCreated, favorite))
 
 	return favorite, nil
 }
 
-func (s *Service) PayFromFavorite(favoriteID string) (*types.Payment, error) {
+func (s *Service) PayFromFavorite(favoriteID string) (payment *types.Payment, err error) {
+	call := s.beginAudit("PayFromFavorite", 0, "", "favoriteID", favoriteID)
+	defer func() { err = call.endPayment(payment, err) }()
+
 	favorite, err := s.FindFavoriteByID(favoriteID)
 	if err != nil {
 		return nil, err
 	}
 
-	payment, err := s.Pay(favorite.AccountID, favorite.Amount, favorite.Category)
+	payment, err = s.Pay(favorite.AccountID, favorite.Amount, favorite.Category)
 	if err != nil {
 		return nil, err
 	}
@@ -190,7 +371,10 @@ func (s *Service) PayFromFavorite(favoriteID string) (*types.Payment, error) {
 	return payment, nil
 }
 
-func (s *Service) ExportToFile(path string) error {
+func (s *Service) ExportToFile(path string) (err error) {
+	call := s.beginAudit("ExportToFile", 0, "", "path", path)
+	defer func() { err = call.end("", err) }()
+
 	result := ""
 	for _, account := range s.accounts {
 		result += strconv.Itoa(int(account.ID)) + ";"
@@ -198,7 +382,7 @@ func (s *Service) ExportToFile(path string) error {
 		result += strconv.Itoa(int(account.Balance)) + "|"
 	}
 
-	err := actionByFile(path, result)
+	err = actionByFile(path, result)
 	if err != nil {
 		return err
 	}
@@ -206,7 +390,10 @@ func (s *Service) ExportToFile(path string) error {
 	return nil
 }
 
-func (s *Service) ImportFromFile(path string) error {
+func (s *Service) ImportFromFile(path string) (err error) {
+	call := s.beginAudit("ImportFromFile", 0, "", "path", path)
+	defer func() { err = call.end("", err) }()
+
 	byteData, err := ioutil.ReadFile(path)
 	if err != nil {
 		log.Println(err)
@@ -232,13 +419,25 @@ func (s *Service) ImportFromFile(path string) error {
 				return err
 			}
 
+			phone, err := s.normalizePhone(types.Phone(datas[1]))
+			if err != nil {
+				log.Println(err)
+				return err
+			}
+
+			if s.findAccountByNormalizedPhone(phone) != nil {
+				log.Println(ErrPhoneNumberRegistred)
+				return ErrPhoneNumberRegistred
+			}
+
 			newAccount := &types.Account{
 				ID:      int64(id),
-				Phone:   types.Phone(datas[1]),
+				Phone:   phone,
 				Balance: types.Money(balance),
 			}
 
-			s.accounts = append(s.accounts, newAccount)
+			s.addAccount(newAccount)
+			s.observeAccountID(newAccount.ID)
 		}
 	}
 
@@ -356,7 +555,7 @@ func (s *Service) actionByAccounts(path string) error {
 
 				acc.Balance = types.Money(balance)
 			} else {
-				account.Phone = phone
+				s.setPhone(account, phone)
 				account.Balance = types.Money(balance)
 			}
 		}
@@ -452,6 +651,12 @@ func (s *Service) actionByFavorites(path string) error {
 
 			category := types.PaymentCategory(data[4])
 
+			err = s.checkFavoriteName(int64(accountID), name, id)
+			if err != nil {
+				log.Println(err)
+				return err
+			}
+
 			favorite, err := s.FindFavoriteByID(id)
 			if err != nil {
 				newFavorite := &types.Favorite{
@@ -587,683 +792,557 @@ func (s *Service) HistoryToFiles(payments []types.Payment, dir string, records i
 }
 
 func (s *Service) SumPayments(goroutines int) types.Money {
-	wg := sync.WaitGroup{}
-	mu := sync.Mutex{}
-	var summ types.Money = 0
-	if goroutines == 0 || goroutines == 1 {
-		wg.Add(1)
-		go func(payments []*types.Payment) {
-			defer wg.Done()
-			for _, payment := range payments {
-				summ += payment.Amount
-			}
-		}(s.payments)
-	} else {
-		from := 0
-		count := len(s.payments) / goroutines
-		for i := 1; i <= goroutines; i++ {
-			wg.Add(1)
-			last := len(s.payments) - i*count
-			if i == goroutines {
-				last = 0
-			}
-			to := len(s.payments) - last
-			go func(payments []*types.Payment) {
-				defer wg.Done()
-				s := types.Money(0)
-				for _, payment := range payments {
-					s += payment.Amount
-				}
-				mu.Lock()
-				defer mu.Unlock()
-				summ += s
-			}(s.payments[from:to])
-			from += count
-		}
+	summ, err := s.SumPaymentsContext(context.Background(), goroutines)
+	if err != nil {
+		log.Print(err)
 	}
 
-	wg.Wait()
-
 	return summ
 }
 
-func (s *Service) FilterPayments(accountID int64, goroutines int) ([]types.Payment, error) {
-	filteredPayments := []types.Payment{}
-	wg := sync.WaitGroup{}
-	mu := sync.Mutex{}
-	if goroutines == 0 || goroutines == 1 {
-		wg.Add(1)
-		go func(payments []*types.Payment) {
-			defer wg.Done()
-			for _, payment := range payments {
-				if payment.AccountID == accountID {
-					filteredPayments = append(filteredPayments, types.Payment{
-						ID:        payment.ID,
-						AccountID: payment.AccountID,
-						Amount:    payment.Amount,
-						Category:  payment.Category,
-						Status:    payment.Status,
-					})
-				}
-			}
-		}(s.payments)
-	} else {
-		from := 0
-		count := len(s.payments) / goroutines
-		for i := 1; i <= goroutines; i++ {
-			wg.Add(1)
-			last := len(s.payments) - i*count
-			if i == goroutines {
-				last = 0
-			}
-			to := len(s.payments) - last
-			go func(payments []*types.Payment) {
-				defer wg.Done()
-				separetePayments := []types.Payment{}
-				for _, payment := range payments {
-					if payment.AccountID == accountID {
-						separetePayments = append(separetePayments, types.Payment{
-							ID:        payment.ID,
-							AccountID: payment.AccountID,
-							Amount:    payment.Amount,
-							Category:  payment.Category,
-							Status:    payment.Status,
-						})
-					}
-				}
-				mu.Lock()
-				defer mu.Unlock()
-				filteredPayments = append(filteredPayments, separetePayments...)
-			}(s.payments[from:to])
-			from += count
-		}
-	}
+// SumPaymentsContext суммирует платежи в goroutines горутинах с поддержкой отмены через ctx.
+func (s *Service) SumPaymentsContext(ctx context.Context, goroutines int) (types.Money, error) {
+	return s.SumPaymentsWithProgressFunc(ctx, goroutines, nil)
+}
+
+// SumPaymentsWithProgressFunc суммирует платежи, сообщая fn о каждой посчитанной части.
+func (s *Service) SumPaymentsWithProgressFunc(ctx context.Context, goroutines int, fn ProgressFunc) (types.Money, error) {
+	return sumPaymentsWithProgress(ctx, s.payments, goroutines, fn)
+}
 
-	wg.Wait()
+func (s *Service) FilterPayments(accountID int64, goroutines int) ([]types.Payment, error) {
+	return s.FilterPaymentsContext(context.Background(), accountID, goroutines)
+}
 
-	if len(filteredPayments) == 0 {
-		return nil, ErrAccountNotFound
+// FilterPaymentsContext возвращает платежи аккаунта, отбирая их в goroutines горутинах.
+func (s *Service) FilterPaymentsContext(ctx context.Context, accountID int64, goroutines int) ([]types.Payment, error) {
+	filter := func(payment types.Payment) bool {
+		return payment.AccountID == accountID
 	}
 
-	return filteredPayments, nil
+	return s.FilterPaymentsByFnContext(ctx, filter, goroutines)
 }
 
 
-
 // homework 17
 
-func (s *Service) Export(dir string) error {
+// dumpSection - содержимое одного файла экспорта (accounts.dump, payments.dump, favorites.dump, ...).
+type dumpSection struct {
+	name  string
+	lines []string
+}
 
-	// dir, err := filepath.Abs(dir)
+func accountFields(account *types.Account) []string {
+	return []string{
+		strconv.FormatInt(account.ID, 10),
+		string(account.Phone),
+		strconv.FormatInt(int64(account.Balance), 10),
+		strconv.FormatInt(int64(account.Overdraft), 10),
+		string(account.Tier),
+		string(account.Status),
+		strconv.FormatInt(int64(account.Held), 10),
+		encodeTime(account.CreatedAt),
+	}
+}
 
-	// if err != nil {
-	// 	log.Print(err)
+func encodeAccount(account *types.Account) string {
+	return strings.Join(accountFields(account), ";")
+}
 
-	// }
-	var err = errors.New("Error")
-	err = nil
-	lenAcou := len(s.accounts)
+func paymentFields(payment *types.Payment) []string {
+	return []string{
+		payment.ID,
+		strconv.FormatInt(payment.AccountID, 10),
+		strconv.FormatInt(int64(payment.Amount), 10),
+		string(payment.Category),
+		string(payment.Status),
+		encodeTime(payment.CreatedAt),
+		strconv.FormatInt(int64(payment.Fee), 10),
+	}
+}
 
-	if lenAcou != 0 {
+// encodePayment - строка payments.dump: поля платежа, пустое место проверок антифрода
+// (Export пишет их в risk.dump, снимки LogStore - в это поле) и аккаунт комиссии.
+func encodePayment(payment *types.Payment) string {
+	return strings.Join(append(paymentFields(payment), "", strconv.FormatInt(payment.FeeAccountID, 10)), ";")
+}
 
-		dirAccount := dir + "/accounts.dump"
-		//	log.Print(dirAccount)
+// encodeTime записывает время в секундах Unix, нулевое время - как 0.
+func encodeTime(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
 
-		fileAccounts, err := os.Create(dirAccount)
-		if err != nil {
-			log.Print(err)
+	return strconv.FormatInt(t.Unix(), 10)
+}
 
-		}
+func decodeTime(data string) (time.Time, error) {
+	seconds, err := strconv.ParseInt(data, 10, 64)
+	if err != nil || seconds == 0 {
+		return time.Time{}, err
+	}
 
-		defer func() {
-			if cerr := fileAccounts.Close(); err != nil {
-				log.Print(cerr)
-			}
-		}()
-
-		for index, account := range s.accounts {
-			//	account, err = s.FindAccountByID(int64(ind))
-			// fmt.Println(newP2)
-			// fmt.Println(ee3)
-			if index != 0 {
-				_, err = fileAccounts.Write([]byte("\n"))
-				if err != nil {
-					log.Print(err)
+	return time.Unix(seconds, 0), nil
+}
 
-				}
+func favoriteFields(favorite *types.Favorite) []string {
+	return []string{
+		favorite.ID,
+		strconv.FormatInt(favorite.AccountID, 10),
+		favorite.Name,
+		strconv.FormatInt(int64(favorite.Amount), 10),
+		string(favorite.Category),
+	}
+}
 
-			}
-			_, err = fileAccounts.Write([]byte(strconv.FormatInt((account.ID), 10)))
-			if err != nil {
-				log.Print(err)
+func encodeFavorite(favorite *types.Favorite) string {
+	return strings.Join(favoriteFields(favorite), ";")
+}
 
-			}
+func (s *Service) exportSections() []dumpSection {
+	accounts := dumpSection{name: "accounts.dump"}
+	for _, account := range s.accounts {
+		accounts.lines = append(accounts.lines, encodeAccount(account))
+	}
 
-			_, err = fileAccounts.Write([]byte(";"))
-			if err != nil {
-				log.Print(err)
+	payments := dumpSection{name: "payments.dump"}
+	for _, payment := range s.payments {
+		payments.lines = append(payments.lines, encodePayment(payment))
+	}
 
-			}
-			_, err = fileAccounts.Write([]byte(string(account.Phone)))
-			if err != nil {
-				log.Print(err)
+	favorites := dumpSection{name: "favorites.dump"}
+	for _, favorite := range s.favorites {
+		favorites.lines = append(favorites.lines, encodeFavorite(favorite))
+	}
 
-			}
+	holds := dumpSection{name: "holds.dump"}
+	for _, hold := range s.holds {
+		holds.lines = append(holds.lines, encodeHold(hold))
+	}
 
-			_, err = fileAccounts.Write([]byte(";"))
-			if err != nil {
-				log.Print(err)
+	return []dumpSection{accounts, payments, favorites, s.idempotencySection(), holds, s.schedulesSection(), s.riskSection(), s.phonesSection(), s.outboxSection(), s.auditSection()}
+}
 
-			}
+func (s *Service) Export(dir string) error {
+	return s.ExportWithProgress(context.Background(), dir, nil)
+}
 
-			_, err = fileAccounts.Write([]byte(strconv.FormatInt(int64(account.Balance), 10)))
-			if err != nil {
-				log.Print(err)
+// ExportWithProgress экспортирует данные в dir, сообщая fn о каждом записанном файле.
+// Для пустых разделов файлы не записываются, а оставшиеся от прошлого экспорта удаляются.
+func (s *Service) ExportWithProgress(ctx context.Context, dir string, fn ProgressFunc) (err error) {
+	call := s.beginAudit("Export", 0, "", "dir", dir)
+	defer func() { err = call.end("", err) }()
 
-			}
+	sections := s.exportSections()
 
-		}
+	total := 0
+	for _, section := range sections {
+		total += len(section.lines)
 	}
+	reporter := newProgressReporter(total, fn)
 
-	lenPay := len(s.payments)
-	if lenPay != 0 {
-
-		dirPayment := dir + "/payments.dump"
-		filePayments, err := os.Create(dirPayment)
+	for part, section := range sections {
+		err := ctx.Err()
 		if err != nil {
-			log.Print(err)
-
+			return err
 		}
 
-		defer func() {
-			if cerr := filePayments.Close(); err != nil {
-				log.Print(cerr)
-			}
-		}()
-
-		for index, payment := range s.payments {
-			//	account, err = s.FindAccountByID(int64(ind))
-			// fmt.Println(newP2)
-
-			if index != 0 {
-				_, err = filePayments.Write([]byte("\n"))
-				if err != nil {
-					log.Print(err)
-
-				}
-
-			}
-
-			_, err = filePayments.Write([]byte(string(payment.ID)))
-			if err != nil {
-				log.Print(err)
-
-			}
-
-			_, err = filePayments.Write([]byte(";"))
-			if err != nil {
-				log.Print(err)
-
-			}
-
-			_, err = filePayments.Write([]byte(strconv.FormatInt(int64(payment.AccountID), 10)))
-			if err != nil {
-				log.Print(err)
-
+		if len(section.lines) != 0 {
+			err = actionByFile(dir+"/"+section.name, strings.Join(section.lines, "\n"))
+		} else {
+			// удаляем файл прошлого экспорта, иначе Import вернёт удалённые данные
+			err = os.Remove(dir + "/" + section.name)
+			if os.IsNotExist(err) {
+				err = nil
 			}
+		}
+		if err != nil {
+			log.Print(err)
+			return err
+		}
 
-			_, err = filePayments.Write([]byte(";"))
-			if err != nil {
-				log.Print(err)
+		reporter.done(part, len(section.lines), 0)
+	}
 
-			}
+	return nil
+}
 
-			_, err = filePayments.Write([]byte(strconv.FormatInt(int64(payment.Amount), 10)))
-			if err != nil {
-				log.Print(err)
+// readDump читает строки файла экспорта, пропуская пустые.
+func readDump(path string) ([]string, error) {
+	byteData, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
 
-			}
+	lines := []string{}
+	for _, line := range strings.Split(string(byteData), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
 
-			_, err = filePayments.Write([]byte(";"))
-			if err != nil {
-				log.Print(err)
+	return lines, nil
+}
 
-			}
+func decodeAccount(line string) (*types.Account, error) {
+	return decodeAccountFields(strings.Split(line, ";"))
+}
 
-			_, err = filePayments.Write([]byte(string(payment.Category)))
-			if err != nil {
-				log.Print(err)
+func decodeAccountFields(data []string) (*types.Account, error) {
+	if len(data) < 3 {
+		return nil, ErrInvalidDump
+	}
 
-			}
+	id, err := strconv.ParseInt(data[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
 
-			_, err = filePayments.Write([]byte(";"))
-			if err != nil {
-				log.Print(err)
+	balance, err := strconv.ParseInt(data[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
 
-			}
+	overdraft := int64(0)
+	if len(data) > 3 {
+		overdraft, err = strconv.ParseInt(data[3], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-			_, err = filePayments.Write([]byte(string(payment.Status)))
-			if err != nil {
-				log.Print(err)
+	tier := types.AccountTier("")
+	if len(data) > 4 {
+		tier = types.AccountTier(data[4])
+	}
 
-			}
+	status := types.AccountStatusActive
+	if len(data) > 5 && data[5] != "" {
+		status = types.AccountStatus(data[5])
+	}
 
+	held := int64(0)
+	if len(data) > 6 {
+		held, err = strconv.ParseInt(data[6], 10, 64)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	lenFav := len(s.favorites)
-
-	if lenFav != 0 {
-		dirFavorite := dir + "/favorites.dump"
-		fileFavorites, err := os.Create(dirFavorite)
+	createdAt := time.Time{}
+	if len(data) > 7 {
+		createdAt, err = decodeTime(data[7])
 		if err != nil {
-			log.Print(err)
-
+			return nil, err
 		}
+	}
 
-		defer func() {
-			if cerr := fileFavorites.Close(); err != nil {
-				log.Print(cerr)
-			}
-		}()
-
-		for index, favorite := range s.favorites {
-			//	account, err = s.FindAccountByID(int64(ind))
-			// fmt.Println(newP2)
-
-			if index != 0 {
-				_, err = fileFavorites.Write([]byte("\n"))
-				if err != nil {
-					log.Print(err)
-
-				}
-
-			}
+	return &types.Account{
+		ID:        id,
+		Phone:     types.Phone(data[1]),
+		Balance:   types.Money(balance),
+		Overdraft: types.Money(overdraft),
+		Tier:      tier,
+		Status:    status,
+		Held:      types.Money(held),
+		CreatedAt: createdAt,
+	}, nil
+}
 
-			_, err = fileFavorites.Write([]byte(string(favorite.ID)))
-			if err != nil {
-				log.Print(err)
+func decodePayment(line string) (*types.Payment, error) {
+	data := strings.Split(line, ";")
+	payment, err := decodePaymentFields(data)
+	if err != nil {
+		return nil, err
+	}
 
-			}
+	// в дампах прежних версий аккаунта комиссии нет
+	if len(data) > 8 {
+		payment.FeeAccountID, err = strconv.ParseInt(data[8], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-			_, err = fileFavorites.Write([]byte(";"))
-			if err != nil {
-				log.Print(err)
+	return payment, nil
+}
 
-			}
+func decodePaymentFields(data []string) (*types.Payment, error) {
+	if len(data) < 5 {
+		return nil, ErrInvalidDump
+	}
 
-			_, err = fileFavorites.Write([]byte(strconv.FormatInt(int64(favorite.AccountID), 10)))
-			if err != nil {
-				log.Print(err)
+	accountID, err := strconv.ParseInt(data[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
 
-			}
+	amount, err := strconv.ParseInt(data[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
 
-			_, err = fileFavorites.Write([]byte(";"))
-			if err != nil {
-				log.Print(err)
+	// время создания появилось в формате позже, старые файлы его не содержат
+	createdAt := time.Time{}
+	if len(data) > 5 {
+		createdAt, err = decodeTime(data[5])
+		if err != nil {
+			return nil, err
+		}
+	}
 
-			}
+	fee := int64(0)
+	if len(data) > 6 {
+		fee, err = strconv.ParseInt(data[6], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-			_, err = fileFavorites.Write([]byte(string(favorite.Name)))
-			if err != nil {
-				log.Print(err)
+	return &types.Payment{
+		ID:        data[0],
+		AccountID: accountID,
+		Amount:    types.Money(amount),
+		Category:  types.PaymentCategory(data[3]),
+		Status:    types.PaymentStatus(data[4]),
+		CreatedAt: createdAt,
+		Fee:       types.Money(fee),
+	}, nil
+}
 
-			}
+func decodeFavorite(line string) (*types.Favorite, error) {
+	return decodeFavoriteFields(strings.Split(line, ";"))
+}
 
-			_, err = fileFavorites.Write([]byte(";"))
-			if err != nil {
-				log.Print(err)
+func decodeFavoriteFields(data []string) (*types.Favorite, error) {
+	if len(data) < 5 {
+		return nil, ErrInvalidDump
+	}
 
-			}
+	accountID, err := strconv.ParseInt(data[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
 
-			_, err = fileFavorites.Write([]byte(strconv.FormatInt(int64(favorite.Amount), 10)))
-			if err != nil {
-				log.Print(err)
+	amount, err := strconv.ParseInt(data[3], 10, 64)
+	if err != nil {
+		return nil, err
+	}
 
-			}
+	return &types.Favorite{
+		ID:        data[0],
+		AccountID: accountID,
+		Name:      data[2],
+		Amount:    types.Money(amount),
+		Category:  types.PaymentCategory(data[4]),
+	}, nil
+}
 
-			_, err = fileFavorites.Write([]byte(";"))
-			if err != nil {
-				log.Print(err)
+func (s *Service) importAccount(line string) error {
+	account, err := decodeAccount(line)
+	if err != nil {
+		return err
+	}
 
-			}
+	return s.upsertAccount(account)
+}
 
-			_, err = fileFavorites.Write([]byte(string(favorite.Category)))
-			if err != nil {
-				log.Print(err)
+// upsertAccount добавляет загруженный аккаунт или обновляет аккаунт с тем же ID.
+func (s *Service) upsertAccount(account *types.Account) (err error) {
+	account.Phone, err = s.normalizePhone(account.Phone)
+	if err != nil {
+		return err
+	}
 
-			}
+	existing := s.findAccountByNormalizedPhone(account.Phone)
+	if existing != nil && existing.ID != account.ID {
+		return ErrPhoneNumberRegistred
+	}
 
+	for _, accountCheck := range s.accounts {
+		if accountCheck.ID == account.ID {
+			s.touchAccount(accountCheck)
+			s.setPhone(accountCheck, account.Phone)
+			accountCheck.Balance = account.Balance
+			accountCheck.Overdraft = account.Overdraft
+			accountCheck.Tier = account.Tier
+			accountCheck.Status = account.Status
+			accountCheck.Held = account.Held
+			accountCheck.CreatedAt = account.CreatedAt
+			return nil
 		}
 	}
 
-	return err
+	s.addAccount(account)
+	// следующий RegisterAccount не должен выдать уже занятый ID
+	s.observeAccountID(account.ID)
 
+	return nil
 }
 
-//Import for
-func (s *Service) Import(dir string) error {
-
-	dirAccount := dir + "/accounts.dump"
-	fileAccount, err := os.Open(dirAccount)
-	//	log.Print(dirAccount)
+func (s *Service) importPayment(line string) error {
+	payment, err := decodePayment(line)
 	if err != nil {
-		log.Print(err)
-		err = ErrFileNotFound
+		return err
 	}
-	if err != ErrFileNotFound {
-		defer func() {
-			err := fileAccount.Close()
-			if err != nil {
-				log.Print(err)
-			}
-		}()
 
-		//		log.Printf("%#v", fileAccount)
+	s.upsertPayment(payment)
+	return nil
+}
 
-		content := make([]byte, 0)
-		buf := make([]byte, 4)
-		for {
-			read, err := fileAccount.Read(buf)
-			if err == io.EOF {
-				break
-			}
-			content = append(content, buf[:read]...)
+// upsertPayment добавляет загруженный платёж или обновляет платёж с тем же ID.
+func (s *Service) upsertPayment(payment *types.Payment) {
+	s.observeID(payment.ID)
+
+	for _, paymentCheck := range s.payments {
+		if paymentCheck.ID == payment.ID {
+			s.touchPayment(paymentCheck)
+			paymentCheck.AccountID = payment.AccountID
+			paymentCheck.Amount = payment.Amount
+			paymentCheck.Category = payment.Category
+			paymentCheck.Status = payment.Status
+			paymentCheck.CreatedAt = payment.CreatedAt
+			paymentCheck.Fee = payment.Fee
+			paymentCheck.FeeAccountID = payment.FeeAccountID
+			paymentCheck.Risk = payment.Risk
+			return
 		}
+	}
 
-		data := string(content)
-		newData := strings.Split(data, "\n")
-		//log.Print(data)
-		//log.Print(newData)
-
-		for _, stroka := range newData {
-			//log.Print(stroka)
-			account := &types.Account{}
-			newData2 := strings.Split(stroka, ";")
-			for ind, stroka2 := range newData2 {
-				// if stroka2 == "" {
-				// 	return ErrPhoneRegistered
-				// }
-				//log.Print(stroka2)
-				if ind == 0 {
-					id, _ := strconv.ParseInt(stroka2, 10, 64)
-					account.ID = id
-				}
-				if ind == 1 {
-					account.Phone = types.Phone(stroka2)
-				}
-				if ind == 2 {
-					balance, _ := strconv.ParseInt(stroka2, 10, 64)
-					account.Balance = types.Money(balance)
-
-				}
-
-				//	log.Print(ind1)
-
-			}
-			errExist := 1
-			for _, accountCheck := range s.accounts {
-
-				if accountCheck.ID == account.ID {
-					accountCheck.Phone = account.Phone
-					accountCheck.Balance = account.Balance
-					errExist = 0
-				}
-
-			}
-			if errExist == 1 {
-				s.accounts = append(s.accounts, account)
-			}
-		}
+	s.addPayment(payment)
+}
 
+func (s *Service) importFavorite(line string) error {
+	favorite, err := decodeFavorite(line)
+	if err != nil {
+		return err
 	}
 
-	dirPayment := dir + "/payments.dump"
-	filePayments, err := os.Open(dirPayment)
+	return s.upsertFavorite(favorite)
+}
+
+// upsertFavorite добавляет загруженный избранный платёж или обновляет избранное с тем же ID.
+// Имя, уже занятое другим избранным аккаунта, отклоняется с ErrFavoriteNameTaken, как в FavoritePayment.
+func (s *Service) upsertFavorite(favorite *types.Favorite) error {
+	err := s.checkFavoriteName(favorite.AccountID, favorite.Name, favorite.ID)
 	if err != nil {
-		log.Print(err)
-		return ErrFileNotFound
+		return err
 	}
-	if err != ErrFileNotFound {
-		defer func() {
-			err := filePayments.Close()
-			if err != nil {
-				log.Print(err)
-			}
-		}()
 
-		//		log.Printf("%#v", filePayments)
+	s.observeID(favorite.ID)
 
-		contentPayment := make([]byte, 0)
-		bufPayment := make([]byte, 4)
-		for {
-			read, err := filePayments.Read(bufPayment)
-			if err == io.EOF {
-				break
-			}
-			contentPayment = append(contentPayment, bufPayment[:read]...)
+	for _, favoriteCheck := range s.favorites {
+		if favoriteCheck.ID == favorite.ID {
+			s.touchFavorite(favoriteCheck)
+			favoriteCheck.AccountID = favorite.AccountID
+			favoriteCheck.Name = favorite.Name
+			favoriteCheck.Amount = favorite.Amount
+			favoriteCheck.Category = favorite.Category
+			return nil
 		}
+	}
 
-		dataPayment := string(contentPayment)
-		newDataPayment := strings.Split(dataPayment, "\n")
-		//log.Print(data)
-		//log.Print(newData)
-
-		for _, stroka := range newDataPayment {
-			//log.Print(stroka)
-			payment := &types.Payment{}
-			newData2 := strings.Split(stroka, ";")
-			for ind, stroka2 := range newData2 {
-				// if stroka2 == "" {
-				// 	return ErrPhoneRegistered
-				// }
-				//log.Print(stroka2)
-				if ind == 0 {
-					//id, _ := stroka2
-					payment.ID = stroka2
-				}
-				if ind == 1 {
-					accountID, _ := strconv.ParseInt(stroka2, 10, 64)
-					payment.AccountID = int64(accountID)
-				}
-
-				if ind == 2 {
-					balance, _ := strconv.ParseInt(stroka2, 10, 64)
-					payment.Amount = types.Money(balance)
-				}
-
-				if ind == 3 {
-					payment.Category = types.PaymentCategory(stroka2)
-				}
-
-				if ind == 4 {
-					payment.Status = types.PaymentStatus(stroka2)
-				}
-
-				//		log.Print(ind1)
-
-			}
-			errExist := 1
-			for _, paymentCheck := range s.payments {
-
-				if paymentCheck.ID == payment.ID {
-					paymentCheck.AccountID = payment.AccountID
-					paymentCheck.Amount = payment.Amount
-					paymentCheck.Category = payment.Category
-					paymentCheck.Status = payment.Status
-					errExist = 0
-				}
+	s.addFavorite(favorite)
+	return nil
+}
 
-			}
-			if errExist == 1 {
-				s.payments = append(s.payments, payment)
-			}
-		}
+//Import for
+func (s *Service) Import(dir string) error {
+	return s.ImportWithProgress(context.Background(), dir, nil)
+}
 
-	}
+// ImportWithProgress импортирует данные из dir, сообщая fn о каждом прочитанном файле.
+// Export не записывает пустые разделы, поэтому отсутствующий файл раздела - не ошибка;
+// ErrFileNotFound возвращается, только если нет самого каталога dir.
+func (s *Service) ImportWithProgress(ctx context.Context, dir string, fn ProgressFunc) (err error) {
+	call := s.beginAudit("Import", 0, "", "dir", dir)
+	defer func() { err = call.end("", err) }()
 
-	dirFavorite := dir + "/favorites.dump"
-	fileFavorites, err := os.Open(dirFavorite)
+	_, err = os.Stat(dir)
 	if err != nil {
 		log.Print(err)
-		err = ErrFileNotFound
+		return ErrFileNotFound
 	}
-	if err != ErrFileNotFound {
-		defer func() {
-			err := fileFavorites.Close()
-			if err != nil {
-				log.Print(err)
-			}
-		}()
 
-		//	log.Printf("%#v", fileFavorites)
+	importers := []struct {
+		name   string
+		action func(line string) error
+	}{
+		{"accounts.dump", s.importAccount},
+		{"payments.dump", s.importPayment},
+		{"favorites.dump", s.importFavorite},
+		{"idempotency.dump", s.importIdempotency},
+		{"holds.dump", s.importHold},
+		{"schedules.dump", s.importSchedule},
+		{"risk.dump", s.importRiskCheck},
+		{"phones.dump", s.importPhoneChange},
+		{"outbox.dump", s.importOutbox},
+		{"audit.dump", s.auditImporter()},
+	}
 
-		contentFavorite := make([]byte, 0)
-		bufFavorite := make([]byte, 4)
-		for {
-			read, err := fileFavorites.Read(bufFavorite)
-			if err == io.EOF {
-				break
-			}
-			contentFavorite = append(contentFavorite, bufFavorite[:read]...)
+	sections := make([][]string, len(importers))
+	total := 0
+	for i, importer := range importers {
+		lines, err := readDump(dir + "/" + importer.name)
+		if err != nil && !os.IsNotExist(err) {
+			log.Print(err)
+			return err
 		}
+		sections[i] = lines
+		total += len(lines)
+	}
+	reporter := newProgressReporter(total, fn)
 
-		dataFavorite := string(contentFavorite)
-		newDataFavorite := strings.Split(dataFavorite, "\n")
-		//log.Print(data)
-		//log.Print(newData)
-
-		for _, stroka := range newDataFavorite {
-			//log.Print(stroka)
-			favorite := &types.Favorite{}
-			newData2 := strings.Split(stroka, ";")
-			for ind, stroka2 := range newData2 {
-				// if stroka2 == "" {
-				// 	return ErrPhoneRegistered
-				// }
-				//log.Print(stroka2)
-				if ind == 0 {
-					//id, _ := stroka2
-					favorite.ID = stroka2
-				}
-				if ind == 1 {
-					accountID, _ := strconv.ParseInt(stroka2, 10, 64)
-					favorite.AccountID = int64(accountID)
-				}
-
-				if ind == 2 {
-					favorite.Name = stroka2
-				}
-				if ind == 3 {
-					balance, _ := strconv.ParseInt(stroka2, 10, 64)
-					favorite.Amount = types.Money(balance)
-				}
-
-				if ind == 4 {
-					favorite.Category = types.PaymentCategory(stroka2)
-				}
-
-				//	log.Print(ind1)
-
+	for part, lines := range sections {
+		for _, line := range lines {
+			err := ctx.Err()
+			if err != nil {
+				return err
 			}
-			errExist := 1
-			for _, favoriteCheck := range s.favorites {
-
-				if favoriteCheck.ID == favorite.ID {
-					favoriteCheck.AccountID = favorite.AccountID
-					favoriteCheck.Name = favorite.Name
-					favoriteCheck.Amount = favorite.Amount
-					favoriteCheck.Category = favorite.Category
-					errExist = 0
-				}
 
-			}
-			if errExist == 1 {
-				s.favorites = append(s.favorites, favorite)
+			err = importers[part].action(line)
+			if err != nil {
+				log.Print(err)
+				return err
 			}
 		}
 
+		reporter.done(part, len(lines), 0)
 	}
-	return nil
 
+	return nil
 }
 
 
 //FilterPaymentsByFn for
 func (s *Service) FilterPaymentsByFn(filter func(payment types.Payment) bool, goroutines int) ([]types.Payment, error) {
-	var foundPayments []types.Payment
-	
-	var allfoundPayments []types.Payment
-	for _, payment := range s.payments {
+	return s.FilterPaymentsByFnContext(context.Background(), filter, goroutines)
+}
 
-		if filter(*payment) == true {
-			foundPayments = append(foundPayments, *payment)
-			
-		}
-	}
-	if foundPayments == nil {
-		return nil, ErrAccountNotFound
-	}
+// FilterPaymentsByFnContext отбирает платежи по filter в goroutines горутинах,
+// сохраняя их исходный порядок.
+func (s *Service) FilterPaymentsByFnContext(ctx context.Context, filter func(payment types.Payment) bool, goroutines int) ([]types.Payment, error) {
+	return s.FilterPaymentsByFnWithProgress(ctx, filter, goroutines, nil)
+}
 
-	if goroutines <= 1 {
-		return foundPayments, nil
+// FilterPaymentsByFnWithProgress отбирает платежи по filter, сообщая fn о каждой обработанной части.
+// Progress.Result - сумма платежей, отобранных в части.
+func (s *Service) FilterPaymentsByFnWithProgress(ctx context.Context, filter func(payment types.Payment) bool, goroutines int, fn ProgressFunc) ([]types.Payment, error) {
+	reporter := newProgressReporter(len(s.payments), fn)
+	found, err := s.MapReducePayments(ctx, goroutines, []types.Payment{}, withProgress(filterMapper(filter), reporter), filterReducer)
+	if err != nil {
+		return nil, err
 	}
 
-	wg := sync.WaitGroup{}
-
-	wg.Add(goroutines) 
-
-	mu := sync.Mutex{}
-
-	lenPay := len(foundPayments)
-	numberOfPaymentPerRoutine := 0
-
-	numberOfPaymentPerRoutine = int(math.Ceil(float64((lenPay + 1) / goroutines)))
-
-	
-
-	index := 0
-	newNumberOfPaymentPerRoutine := numberOfPaymentPerRoutine
-
-	go func() {
-		for i := 0; i < goroutines; i++ {
-			lenPay := len(foundPayments)
-
-			
-
-			defer wg.Done() 
-			var newPayments []types.Payment
-
-			for i := 0; index < numberOfPaymentPerRoutine; i++ {
-				
-				newPayments = append(newPayments, foundPayments[index])
-				
-				index++
-			}
-
-			mu.Lock()
-			numberOfPaymentPerRoutine += newNumberOfPaymentPerRoutine
-
-			allfoundPayments = append(allfoundPayments, newPayments...)
-			mu.Unlock()
-			if (i == goroutines-1) && (len(allfoundPayments) != lenPay) {
-
-				foundLen := len(allfoundPayments)
-				for j := foundLen; j < lenPay; j++ {
-					allfoundPayments = append(allfoundPayments, foundPayments[j])
-				}
-			}
-
-		}
-	}()
-
+	foundPayments := found.([]types.Payment)
+	if len(foundPayments) == 0 {
+		return nil, ErrAccountNotFound
+	}
 
-	wg.Wait()
-	return allfoundPayments, nil
+	return foundPayments, nil
 }
 
 
-
 type Progress struct {
 	Part int
 	Result types.Money
@@ -1279,36 +1358,28 @@ type Progress struct {
 func (s *Service) SumPaymentsWithProgress() <-chan types.Progress {
 	size := 100_0000
 
-	amountOfMoney := make([]types.Money, 0)
-	for _, pay := range s.payments {
-		amountOfMoney = append(amountOfMoney, pay.Amount)
-	}
-
-	wg := sync.WaitGroup{}
-	goroutines := (len(amountOfMoney) + 1) / size
-	ch := make(chan types.Progress)
-	if goroutines <= 0 {
-		goroutines = 1
+	parts := (len(s.payments) + size - 1) / size
+	if parts <= 0 {
+		parts = 1
 	}
-	for i := 0; i < goroutines; i++ {
-		wg.Add(1)
-		go func(ch chan<- types.Progress, amountOfMoney []types.Money, part int) {
-			sum := 0
-			defer wg.Done()
-			for _, val := range amountOfMoney {
-				sum += int(val)
 
-			}
-			ch <- types.Progress{
-				Result: types.Money(sum),
-			}
-		}(ch, amountOfMoney, i)
+	// канал буферизован на все части, поэтому отправка никогда не блокируется
+	// и горутины не зависнут, даже если читатель перестанет читать
+	ch := make(chan types.Progress, parts)
+	fn := func(progress types.Progress) {
+		ch <- progress
 	}
 
+	// горутины работают после возврата из метода, поэтому суммируют копию платежей,
+	// а не s.payments, которые к тому времени могут измениться
+	snapshot := s.SnapshotPayments()
 	go func() {
 		defer close(ch)
-		wg.Wait()
+		_, err := snapshot.SumWithProgressFunc(context.Background(), parts, fn)
+		if err != nil {
+			log.Print(err)
+		}
 	}()
 
 	return ch
-}
\ No newline at end of file
+}
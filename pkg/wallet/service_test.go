@@ -6,10 +6,49 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/Ulugbek999/wallet/pkg/types"
 )
 
+// newTestService создаёт сервис с остановленными часами и аккаунтом "+992000000000" с балансом balance.
+// Часы можно сдвигать через возвращённый указатель.
+func newTestService(t *testing.T, balance types.Money) (*Service, *types.Account, *time.Time) {
+	t.Helper()
+
+	now := time.Date(2021, 3, 15, 10, 0, 0, 0, time.UTC)
+	svc := &Service{}
+	svc.SetClock(func() time.Time {
+		return now
+	})
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if balance > 0 {
+		err = svc.Deposit(account.ID, balance)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return svc, account, &now
+}
+
+// mustPay проводит платёж и останавливает тест при ошибке.
+func mustPay(t *testing.T, svc *Service, accountID int64, amount types.Money, category types.PaymentCategory) *types.Payment {
+	t.Helper()
+
+	payment, err := svc.Pay(accountID, amount, category)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return payment
+}
+
 func TestService_Register(t *testing.T) {
 	svc := Service{}
 	_, err := svc.RegisterAccount("+992000000000")
@@ -432,7 +471,8 @@ func TestService_Import_IfHaveData(t *testing.T) {
 		t.Error(err)
 	}
 
-	_, err = svc.FavoritePayment(payment.ID, "isbraniy")
+	// в дампе TestSetice_Export у этого аккаунта уже есть избранное "isbraniy" с другим ID
+	_, err = svc.FavoritePayment(payment.ID, "isbraniy 2")
 	if err != nil {
 		t.Error(err)
 	}
@@ -447,6 +487,38 @@ func TestService_Import_IfHaveData(t *testing.T) {
 	}
 }
 
+func TestService_Import_nextAccountID(t *testing.T) {
+	dir := t.TempDir()
+	svc := &Service{}
+
+	for _, phone := range []types.Phone{"+992000000001", "+992000000002"} {
+		_, err := svc.RegisterAccount(phone)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := svc.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newSvc := &Service{}
+	err = newSvc.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account, err := newSvc.RegisterAccount("+992000000003")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if account.ID != 3 {
+		t.Errorf("invalid account id after import, got %v, want 3", account.ID)
+	}
+}
+
 func TestService_HistoryToFile(t *testing.T) {
 	svc := &Service{}
 
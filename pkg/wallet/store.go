@@ -0,0 +1,613 @@
+package wallet
+
+import (
+	"sort"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+// Store - постоянное хранилище аккаунтов, платежей, избранного, резервирований, повторяющихся платежей,
+// истории смен номеров, outbox, ключей идемпотентности и журнала аудита (pkg/sqlitestore, pkg/boltstore, LogStore).
+// Сервис с хранилищем после каждого вызова передаёт изменённые им объекты, новые и доставленные
+// события outbox и запись аудита о вызове одним вызовом Apply, который должен применить их атомарно:
+// событие не может быть сохранено без изменений, о которых оно сообщает, и наоборот.
+// Если Apply вернул ошибку, все изменения вызова откатываются в памяти, его события не доставляются
+// подписчикам, а вызов возвращает эту ошибку; в журнале аудита вызов остаётся с этой ошибкой
+// и записывается в хранилище со следующим принятым вызовом.
+type Store interface {
+	// Load возвращает все объекты хранилища.
+	Load() (StoreChanges, error)
+	// Apply атомарно записывает изменённые объекты и удаляет удалённое избранное.
+	Apply(changes StoreChanges) error
+}
+
+// StoreChanges - объекты для записи в хранилище или загруженные из него.
+type StoreChanges struct {
+	Accounts  []types.Account
+	Payments  []types.Payment
+	Favorites []types.Favorite
+	// DeletedFavorites - ID удалённых избранных платежей
+	DeletedFavorites []string
+	Holds            []types.Hold
+	// Schedules - повторяющиеся платежи, DeletedSchedules - ID отменённых и снятых
+	Schedules        []ScheduleRecord
+	DeletedSchedules []string
+	// PhoneChanges - новые записи истории смен номеров (история только дополняется)
+	PhoneChanges []PhoneChange
+	// Outbox - новые события и события с изменённым состоянием доставки (по Event.Seq)
+	Outbox []OutboxEntry
+	// DeletedOutbox - номера событий, удалённых из очереди CompactOutbox
+	DeletedOutbox []uint64
+	// Idempotency - новые ключи идемпотентности, DeletedIdempotency - удалённые (истёкшие) ключи
+	Idempotency        []IdempotencyRecord
+	DeletedIdempotency []string
+	// Audit - новые записи журнала аудита (журнал только дополняется)
+	Audit []AuditRecord
+}
+
+func (c StoreChanges) empty() bool {
+	return len(c.Accounts) == 0 && len(c.Payments) == 0 && len(c.Favorites) == 0 && len(c.DeletedFavorites) == 0 && len(c.Holds) == 0 &&
+		len(c.Schedules) == 0 && len(c.DeletedSchedules) == 0 && len(c.PhoneChanges) == 0 &&
+		len(c.Outbox) == 0 && len(c.DeletedOutbox) == 0 && len(c.Idempotency) == 0 && len(c.DeletedIdempotency) == 0 && len(c.Audit) == 0
+}
+
+// changeLog - изменения текущего вызова: затронутые объекты для записи в хранилище
+// и действия отмены, которые в обратном порядке возвращают сервис к состоянию до вызова.
+// Ведётся только при подключённом хранилище, поэтому запись и откат занимают время,
+// пропорциональное изменениям вызова, а не размеру сервиса.
+type changeLog struct {
+	accounts         []*types.Account
+	payments         []*types.Payment
+	favorites        []*types.Favorite
+	deletedFavorites []string
+	holds            []*types.Hold
+	phoneChanges     []PhoneChange
+	outbox           []*OutboxEntry
+	deletedOutbox    []uint64
+	idempotency      []string
+	// touched - уже записанные в журнал объекты и ключи идемпотентности; removed - удалённое избранное
+	touched map[interface{}]bool
+	removed map[*types.Favorite]bool
+	undo    []func()
+	// outboxSaved, schedulesSaved - outbox и расписания сохранены для отката целиком
+	outboxSaved    bool
+	schedulesSaved bool
+	// schedules, scheduleValues - повторяющиеся платежи до вызова, по ним определяются изменённые
+	schedules      []*RecurringPayment
+	scheduleValues []RecurringPayment
+}
+
+func newChangeLog() *changeLog {
+	return &changeLog{
+		touched: map[interface{}]bool{},
+		removed: map[*types.Favorite]bool{},
+	}
+}
+
+// changeLog возвращает журнал изменений текущего вызова или nil, если хранилище не подключено
+// (и не подключается сейчас через SetStore).
+func (s *Service) changeLog() *changeLog {
+	if s.changes != nil {
+		return s.changes
+	}
+
+	if s.store == nil {
+		return nil
+	}
+
+	s.changes = newChangeLog()
+	return s.changes
+}
+
+// onRollback добавляет действие, отменяющее изменение, если хранилище его не примет.
+func (s *Service) onRollback(undo func()) {
+	changes := s.changeLog()
+	if changes != nil {
+		changes.undo = append(changes.undo, undo)
+	}
+}
+
+// touchAccount вызывается перед изменением аккаунта: аккаунт будет записан в хранилище,
+// а при откате получит прежнее значение.
+func (s *Service) touchAccount(account *types.Account) {
+	changes := s.changeLog()
+	if changes == nil || changes.touched[account] {
+		return
+	}
+
+	before := *account
+	changes.touched[account] = true
+	changes.accounts = append(changes.accounts, account)
+	changes.undo = append(changes.undo, func() { *account = before })
+}
+
+// addAccount добавляет новый аккаунт.
+func (s *Service) addAccount(account *types.Account) {
+	count := len(s.accounts)
+	s.accounts = append(s.accounts, account)
+	s.indexPhone(account)
+
+	changes := s.changeLog()
+	if changes == nil {
+		return
+	}
+
+	changes.touched[account] = true
+	changes.accounts = append(changes.accounts, account)
+	changes.undo = append(changes.undo, func() {
+		s.unindexPhone(account, account.Phone)
+		s.accounts = s.accounts[:count]
+	})
+}
+
+// touchPayment - touchAccount для платежа.
+func (s *Service) touchPayment(payment *types.Payment) {
+	changes := s.changeLog()
+	if changes == nil || changes.touched[payment] {
+		return
+	}
+
+	before := *payment
+	// проверки антифрода изменяются на месте, поэтому копируются отдельно
+	before.Risk = append([]types.RiskCheck(nil), payment.Risk...)
+	changes.touched[payment] = true
+	changes.payments = append(changes.payments, payment)
+	changes.undo = append(changes.undo, func() { *payment = before })
+}
+
+// addPayment добавляет новый платёж.
+func (s *Service) addPayment(payment *types.Payment) {
+	count := len(s.payments)
+	s.payments = append(s.payments, payment)
+
+	changes := s.changeLog()
+	if changes == nil {
+		return
+	}
+
+	changes.touched[payment] = true
+	changes.payments = append(changes.payments, payment)
+	changes.undo = append(changes.undo, func() { s.payments = s.payments[:count] })
+}
+
+// touchFavorite - touchAccount для избранного.
+func (s *Service) touchFavorite(favorite *types.Favorite) {
+	changes := s.changeLog()
+	if changes == nil || changes.touched[favorite] {
+		return
+	}
+
+	before := *favorite
+	changes.touched[favorite] = true
+	changes.favorites = append(changes.favorites, favorite)
+	changes.undo = append(changes.undo, func() { *favorite = before })
+}
+
+// addFavorite добавляет новый избранный платёж.
+func (s *Service) addFavorite(favorite *types.Favorite) {
+	count := len(s.favorites)
+	s.favorites = append(s.favorites, favorite)
+
+	changes := s.changeLog()
+	if changes == nil {
+		return
+	}
+
+	changes.touched[favorite] = true
+	changes.favorites = append(changes.favorites, favorite)
+	changes.undo = append(changes.undo, func() { s.favorites = s.favorites[:count] })
+}
+
+// removeFavorite удаляет избранный платёж с индексом i.
+func (s *Service) removeFavorite(i int) {
+	favorite := s.favorites[i]
+	s.favorites = append(s.favorites[:i], s.favorites[i+1:]...)
+
+	changes := s.changeLog()
+	if changes == nil {
+		return
+	}
+
+	changes.removed[favorite] = true
+	changes.deletedFavorites = append(changes.deletedFavorites, favorite.ID)
+	changes.undo = append(changes.undo, func() {
+		delete(changes.removed, favorite)
+		s.favorites = append(s.favorites[:i], append([]*types.Favorite{favorite}, s.favorites[i:]...)...)
+	})
+}
+
+// touchHold - touchAccount для резервирования.
+func (s *Service) touchHold(hold *types.Hold) {
+	changes := s.changeLog()
+	if changes == nil || changes.touched[hold] {
+		return
+	}
+
+	before := *hold
+	changes.touched[hold] = true
+	changes.holds = append(changes.holds, hold)
+	changes.undo = append(changes.undo, func() { *hold = before })
+}
+
+// addHold добавляет новое резервирование.
+func (s *Service) addHold(hold *types.Hold) {
+	count := len(s.holds)
+	s.holds = append(s.holds, hold)
+
+	changes := s.changeLog()
+	if changes == nil {
+		return
+	}
+
+	changes.touched[hold] = true
+	changes.holds = append(changes.holds, hold)
+	changes.undo = append(changes.undo, func() { s.holds = s.holds[:count] })
+}
+
+// addPhoneChange добавляет запись в историю смен номеров.
+func (s *Service) addPhoneChange(change PhoneChange) {
+	count := len(s.phoneChanges)
+	s.phoneChanges = append(s.phoneChanges, change)
+
+	changes := s.changeLog()
+	if changes == nil {
+		return
+	}
+
+	changes.phoneChanges = append(changes.phoneChanges, change)
+	changes.undo = append(changes.undo, func() { s.phoneChanges = s.phoneChanges[:count] })
+}
+
+// touchOutbox вызывается для нового события outbox или перед изменением состояния его доставки.
+func (s *Service) touchOutbox(entry *OutboxEntry) {
+	changes := s.changeLog()
+	if changes == nil || changes.touched[entry] {
+		return
+	}
+
+	changes.touched[entry] = true
+	changes.outbox = append(changes.outbox, entry)
+}
+
+// removeOutbox отмечает событие, удаляемое из outbox.
+func (s *Service) removeOutbox(entry *OutboxEntry) {
+	changes := s.changeLog()
+	if changes == nil {
+		return
+	}
+
+	changes.deletedOutbox = append(changes.deletedOutbox, entry.Event.Seq)
+}
+
+// touchIdempotency вызывается перед сохранением или удалением записи ключа идемпотентности key.
+func (s *Service) touchIdempotency(key string) {
+	changes := s.changeLog()
+	if changes == nil || changes.touched[key] {
+		return
+	}
+
+	changes.touched[key] = true
+	changes.idempotency = append(changes.idempotency, key)
+}
+
+// saveOutbox сохраняет outbox для отката перед изменением уже опубликованных событий.
+func (s *Service) saveOutbox() {
+	changes := s.changeLog()
+	if changes == nil || changes.outboxSaved {
+		return
+	}
+
+	outbox := append([]*OutboxEntry{}, s.outbox...)
+	entries := make([]OutboxEntry, len(outbox))
+	for i, entry := range outbox {
+		entries[i] = *entry
+	}
+	eventSeq := s.eventSeq
+
+	changes.outboxSaved = true
+	changes.undo = append(changes.undo, func() {
+		for i, entry := range outbox {
+			*entry = entries[i]
+		}
+		s.outbox = outbox
+		s.eventSeq = eventSeq
+	})
+}
+
+// saveSchedules сохраняет повторяющиеся платежи и историю их запусков для отката перед их изменением.
+func (s *Service) saveSchedules() {
+	changes := s.changeLog()
+	if changes == nil || changes.schedulesSaved {
+		return
+	}
+
+	schedules := append([]*RecurringPayment{}, s.schedules...)
+	values := make([]RecurringPayment, len(schedules))
+	for i, recurring := range schedules {
+		values[i] = *recurring
+	}
+	runs := len(s.scheduleRuns)
+
+	changes.schedulesSaved = true
+	changes.schedules = schedules
+	changes.scheduleValues = values
+	changes.undo = append(changes.undo, func() {
+		for i, recurring := range schedules {
+			*recurring = values[i]
+		}
+		s.schedules = schedules
+		s.scheduleRuns = s.scheduleRuns[:runs]
+	})
+}
+
+// SetStore подключает хранилище: загружает из него объекты (совпадающие по ID заменяют объекты в памяти)
+// и сохраняет в него объекты, которых там ещё нет. nil отключает хранилище.
+// Если загрузка не удалась или хранилище не приняло объекты, оно не подключается,
+// а уже загруженные объекты убираются: сервис остаётся в состоянии до вызова.
+func (s *Service) SetStore(store Store) (err error) {
+	call := s.beginAudit("SetStore", 0, "")
+	defer func() { err = call.end("", err) }()
+
+	s.store = nil
+	s.changes = nil
+	if store == nil {
+		return nil
+	}
+
+	// загрузка ведёт журнал изменений, хотя хранилище ещё не подключено, чтобы её можно было откатить
+	s.changes = newChangeLog()
+	defer func() {
+		if err != nil {
+			s.rollbackChanges()
+			return
+		}
+		s.changes = nil
+	}()
+
+	loaded, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	storedAccounts := map[int64]bool{}
+	for i := range loaded.Accounts {
+		err = s.upsertAccount(&loaded.Accounts[i])
+		if err != nil {
+			return err
+		}
+		storedAccounts[loaded.Accounts[i].ID] = true
+	}
+	storedPayments := map[string]bool{}
+	for i := range loaded.Payments {
+		s.upsertPayment(&loaded.Payments[i])
+		storedPayments[loaded.Payments[i].ID] = true
+	}
+	storedFavorites := map[string]bool{}
+	for i := range loaded.Favorites {
+		err = s.upsertFavorite(&loaded.Favorites[i])
+		if err != nil {
+			return err
+		}
+		storedFavorites[loaded.Favorites[i].ID] = true
+	}
+	storedHolds := map[string]bool{}
+	for i := range loaded.Holds {
+		s.upsertHold(&loaded.Holds[i])
+		storedHolds[loaded.Holds[i].ID] = true
+	}
+	storedSchedules := map[string]bool{}
+	for _, record := range loaded.Schedules {
+		recurring, err := loadedSchedule(record)
+		if err != nil {
+			return err
+		}
+		s.upsertSchedule(recurring)
+		storedSchedules[record.ID] = true
+	}
+	storedPhoneChanges := map[string]bool{}
+	for _, change := range loaded.PhoneChanges {
+		s.upsertPhoneChange(change)
+		storedPhoneChanges[encodePhoneChange(change)] = true
+	}
+	storedOutbox := map[string]bool{}
+	for i := range loaded.Outbox {
+		entry := loaded.Outbox[i]
+		s.importOutboxEntry(&entry)
+		storedOutbox[encodeOutboxEntry(&entry)] = true
+	}
+	storedKeys := map[string]bool{}
+	for _, record := range loaded.Idempotency {
+		s.setIdempotency(record.Key, loadedIdempotency(record))
+		storedKeys[record.Key] = true
+	}
+
+	// журнал аудита хранилища - основной: записи сервиса, которых в нём нет,
+	// дописываются после него и продолжают его цепочку хешей
+	auditLog := s.auditLog
+	s.onRollback(func() { s.auditLog = auditLog })
+	common := 0
+	for common < len(loaded.Audit) && common < len(s.auditLog) && s.auditLog[common].Hash == loaded.Audit[common].Hash {
+		common++
+	}
+	local := s.auditLog[common:]
+	s.auditLog = nil
+	for i := range loaded.Audit {
+		s.auditLog = append(s.auditLog, &loaded.Audit[i])
+	}
+	for _, record := range local {
+		s.appendAudit(record)
+	}
+
+	// остальные объекты в хранилище ещё не попадали
+	changes := StoreChanges{}
+	for _, account := range s.accounts {
+		if !storedAccounts[account.ID] {
+			changes.Accounts = append(changes.Accounts, *account)
+		}
+	}
+	for _, payment := range s.payments {
+		if !storedPayments[payment.ID] {
+			changes.Payments = append(changes.Payments, *payment)
+		}
+	}
+	for _, favorite := range s.favorites {
+		if !storedFavorites[favorite.ID] {
+			changes.Favorites = append(changes.Favorites, *favorite)
+		}
+	}
+	for _, hold := range s.holds {
+		if !storedHolds[hold.ID] {
+			changes.Holds = append(changes.Holds, *hold)
+		}
+	}
+	for _, recurring := range s.schedules {
+		if !storedSchedules[recurring.ID] {
+			changes.Schedules = append(changes.Schedules, storedSchedule(recurring))
+		}
+	}
+	for _, change := range s.phoneChanges {
+		if !storedPhoneChanges[encodePhoneChange(change)] {
+			changes.PhoneChanges = append(changes.PhoneChanges, change)
+		}
+	}
+	for _, entry := range s.outbox {
+		if !storedOutbox[encodeOutboxEntry(entry)] {
+			changes.Outbox = append(changes.Outbox, *entry)
+		}
+	}
+	keys := []string{}
+	for key := range s.idempotency {
+		if !storedKeys[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		changes.Idempotency = append(changes.Idempotency, storedIdempotency(s.idempotency[key]))
+	}
+	for _, record := range s.auditLog[len(loaded.Audit):] {
+		changes.Audit = append(changes.Audit, *record)
+	}
+
+	if !changes.empty() {
+		err = store.Apply(changes)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.store = store
+	s.auditStored = len(s.auditLog)
+	return nil
+}
+
+// flushStore записывает в хранилище объекты и события, изменённые текущим вызовом,
+// и ещё не сохранённые записи журнала аудита.
+func (s *Service) flushStore() error {
+	if s.store == nil {
+		return nil
+	}
+
+	applied := StoreChanges{}
+	changes := s.changes
+	if changes != nil {
+		applied.DeletedFavorites = changes.deletedFavorites
+		for _, account := range changes.accounts {
+			applied.Accounts = append(applied.Accounts, *account)
+		}
+		for _, payment := range changes.payments {
+			applied.Payments = append(applied.Payments, *payment)
+		}
+		for _, favorite := range changes.favorites {
+			if !changes.removed[favorite] {
+				applied.Favorites = append(applied.Favorites, *favorite)
+			}
+		}
+		for _, hold := range changes.holds {
+			applied.Holds = append(applied.Holds, *hold)
+		}
+		applied.Schedules, applied.DeletedSchedules = s.changedSchedules(changes)
+		applied.PhoneChanges = changes.phoneChanges
+
+		deleted := map[uint64]bool{}
+		for _, seq := range changes.deletedOutbox {
+			deleted[seq] = true
+		}
+		applied.DeletedOutbox = changes.deletedOutbox
+		for _, entry := range changes.outbox {
+			if !deleted[entry.Event.Seq] {
+				applied.Outbox = append(applied.Outbox, *entry)
+			}
+		}
+
+		for _, key := range changes.idempotency {
+			record, ok := s.idempotency[key]
+			if ok {
+				applied.Idempotency = append(applied.Idempotency, storedIdempotency(record))
+			} else {
+				applied.DeletedIdempotency = append(applied.DeletedIdempotency, key)
+			}
+		}
+	}
+
+	for _, record := range s.auditLog[s.auditStored:] {
+		applied.Audit = append(applied.Audit, *record)
+	}
+
+	if applied.empty() {
+		return nil
+	}
+
+	return s.store.Apply(applied)
+}
+
+// changedSchedules возвращает повторяющиеся платежи, добавленные или изменённые вызовом, и ID удалённых.
+func (s *Service) changedSchedules(changes *changeLog) ([]ScheduleRecord, []string) {
+	if !changes.schedulesSaved {
+		return nil, nil
+	}
+
+	before := map[*RecurringPayment]RecurringPayment{}
+	for i, recurring := range changes.schedules {
+		before[recurring] = changes.scheduleValues[i]
+	}
+
+	changed := []ScheduleRecord{}
+	current := map[*RecurringPayment]bool{}
+	for _, recurring := range s.schedules {
+		current[recurring] = true
+		value, ok := before[recurring]
+		if !ok || storedSchedule(&value) != storedSchedule(recurring) {
+			changed = append(changed, storedSchedule(recurring))
+		}
+	}
+
+	deleted := []string{}
+	for _, recurring := range changes.schedules {
+		if !current[recurring] {
+			deleted = append(deleted, recurring.ID)
+		}
+	}
+
+	return changed, deleted
+}
+
+// commitChanges завершает журнал изменений вызова, принятого хранилищем.
+func (s *Service) commitChanges() {
+	s.changes = nil
+	s.auditStored = len(s.auditLog)
+}
+
+// rollbackChanges отменяет изменения текущего вызова и его неотправленные события.
+func (s *Service) rollbackChanges() {
+	changes := s.changes
+	s.changes = nil
+	s.pendingEvents = nil
+	if changes == nil {
+		return
+	}
+
+	for i := len(changes.undo) - 1; i >= 0; i-- {
+		changes.undo[i]()
+	}
+}
@@ -0,0 +1,189 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+)
+
+var errStoreDown = errors.New("store down")
+
+// failingStore загружает loaded, запоминает последние изменения и отклоняет Apply, пока fail = true.
+type failingStore struct {
+	fail   bool
+	loaded StoreChanges
+	last   StoreChanges
+}
+
+func (s *failingStore) Load() (StoreChanges, error) {
+	return s.loaded, nil
+}
+
+func (s *failingStore) Apply(changes StoreChanges) error {
+	if s.fail {
+		return errStoreDown
+	}
+
+	s.last = changes
+	return nil
+}
+
+func registerStored(t *testing.T, svc *Service, phone types.Phone) *types.Account {
+	t.Helper()
+
+	account, err := svc.RegisterAccount(phone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return account
+}
+
+func TestService_SetStore_applyOnlyChanges(t *testing.T) {
+	svc := &Service{}
+	store := &failingStore{}
+	err := svc.SetStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := registerStored(t, svc, "+992000000001")
+	registerStored(t, svc, "+992000000002")
+
+	payment, err := svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.last.Accounts) != 1 || store.last.Accounts[0].ID != account.ID || store.last.Accounts[0].Balance != 900 {
+		t.Errorf("invalid accounts: %+v", store.last.Accounts)
+	}
+	if len(store.last.Payments) != 1 || store.last.Payments[0].ID != payment.ID {
+		t.Errorf("invalid payments: %+v", store.last.Payments)
+	}
+	// событие и запись аудита сохраняются тем же Apply, что и платёж
+	if len(store.last.Outbox) != 1 || store.last.Outbox[0].Event.PaymentID != payment.ID {
+		t.Errorf("invalid outbox: %+v", store.last.Outbox)
+	}
+	if len(store.last.Audit) != 1 || store.last.Audit[0].Operation != "Pay" || store.last.Audit[0].PaymentID != payment.ID {
+		t.Errorf("invalid audit: %+v", store.last.Audit)
+	}
+}
+
+func TestService_SetStore_rollback(t *testing.T) {
+	svc := &Service{}
+	store := &failingStore{}
+	err := svc.SetStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := registerStored(t, svc, "+992000000001")
+
+	events := []Event{}
+	svc.Subscribe(func(event Event) {
+		events = append(events, event)
+	})
+	outbox := len(svc.Outbox())
+	audit := len(svc.AuditLog())
+
+	store.fail = true
+	_, err = svc.PayIdempotent("key-1", account.ID, 100, "auto")
+	if err != errStoreDown {
+		t.Fatalf("invalid error, got %v, want %v", err, errStoreDown)
+	}
+
+	_, err = svc.Authorize(account.ID, 200, "auto")
+	if err != errStoreDown {
+		t.Fatalf("invalid error, got %v, want %v", err, errStoreDown)
+	}
+
+	if account.Balance != 1000 || account.Held != 0 {
+		t.Errorf("account not rolled back: %+v", account)
+	}
+	if len(svc.payments) != 0 || len(svc.holds) != 0 || len(svc.idempotency) != 0 {
+		t.Errorf("state not rolled back: payments %v, holds %v, idempotency %v", svc.payments, svc.holds, svc.idempotency)
+	}
+	if len(events) != 0 {
+		t.Errorf("events delivered: %v", eventTypes(events))
+	}
+	if got := len(svc.Outbox()); got != outbox {
+		t.Errorf("invalid outbox, got %v entries, want %v", got, outbox)
+	}
+
+	log := svc.AuditLog()
+	if len(log) != audit+2 || log[audit].Error != errStoreDown.Error() {
+		t.Errorf("invalid audit log: %+v", log[audit:])
+	}
+
+	store.fail = false
+	payment, err := svc.PayIdempotent("key-1", account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if account.Balance != 900 || store.last.Payments[0].ID != payment.ID {
+		t.Errorf("invalid retry: %+v, %+v", account, store.last)
+	}
+	if len(store.last.Idempotency) != 1 || store.last.Idempotency[0].Key != "key-1" || store.last.Idempotency[0].PaymentID != payment.ID {
+		t.Errorf("invalid idempotency: %+v", store.last.Idempotency)
+	}
+	// записи об отклонённых вызовах сохраняются со следующим принятым вызовом
+	if len(store.last.Audit) != 3 || store.last.Audit[0].Error != errStoreDown.Error() || store.last.Audit[2].Error != "" {
+		t.Errorf("invalid audit: %+v", store.last.Audit)
+	}
+	if len(events) == 0 {
+		t.Error("events not delivered after retry")
+	}
+}
+
+func TestService_SetStore_loadRollback(t *testing.T) {
+	svc := &Service{}
+	account := registerStored(t, svc, "+992000000001")
+
+	stored := types.Account{ID: 42, Phone: "+992000000042", Balance: 500, Status: types.AccountStatusActive}
+	store := &failingStore{
+		fail: true,
+		loaded: StoreChanges{
+			Accounts: []types.Account{stored, {ID: account.ID, Phone: account.Phone, Balance: 1}},
+			Payments: []types.Payment{{ID: "stored", AccountID: 42, Amount: 10, Category: "auto", Status: types.PaymentStatusOk}},
+		},
+	}
+	err := svc.SetStore(store)
+	if err != errStoreDown {
+		t.Fatalf("invalid error, got %v, want %v", err, errStoreDown)
+	}
+
+	_, err = svc.FindAccountByID(42)
+	if err != ErrAccountNotFound {
+		t.Errorf("loaded account left after failed SetStore: %v", err)
+	}
+	if account.Balance != 1000 || len(svc.accounts) != 1 || len(svc.payments) != 0 {
+		t.Errorf("state not rolled back: %+v, accounts %v, payments %v", account, len(svc.accounts), svc.payments)
+	}
+
+	// загрузка, прерванная ошибкой на втором аккаунте (номер уже занят другим ID)
+	store.fail = false
+	store.loaded.Accounts = []types.Account{stored, {ID: 43, Phone: stored.Phone}}
+	err = svc.SetStore(store)
+	if err != ErrPhoneNumberRegistred {
+		t.Fatalf("invalid error, got %v, want %v", err, ErrPhoneNumberRegistred)
+	}
+	_, err = svc.FindAccountByID(42)
+	if err != ErrAccountNotFound || svc.store != nil {
+		t.Errorf("partial load left: %v, store %v", err, svc.store)
+	}
+
+	next, err := svc.RegisterAccount("+992000000002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.ID != account.ID+1 {
+		t.Errorf("invalid account id after rollback, got %v, want %v", next.ID, account.ID+1)
+	}
+}
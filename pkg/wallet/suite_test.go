@@ -0,0 +1,38 @@
+package wallet_test
+
+import (
+	"testing"
+
+	"github.com/Ulugbek999/wallet/pkg/wallet"
+	"github.com/Ulugbek999/wallet/pkg/wallet/wallettest"
+)
+
+func TestService_suite(t *testing.T) {
+	wallettest.Run(t, func(t *testing.T) (*wallet.Service, func() *wallet.Service) {
+		svc := &wallet.Service{}
+		return svc, func() *wallet.Service { return svc }
+	})
+}
+
+func TestLogStore_suite(t *testing.T) {
+	wallettest.Run(t, func(t *testing.T) (*wallet.Service, func() *wallet.Service) {
+		dir := t.TempDir()
+		open := func() *wallet.Service {
+			store, err := wallet.OpenLogStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { store.Close() })
+
+			svc := &wallet.Service{}
+			err = svc.SetStore(store)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return svc
+		}
+
+		return open(), open
+	})
+}
@@ -0,0 +1,130 @@
+package wallet
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+var (
+	ErrTenantNotFound  = errors.New("tenant not found")
+	ErrTenantExists    = errors.New("tenant already exists")
+	ErrInvalidTenantID = errors.New("invalid tenant id")
+)
+
+// Tenants хранит отдельный Service для каждого арендатора (мерчанта).
+// У каждого арендатора свои последовательность ID аккаунтов, уникальность телефонов,
+// платежи, журналы и настройки: сервисы не имеют общего состояния, поэтому запрос
+// к одному арендатору не видит данные другого. Нулевое значение готово к использованию.
+// Как и Service, Tenants не рассчитан на конкурентные вызовы.
+type Tenants struct {
+	services map[string]*Service
+}
+
+// validTenantID разрешает только буквы, цифры, '-' и '_': ID арендатора - имя его каталога экспорта.
+func validTenantID(tenantID string) bool {
+	if tenantID == "" || len(tenantID) > 64 {
+		return false
+	}
+
+	for _, r := range tenantID {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// Add создаёт пустой сервис арендатора tenantID.
+func (t *Tenants) Add(tenantID string) (*Service, error) {
+	if !validTenantID(tenantID) {
+		return nil, ErrInvalidTenantID
+	}
+
+	if _, ok := t.services[tenantID]; ok {
+		return nil, ErrTenantExists
+	}
+
+	if t.services == nil {
+		t.services = map[string]*Service{}
+	}
+
+	svc := &Service{}
+	t.services[tenantID] = svc
+	return svc, nil
+}
+
+// Tenant возвращает сервис арендатора tenantID.
+func (t *Tenants) Tenant(tenantID string) (*Service, error) {
+	svc, ok := t.services[tenantID]
+	if !ok {
+		return nil, ErrTenantNotFound
+	}
+
+	return svc, nil
+}
+
+// IDs возвращает ID арендаторов в порядке возрастания.
+func (t *Tenants) IDs() []string {
+	ids := make([]string, 0, len(t.services))
+	for id := range t.services {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// Export экспортирует каждого арендатора в отдельный каталог root/<tenantID>.
+func (t *Tenants) Export(root string) error {
+	for _, id := range t.IDs() {
+		dir := filepath.Join(root, id)
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			return err
+		}
+
+		err = t.services[id].Export(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Import импортирует арендаторов из подкаталогов root, созданных Export,
+// добавляя отсутствующих. Подкаталоги с недопустимыми именами пропускаются.
+func (t *Tenants) Import(root string) error {
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		id := entry.Name()
+		if !entry.IsDir() || !validTenantID(id) {
+			continue
+		}
+
+		svc, err := t.Tenant(id)
+		if errors.Is(err, ErrTenantNotFound) {
+			svc, err = t.Add(id)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = svc.Import(filepath.Join(root, id))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
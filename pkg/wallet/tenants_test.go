@@ -0,0 +1,142 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTenants_isolation(t *testing.T) {
+	tenants := &Tenants{}
+
+	first, err := tenants.Add("shop-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := tenants.Add("shop-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firstAccount, err := first.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// тот же телефон у другого арендатора допустим, ID начинаются заново
+	secondAccount, err := second.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if firstAccount.ID != 1 || secondAccount.ID != 1 {
+		t.Errorf("invalid ids, got %v and %v, want 1 and 1", firstAccount.ID, secondAccount.ID)
+	}
+
+	_, err = first.RegisterAccount("+992000000001")
+	if !errors.Is(err, ErrPhoneNumberRegistred) {
+		t.Errorf("invalid error, got %v, want %v", err, ErrPhoneNumberRegistred)
+	}
+
+	err = first.Deposit(firstAccount.ID, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, err := first.Pay(firstAccount.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = second.FindPaymentByID(payment.ID)
+	if !errors.Is(err, ErrPaymentNotFound) {
+		t.Errorf("payment leaked to other tenant: %v", err)
+	}
+
+	_, err = second.ExportAccountHistory(secondAccount.ID)
+	if !errors.Is(err, ErrPaymentNotFound) {
+		t.Errorf("history leaked to other tenant: %v", err)
+	}
+}
+
+func TestTenants_Add_errors(t *testing.T) {
+	tenants := &Tenants{}
+
+	_, err := tenants.Add("shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tenants.Add("shop")
+	if !errors.Is(err, ErrTenantExists) {
+		t.Errorf("invalid error, got %v, want %v", err, ErrTenantExists)
+	}
+
+	for _, id := range []string{"", "../shop", "shop/1", "shop 1"} {
+		_, err = tenants.Add(id)
+		if !errors.Is(err, ErrInvalidTenantID) {
+			t.Errorf("tenant %q: got %v, want %v", id, err, ErrInvalidTenantID)
+		}
+	}
+
+	_, err = tenants.Tenant("unknown")
+	if !errors.Is(err, ErrTenantNotFound) {
+		t.Errorf("invalid error, got %v, want %v", err, ErrTenantNotFound)
+	}
+}
+
+func TestTenants_ExportImport(t *testing.T) {
+	tenants := &Tenants{}
+	first, _ := tenants.Add("shop-1")
+	second, _ := tenants.Add("shop-2")
+
+	account, err := first.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = first.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = first.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// у второго арендатора нет платежей
+	_, err = second.RegisterAccount("+992000000002")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	err = tenants.Export(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported := &Tenants{}
+	err = imported.Import(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := imported.IDs(); len(ids) != 2 || ids[0] != "shop-1" || ids[1] != "shop-2" {
+		t.Fatalf("invalid imported tenants: %v", ids)
+	}
+
+	importedFirst, _ := imported.Tenant("shop-1")
+	importedSecond, _ := imported.Tenant("shop-2")
+	if len(importedFirst.payments) != 1 || len(importedSecond.payments) != 0 {
+		t.Errorf("invalid imported payments: %v and %v", len(importedFirst.payments), len(importedSecond.payments))
+	}
+
+	_, err = importedSecond.FindAccountByPhone("+992000000001")
+	if !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("account leaked to other tenant: %v", err)
+	}
+
+	next, err := importedSecond.RegisterAccount("+992000000003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.ID != 2 {
+		t.Errorf("invalid next id, got %v, want 2", next.ID)
+	}
+}
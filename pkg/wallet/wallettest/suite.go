@@ -0,0 +1,617 @@
+// Package wallettest содержит общий набор тестов wallet.Service, который прогоняется
+// и для сервиса в памяти, и для сервиса с постоянным хранилищем.
+package wallettest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Ulugbek999/wallet/pkg/types"
+	"github.com/Ulugbek999/wallet/pkg/wallet"
+)
+
+// Factory создаёт пустой сервис для одного теста. reopen возвращает сервис,
+// заново открытый из того же хранилища (для сервиса в памяти - тот же самый),
+// чтобы проверить, что результат вызовов сохранился.
+type Factory func(t *testing.T) (svc *wallet.Service, reopen func() *wallet.Service)
+
+// Run прогоняет набор тестов для сервисов, созданных factory.
+func Run(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, factory Factory)
+	}{
+		{"register and deposit", testRegisterAndDeposit},
+		{"pay and reject", testPayAndReject},
+		{"failed payment", testFailedPayment},
+		{"repeat", testRepeat},
+		{"favorites", testFavorites},
+		{"find by id", testFindByID},
+		{"reject unknown payment", testRejectUnknown},
+		{"idempotent pay", testIdempotentPay},
+		{"sum payments", testSumPayments},
+		{"export and import", testExportImport},
+		{"holds", testHolds},
+		{"schedules", testSchedules},
+		{"phone history", testPhoneHistory},
+		{"outbox, idempotency and audit", testOutboxIdempotencyAudit},
+		{"risk checks", testRiskChecks},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.test(t, factory)
+		})
+	}
+}
+
+func register(t *testing.T, svc *wallet.Service, phone types.Phone, balance types.Money) *types.Account {
+	t.Helper()
+
+	account, err := svc.RegisterAccount(phone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if balance > 0 {
+		err = svc.Deposit(account.ID, balance)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return account
+}
+
+func balance(t *testing.T, svc *wallet.Service, accountID int64) types.Money {
+	t.Helper()
+
+	account, err := svc.FindAccountByID(accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return account.Balance
+}
+
+func testRegisterAndDeposit(t *testing.T, factory Factory) {
+	svc, reopen := factory(t)
+
+	first := register(t, svc, "+992000000001", 1000)
+	second := register(t, svc, "+992000000002", 0)
+
+	_, err := svc.RegisterAccount("+992000000001")
+	if !errors.Is(err, wallet.ErrPhoneNumberRegistred) {
+		t.Errorf("invalid error, got %v, want %v", err, wallet.ErrPhoneNumberRegistred)
+	}
+
+	err = svc.Deposit(second.ID, 0)
+	if !errors.Is(err, wallet.ErrAmountMustBePositive) {
+		t.Errorf("invalid error, got %v, want %v", err, wallet.ErrAmountMustBePositive)
+	}
+
+	svc = reopen()
+	if got := balance(t, svc, first.ID); got != 1000 {
+		t.Errorf("invalid balance, got %v, want 1000", got)
+	}
+
+	account, err := svc.FindAccountByPhone("+992000000002")
+	if err != nil || account.ID != second.ID {
+		t.Errorf("invalid account by phone: %+v, %v", account, err)
+	}
+
+	third := register(t, svc, "+992000000003", 0)
+	if third.ID != second.ID+1 {
+		t.Errorf("invalid id after reopen, got %v, want %v", third.ID, second.ID+1)
+	}
+}
+
+func testPayAndReject(t *testing.T, factory Factory) {
+	svc, reopen := factory(t)
+	account := register(t, svc, "+992000000001", 1000)
+
+	payment, err := svc.Pay(account.ID, 300, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept, err := svc.Pay(account.ID, 200, "food")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc = reopen()
+	if got := balance(t, svc, account.ID); got != 800 {
+		t.Errorf("invalid balance, got %v, want 800", got)
+	}
+
+	rejected, err := svc.FindPaymentByID(payment.ID)
+	if err != nil || rejected.Status != types.PaymentStatusFail {
+		t.Errorf("invalid rejected payment: %+v, %v", rejected, err)
+	}
+
+	got, err := svc.FindPaymentByID(kept.ID)
+	if err != nil || got.Amount != 200 || got.Category != "food" || got.Status != types.PaymentStatusInProgress {
+		t.Errorf("invalid payment: %+v, %v", got, err)
+	}
+}
+
+func testFailedPayment(t *testing.T, factory Factory) {
+	svc, reopen := factory(t)
+	account := register(t, svc, "+992000000001", 100)
+
+	_, err := svc.Pay(account.ID, 101, "auto")
+	if !errors.Is(err, wallet.ErrNotEnoughBalance) {
+		t.Fatalf("invalid error, got %v, want %v", err, wallet.ErrNotEnoughBalance)
+	}
+
+	_, err = svc.Pay(account.ID+1, 1, "auto")
+	if !errors.Is(err, wallet.ErrAccountNotFound) {
+		t.Errorf("invalid error, got %v, want %v", err, wallet.ErrAccountNotFound)
+	}
+
+	svc = reopen()
+	if got := balance(t, svc, account.ID); got != 100 {
+		t.Errorf("invalid balance, got %v, want 100", got)
+	}
+
+	history, err := svc.ExportAccountHistory(account.ID)
+	if !errors.Is(err, wallet.ErrPaymentNotFound) {
+		t.Errorf("unexpected history: %+v, %v", history, err)
+	}
+}
+
+func testRepeat(t *testing.T, factory Factory) {
+	svc, reopen := factory(t)
+	account := register(t, svc, "+992000000001", 1000)
+
+	payment, err := svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repeated, err := svc.Repeat(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repeated.ID == payment.ID || repeated.Amount != payment.Amount {
+		t.Errorf("invalid repeated payment: %+v", repeated)
+	}
+
+	svc = reopen()
+	history, err := svc.ExportAccountHistory(account.ID)
+	if err != nil || len(history) != 2 {
+		t.Errorf("invalid history: %+v, %v", history, err)
+	}
+
+	if got := balance(t, svc, account.ID); got != 800 {
+		t.Errorf("invalid balance, got %v, want 800", got)
+	}
+}
+
+func testFavorites(t *testing.T, factory Factory) {
+	svc, reopen := factory(t)
+	account := register(t, svc, "+992000000001", 1000)
+
+	payment, err := svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept, err := svc.FavoritePayment(payment.ID, "car")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := svc.FavoritePayment(payment.ID, "old car")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.FavoritePayment(payment.ID, "car")
+	if !errors.Is(err, wallet.ErrFavoriteNameTaken) {
+		t.Errorf("invalid error, got %v, want %v", err, wallet.ErrFavoriteNameTaken)
+	}
+
+	_, err = svc.UpdateFavorite(kept.ID, "car", 150)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.DeleteFavorite(deleted.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.PayFromFavorite(kept.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc = reopen()
+	favorites, err := svc.FavoritesForAccount(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(favorites) != 1 || favorites[0].ID != kept.ID || favorites[0].Amount != 150 {
+		t.Errorf("invalid favorites: %+v", favorites)
+	}
+
+	if got := balance(t, svc, account.ID); got != 750 {
+		t.Errorf("invalid balance, got %v, want 750", got)
+	}
+}
+
+func testFindByID(t *testing.T, factory Factory) {
+	svc, reopen := factory(t)
+	account := register(t, svc, "+992000000001", 1000)
+
+	payment, err := svc.Pay(account.ID, 500, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc = reopen()
+	gotAccount, err := svc.FindAccountByID(account.ID)
+	if err != nil || gotAccount.Phone != account.Phone || gotAccount.Balance != 500 {
+		t.Errorf("invalid account: %+v, %v", gotAccount, err)
+	}
+
+	_, err = svc.FindAccountByID(account.ID + 1)
+	if !errors.Is(err, wallet.ErrAccountNotFound) {
+		t.Errorf("invalid error, got %v, want %v", err, wallet.ErrAccountNotFound)
+	}
+
+	gotPayment, err := svc.FindPaymentByID(payment.ID)
+	if err != nil || gotPayment.AccountID != account.ID || gotPayment.Amount != 500 || gotPayment.Category != "auto" {
+		t.Errorf("invalid payment: %+v, %v", gotPayment, err)
+	}
+
+	_, err = svc.FindPaymentByID("unknown")
+	if !errors.Is(err, wallet.ErrPaymentNotFound) {
+		t.Errorf("invalid error, got %v, want %v", err, wallet.ErrPaymentNotFound)
+	}
+}
+
+func testRejectUnknown(t *testing.T, factory Factory) {
+	svc, reopen := factory(t)
+	account := register(t, svc, "+992000000001", 1000)
+
+	_, err := svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.Reject("unknown")
+	if !errors.Is(err, wallet.ErrPaymentNotFound) {
+		t.Errorf("invalid error, got %v, want %v", err, wallet.ErrPaymentNotFound)
+	}
+
+	svc = reopen()
+	if got := balance(t, svc, account.ID); got != 900 {
+		t.Errorf("invalid balance, got %v, want 900", got)
+	}
+}
+
+func testIdempotentPay(t *testing.T, factory Factory) {
+	svc, reopen := factory(t)
+	account := register(t, svc, "+992000000001", 1000)
+
+	payment, err := svc.PayIdempotent("key-1", account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repeated, err := svc.PayIdempotent("key-1", account.ID, 100, "auto")
+	if err != nil || repeated.ID != payment.ID {
+		t.Errorf("invalid repeated payment: %+v, %v", repeated, err)
+	}
+
+	_, err = svc.PayIdempotent("key-1", account.ID, 200, "auto")
+	if !errors.Is(err, wallet.ErrIdempotencyKeyReused) {
+		t.Errorf("invalid error, got %v, want %v", err, wallet.ErrIdempotencyKeyReused)
+	}
+
+	svc = reopen()
+	if got := balance(t, svc, account.ID); got != 900 {
+		t.Errorf("invalid balance, got %v, want 900", got)
+	}
+}
+
+func testSumPayments(t *testing.T, factory Factory) {
+	svc, reopen := factory(t)
+	first := register(t, svc, "+992000000001", 1000)
+	second := register(t, svc, "+992000000002", 1000)
+
+	for i := 0; i < 10; i++ {
+		_, err := svc.Pay(first.ID, 10, "auto")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = svc.Pay(second.ID, 20, "food")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	svc = reopen()
+	if got := svc.SumPayments(3); got != 300 {
+		t.Errorf("invalid sum, got %v, want 300", got)
+	}
+}
+
+func testExportImport(t *testing.T, factory Factory) {
+	source, _ := factory(t)
+	account := register(t, source, "+992000000001", 1000)
+
+	payment, err := source.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	favorite, err := source.FavoritePayment(payment.ID, "car")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schedule, err := wallet.Daily(12, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recurring, err := source.SchedulePayment(favorite.ID, schedule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = source.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc, reopen := factory(t)
+	err = svc.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc = reopen()
+	if got := balance(t, svc, account.ID); got != 900 {
+		t.Errorf("invalid balance, got %v, want 900", got)
+	}
+
+	_, err = svc.FindPaymentByID(payment.ID)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = svc.PayFromFavorite(favorite.ID)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = svc.CancelSchedule(recurring.ID)
+	if err != nil {
+		t.Errorf("schedule must be imported: %v", err)
+	}
+}
+
+func testSchedules(t *testing.T, factory Factory) {
+	svc, reopen := factory(t)
+	account := register(t, svc, "+992000000001", 1000)
+
+	payment, err := svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	favorite, err := svc.FavoritePayment(payment.ID, "car")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schedule, err := wallet.ParseCron("30 9 * * 1-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := svc.SchedulePayment(favorite.ID, schedule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canceled, err := svc.SchedulePayment(favorite.ID, schedule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.CancelSchedule(canceled.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc = reopen()
+	err = svc.CancelSchedule(canceled.ID)
+	if !errors.Is(err, wallet.ErrScheduleNotFound) {
+		t.Errorf("invalid error for canceled schedule, got %v, want %v", err, wallet.ErrScheduleNotFound)
+	}
+
+	err = svc.CancelSchedule(kept.ID)
+	if err != nil {
+		t.Errorf("schedule must be stored: %v", err)
+	}
+
+	svc = reopen()
+	err = svc.CancelSchedule(kept.ID)
+	if !errors.Is(err, wallet.ErrScheduleNotFound) {
+		t.Errorf("invalid error after reopen, got %v, want %v", err, wallet.ErrScheduleNotFound)
+	}
+}
+
+func testHolds(t *testing.T, factory Factory) {
+	svc, reopen := factory(t)
+	account := register(t, svc, "+992000000001", 1000)
+	house := register(t, svc, "+992000000000", 0)
+
+	// комиссия резервируется вместе с суммой и сохраняется в резервировании
+	err := svc.SetFeeRules(house.ID, wallet.FeeRule{Flat: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	captured, err := svc.Authorize(account.ID, 300, "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	active, err := svc.Authorize(account.ID, 200, "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := svc.Capture(captured.ID, 250)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc = reopen()
+	got, err := svc.FindAccountByID(account.ID)
+	if err != nil || got.Balance != 740 || got.Held != 210 {
+		t.Errorf("invalid account: %+v, %v", got, err)
+	}
+
+	hold, err := svc.FindHoldByID(active.ID)
+	if err != nil || hold.Fee != 10 {
+		t.Errorf("invalid active hold: %+v, %v", hold, err)
+	}
+
+	hold, err = svc.FindHoldByID(captured.ID)
+	if err != nil || hold.Status != types.HoldStatusCaptured || hold.PaymentID != payment.ID {
+		t.Errorf("invalid captured hold: %+v, %v", hold, err)
+	}
+
+	capturedPayment, err := svc.FindPaymentByID(payment.ID)
+	if err != nil || capturedPayment.Fee != 10 || capturedPayment.FeeAccountID != house.ID {
+		t.Errorf("invalid captured payment: %+v, %v", capturedPayment, err)
+	}
+
+	err = svc.Release(active.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc = reopen()
+	got, err = svc.FindAccountByID(account.ID)
+	if err != nil || got.Held != 0 {
+		t.Errorf("invalid account after release: %+v, %v", got, err)
+	}
+
+	hold, err = svc.FindHoldByID(active.ID)
+	if err != nil || hold.Status != types.HoldStatusReleased {
+		t.Errorf("invalid released hold: %+v, %v", hold, err)
+	}
+}
+
+func testPhoneHistory(t *testing.T, factory Factory) {
+	svc, reopen := factory(t)
+	account := register(t, svc, "+992000000001", 0)
+
+	err := svc.ChangePhone(account.ID, "+992000000002")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc = reopen()
+	err = svc.ChangePhone(account.ID, "+992000000003")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc = reopen()
+	history := svc.PhoneHistory(account.ID)
+	if len(history) != 2 || history[0].NewPhone != "+992000000002" || history[1].OldPhone != "+992000000002" || history[1].NewPhone != "+992000000003" {
+		t.Errorf("invalid history: %+v", history)
+	}
+
+	_, err = svc.FindAccountByPhone("+992000000003")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testOutboxIdempotencyAudit(t *testing.T, factory Factory) {
+	svc, reopen := factory(t)
+	account := register(t, svc, "+992000000001", 1000)
+
+	payment, err := svc.PayIdempotent("key-1", account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.DispatchOutbox(context.Background(), func(event wallet.Event) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := svc.Pay(account.ID, 50, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit := svc.AuditLog()
+
+	svc = reopen()
+	outbox := svc.Outbox()
+	if len(outbox) != 1 || outbox[0].Event.PaymentID != pending.ID {
+		t.Errorf("invalid outbox: %+v", outbox)
+	}
+
+	repeated, err := svc.PayIdempotent("key-1", account.ID, 100, "auto")
+	if err != nil || repeated.ID != payment.ID {
+		t.Errorf("invalid repeated payment: %+v, %v", repeated, err)
+	}
+	if got := balance(t, svc, account.ID); got != 850 {
+		t.Errorf("invalid balance, got %v, want 850", got)
+	}
+
+	err = svc.VerifyAuditLog()
+	if err != nil {
+		t.Error(err)
+	}
+	log := svc.AuditLog()
+	if len(log) < len(audit) {
+		t.Fatalf("audit log not saved, got %v records, want at least %v", len(log), len(audit))
+	}
+	for i := range audit {
+		if log[i].Hash != audit[i].Hash {
+			t.Errorf("invalid audit record %v: %+v, want %+v", i, log[i], audit[i])
+		}
+	}
+}
+
+func testRiskChecks(t *testing.T, factory Factory) {
+	svc, reopen := factory(t)
+	account := register(t, svc, "+992000000001", 1000)
+
+	svc.SetRiskRules(wallet.RiskRule{
+		Name: "manual",
+		Check: func(request wallet.RiskRequest) (types.RiskDecision, string) {
+			return types.RiskReview, "check; amount\nmanually"
+		},
+	})
+
+	payment, err := svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc = reopen()
+	stored, err := svc.FindPaymentByID(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != types.PaymentStatusReview || len(stored.Risk) != 1 || stored.Risk[0] != payment.Risk[0] {
+		t.Errorf("invalid risk checks: %+v, want %+v", stored, payment.Risk)
+	}
+}